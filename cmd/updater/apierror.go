@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+)
+
+// rayIDHeader identifies a request in Cloudflare's logs. Support asks for it
+// when investigating a failed call.
+const rayIDHeader = "CF-Ray"
+
+// apiMessage is one entry of the errors array in a Cloudflare response.
+type apiMessage struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIFailureError is returned when Cloudflare answers with success=false in
+// the response envelope. The SDK only treats HTTP status codes >= 400 as
+// errors, so this catches failures delivered with a 2xx status.
+type APIFailureError struct {
+	Errors []apiMessage
+}
+
+func (e *APIFailureError) Error() string {
+	if len(e.Errors) == 0 {
+		return "Cloudflare API reported success=false without error details"
+	}
+
+	details := make([]string, 0, len(e.Errors))
+	for _, msg := range e.Errors {
+		details = append(details, fmt.Sprintf("[%d] %s", msg.Code, msg.Message))
+	}
+	return "Cloudflare API reported failure: " + strings.Join(details, "; ")
+}
+
+// PermissionError is returned when Cloudflare refuses a write because the
+// credentials may not edit DNS records, typically a token with only Zone
+// Read or DNS Read.
+type PermissionError struct {
+	Err error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("the credentials may not edit DNS records (grant DNS Edit, or set %s=true to only report changes): %v", envReadOnly, e.Err)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// Error codes Cloudflare reports for credentials lacking a permission.
+var permissionCodes = map[int64]bool{9109: true, 10000: true}
+
+// asPermissionError wraps err in a *PermissionError when it is a 403 or a
+// failure envelope carrying a permission error code.
+func asPermissionError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var sdkErr *cloudflare.Error
+	if errors.As(err, &sdkErr) && sdkErr.StatusCode == http.StatusForbidden {
+		return &PermissionError{Err: err}
+	}
+	var failure *APIFailureError
+	if errors.As(err, &failure) {
+		for _, msg := range failure.Errors {
+			if permissionCodes[msg.Code] {
+				return &PermissionError{Err: err}
+			}
+		}
+	}
+	return err
+}
+
+// checkSuccess inspects a raw response envelope and returns an
+// *APIFailureError when it reports success=false. Bodies that cannot be
+// parsed or omit the field are left to the SDK's own handling.
+func checkSuccess(raw string) error {
+	var envelope struct {
+		Success *bool        `json:"success"`
+		Errors  []apiMessage `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(raw), &envelope); err != nil {
+		return nil
+	}
+
+	if envelope.Success == nil || *envelope.Success {
+		return nil
+	}
+	return &APIFailureError{Errors: envelope.Errors}
+}
+
+// withRayID appends the CF-Ray ID of the failed call to err. The ID is taken
+// from the SDK error when it carries the response, otherwise from resp.
+func withRayID(err error, resp *http.Response) error {
+	if err == nil {
+		return nil
+	}
+
+	var sdkErr *cloudflare.Error
+	if errors.As(err, &sdkErr) && sdkErr.Response != nil {
+		resp = sdkErr.Response
+	}
+	if resp == nil {
+		return err
+	}
+
+	rayID := resp.Header.Get(rayIDHeader)
+	if rayID == "" {
+		return err
+	}
+	return fmt.Errorf("%w (%s: %s)", err, rayIDHeader, rayID)
+}
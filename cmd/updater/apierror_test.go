@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// staticJSONClient returns an HTTP client that answers every request with the
+// given status and JSON body.
+func staticJSONClient(status int, body string) *http.Client {
+	return &http.Client{
+		Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp := &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				Header:     make(http.Header),
+			}
+			resp.Header.Set("Content-Type", "application/json")
+			return resp, nil
+		}),
+	}
+}
+
+const failedEnvelope = `{"success":false,"errors":[{"code":1004,"message":"DNS Validation Error"}],"messages":[],"result":null}`
+
+func TestFetchDNSRecordSuccessFalse(t *testing.T) {
+	cfg := Config{AuthMethod: "token", AuthKey: "token-value", ZoneID: "zone-id", RecordName: "example.com", RecordType: "A"}
+	client, err := newCloudflareClient(staticJSONClient(http.StatusOK, failedEnvelope), cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	_, err = cloudflareProvider{client: client}.Fetch(context.Background(), cfg)
+	var apiErr *APIFailureError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIFailureError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "[1004] DNS Validation Error") {
+		t.Fatalf("expected error details, got %q", err.Error())
+	}
+}
+
+func TestUpdateDNSRecordSuccessFalse(t *testing.T) {
+	cfg := Config{AuthMethod: "token", AuthKey: "token-value", ZoneID: "zone-id", RecordName: "example.com", RecordType: "A", TTL: 300}
+	client, err := newCloudflareClient(staticJSONClient(http.StatusOK, failedEnvelope), cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	err = cloudflareProvider{client: client}.Update(context.Background(), cfg, "record-id", "198.51.100.3")
+	var apiErr *APIFailureError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIFailureError, got %v", err)
+	}
+	if len(apiErr.Errors) != 1 || apiErr.Errors[0].Code != 1004 {
+		t.Fatalf("unexpected error details %+v", apiErr.Errors)
+	}
+}
+
+func TestCheckSuccess(t *testing.T) {
+	if err := checkSuccess(`{"success":true,"errors":[]}`); err != nil {
+		t.Fatalf("expected nil for successful envelope, got %v", err)
+	}
+	if err := checkSuccess(`{"result":{}}`); err != nil {
+		t.Fatalf("expected nil when success is absent, got %v", err)
+	}
+	if err := checkSuccess(`{"success":false,"errors":[]}`); err == nil {
+		t.Fatalf("expected error for success=false")
+	}
+}
+
+func TestErrorsIncludeRayID(t *testing.T) {
+	cfg := Config{AuthMethod: "token", AuthKey: "token-value", ZoneID: "zone-id", RecordName: "example.com", RecordType: "A", TTL: 300}
+
+	for _, status := range []int{http.StatusOK, http.StatusForbidden} {
+		httpClient := staticJSONClient(status, failedEnvelope)
+		base := httpClient.Transport
+		httpClient.Transport = RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := base.RoundTrip(req)
+			if resp != nil {
+				resp.Header.Set(rayIDHeader, "8a1b2c3d4e5f6789-AMS")
+			}
+			return resp, err
+		})
+
+		client, err := newCloudflareClient(httpClient, cfg, nil)
+		if err != nil {
+			t.Fatalf("unexpected client error: %v", err)
+		}
+
+		err = cloudflareProvider{client: client}.Update(context.Background(), cfg, "record-id", "198.51.100.3")
+		if err == nil || !strings.Contains(err.Error(), "CF-Ray: 8a1b2c3d4e5f6789-AMS") {
+			t.Fatalf("status %d: expected Ray ID in error, got %v", status, err)
+		}
+
+		_, err = cloudflareProvider{client: client}.Fetch(context.Background(), cfg)
+		if err == nil || !strings.Contains(err.Error(), "CF-Ray: 8a1b2c3d4e5f6789-AMS") {
+			t.Fatalf("status %d: expected Ray ID in error, got %v", status, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// version is the updater's version, set at build time with
+// -ldflags "-X main.version=v1.3.0".
+var version = "dev"
+
+// auditSource returns the provenance written by CF_AUDIT_COMMENT: the
+// machine's hostname and the updater's version.
+func auditSource() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown-host"
+	}
+	return fmt.Sprintf("ddns by %s %s", host, version)
+}
+
+// auditComment stamps source with the time of the write.
+func auditComment(source string, now time.Time) string {
+	return source + " @ " + now.UTC().Format(time.RFC3339)
+}
+
+// recordComment returns the comment written with a record, or "" to leave
+// it unset. Sync mode writes the managed marker, and CF_AUDIT_COMMENT
+// appends its provenance after it.
+func recordComment(cfg Config) string {
+	var parts []string
+	if cfg.RecordMode == recordModeSync {
+		parts = append(parts, managedComment)
+	}
+	if cfg.AuditComment != "" {
+		parts = append(parts, cfg.AuditComment)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// isManaged reports whether comment carries the managed marker, alone or
+// followed by an audit comment.
+func isManaged(comment string) bool {
+	return comment == managedComment || strings.HasPrefix(comment, managedComment+"; ")
+}
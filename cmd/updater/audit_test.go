@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordComment(t *testing.T) {
+	audit := auditComment("ddns by host-42 v1.3.0", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if audit != "ddns by host-42 v1.3.0 @ 2024-01-01T00:00:00Z" {
+		t.Fatalf("unexpected audit comment %q", audit)
+	}
+
+	cases := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{RecordMode: recordModeUpdate}, ""},
+		{Config{RecordMode: recordModeSync}, managedComment},
+		{Config{RecordMode: recordModeUpdate, AuditComment: audit}, audit},
+		{Config{RecordMode: recordModeSync, AuditComment: audit}, managedComment + "; " + audit},
+	}
+	for _, c := range cases {
+		if got := recordComment(c.cfg); got != c.want {
+			t.Errorf("recordComment(%s, %q) = %q, want %q", c.cfg.RecordMode, c.cfg.AuditComment, got, c.want)
+		}
+		if c.cfg.RecordMode == recordModeSync && !isManaged(recordComment(c.cfg)) {
+			t.Errorf("expected %q to count as managed", recordComment(c.cfg))
+		}
+	}
+	if isManaged(managedComment + " by hand") {
+		t.Fatalf("expected a lookalike comment not to count as managed")
+	}
+}
+
+func TestUpdaterAuditComment(t *testing.T) {
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "203.0.113.10"),
+	)
+	cfg := Config{RecordNames: []string{"a.example.com", "b.example.com"}, AuditComment: "ddns by host-42 v1.3.0"}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	comment, _ := api.records["a.example.com"]["comment"].(string)
+	if !strings.HasPrefix(comment, "ddns by host-42 v1.3.0 @ ") {
+		t.Fatalf("expected the updated record to carry the audit comment, got %q", comment)
+	}
+	if _, ok := api.records["b.example.com"]["comment"]; ok || api.updates != 1 {
+		t.Fatalf("expected the up-to-date record left alone, got %d update(s)", api.updates)
+	}
+}
+
+func TestUpdaterAuditCommentSyncMode(t *testing.T) {
+	stale := aRecordFixture("id-2", "old.example.com", "198.51.100.1")
+	stale["comment"] = managedComment + "; ddns by host-7 v1.2.0 @ 2024-01-01T00:00:00Z"
+	current := aRecordFixture("id-1", "a.example.com", "203.0.113.10")
+	current["comment"] = managedComment + "; ddns by host-7 v1.2.0 @ 2024-01-01T00:00:00Z"
+
+	api := newMockCloudflare(current, stale)
+	cfg := Config{RecordNames: []string{"a.example.com"}, RecordMode: recordModeSync, Prune: true, AuditComment: "ddns by host-42 v1.3.0"}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || results[0].Action != actionUnchanged || results[1].Action != actionDeleted {
+		t.Fatalf("expected the audited record kept and the stale one pruned, got %+v", results)
+	}
+	if api.updates != 0 {
+		t.Fatalf("expected the older audit comment not to force a write, got %d update(s)", api.updates)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "regexp"
+
+// authMethodAuto picks token or global authentication from the shape of the
+// key and whether an email is set.
+const authMethodAuto = "auto"
+
+// globalKeyPattern matches a Cloudflare Global API Key: 37 lowercase
+// hexadecimal characters. API tokens are 40 characters long and use the
+// full alphanumeric range.
+var globalKeyPattern = regexp.MustCompile(`^[0-9a-f]{37}$`)
+
+// inferAuthMethod returns "global" for a key shaped like a Global API Key
+// when an email is set, and "token" otherwise, along with the reason for
+// the log.
+func inferAuthMethod(key, email string) (string, string) {
+	looksGlobal := globalKeyPattern.MatchString(key)
+	switch {
+	case looksGlobal && email != "":
+		return "global", "the key looks like a Global API Key and an email is set"
+	case looksGlobal:
+		return "token", "the key looks like a Global API Key, but global authentication needs " + envAuthEmail
+	default:
+		return "token", "the key looks like an API token"
+	}
+}
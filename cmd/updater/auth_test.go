@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	testGlobalKey = "0123456789abcdef0123456789abcdef01234"
+	testAPIToken  = "Abc_DEF-0123456789abcdefghijklmnopqrstuv"
+)
+
+func TestInferAuthMethod(t *testing.T) {
+	cases := []struct {
+		key, email, want string
+	}{
+		{testGlobalKey, "ops@example.com", "global"},
+		{testGlobalKey, "", "token"},
+		{testAPIToken, "", "token"},
+		{testAPIToken, "ops@example.com", "token"},
+		{strings.ToUpper(testGlobalKey), "ops@example.com", "token"},
+	}
+	for _, tc := range cases {
+		if got, _ := inferAuthMethod(tc.key, tc.email); got != tc.want {
+			t.Errorf("inferAuthMethod(%q, %q) = %q, want %q", tc.key, tc.email, got, tc.want)
+		}
+	}
+}
+
+func TestLoadConfigAuthMethodAuto(t *testing.T) {
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "home.example.com")
+	t.Setenv(envAuthKey, testGlobalKey)
+	t.Setenv(envAuthEmail, "ops@example.com")
+
+	cfg, err := loadConfig()
+	if err != nil || cfg.AuthMethod != "global" {
+		t.Fatalf("expected global authentication to be inferred, got %q, %v", cfg.AuthMethod, err)
+	}
+
+	t.Setenv(envAuthMethod, "token")
+	if cfg, err = loadConfig(); err != nil || cfg.AuthMethod != "token" {
+		t.Fatalf("expected an explicit method to win, got %q, %v", cfg.AuthMethod, err)
+	}
+
+	t.Setenv(envAuthMethod, "AUTO")
+	t.Setenv(envAuthKey, testAPIToken)
+	if cfg, err = loadConfig(); err != nil || cfg.AuthMethod != "token" {
+		t.Fatalf("expected token authentication to be inferred, got %q, %v", cfg.AuthMethod, err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uptimePath reports the time since the system booted.
+var uptimePath = "/proc/uptime"
+
+// systemUptime returns how long ago the system booted, read from the first
+// field of uptimePath.
+func systemUptime() (time.Duration, error) {
+	data, err := os.ReadFile(uptimePath)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%s is empty", uptimePath)
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid uptime %q in %s", fields[0], uptimePath)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// bootWaitLeft returns how much of wait is left since the system booted.
+// When the uptime cannot be read, as on systems without /proc, the whole
+// wait is left, so the first run of the process is taken to be at boot.
+func bootWaitLeft(wait time.Duration) time.Duration {
+	uptime, err := systemUptime()
+	if err != nil {
+		return wait
+	}
+	return wait - uptime
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setUptime points uptimePath at a file holding content for the test.
+func setUptime(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "uptime")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	prev := uptimePath
+	uptimePath = path
+	t.Cleanup(func() { uptimePath = prev })
+}
+
+func TestUpdaterBootWait(t *testing.T) {
+	prev := bootRetryInterval
+	bootRetryInterval = time.Millisecond
+	t.Cleanup(func() { bootRetryInterval = prev })
+	setUptime(t, "3.50 7.00\n")
+
+	var calls int
+	offline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("203.0.113.10"))
+	}))
+	t.Cleanup(offline.Close)
+
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"home.example.com"}, BootWait: time.Minute}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.cfg.IPServices = []string{offline.URL}
+	u.clock = systemClock
+
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("expected the first run to wait for the network, got %v", err)
+	}
+	if calls != 4 || api.updates != 1 {
+		t.Fatalf("expected three failed attempts before success, got %d call(s) and %d update(s)", calls, api.updates)
+	}
+
+	// Only the first run waits.
+	calls = -10
+	err := u.cycle(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to determine public IP") {
+		t.Fatalf("expected a later run to fail at once, got %v", err)
+	}
+	if calls != -9 {
+		t.Fatalf("expected a single attempt after boot, got %d call(s)", calls+10)
+	}
+}
+
+func TestUpdaterBootWaitRunsOut(t *testing.T) {
+	prev, prevPath := bootRetryInterval, uptimePath
+	bootRetryInterval = time.Millisecond
+	// Without a readable uptime the first run is taken to be at boot.
+	uptimePath = filepath.Join(t.TempDir(), "missing")
+	t.Cleanup(func() { bootRetryInterval, uptimePath = prev, prevPath })
+
+	offline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(offline.Close)
+
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"home.example.com"}, BootWait: 20 * time.Millisecond}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.cfg.IPServices = []string{offline.URL}
+	u.clock = systemClock
+
+	_, err := u.run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), envBootWait) {
+		t.Fatalf("expected discovery to give up after the boot wait, got %v", err)
+	}
+	if api.updates != 0 {
+		t.Fatalf("expected no update, got %d", api.updates)
+	}
+}
+
+func TestUpdaterBootWaitSkippedLongAfterBoot(t *testing.T) {
+	prev := bootRetryInterval
+	bootRetryInterval = time.Millisecond
+	t.Cleanup(func() { bootRetryInterval = prev })
+	setUptime(t, "86400.00 170000.00\n")
+
+	var calls int
+	offline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(offline.Close)
+
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"home.example.com"}, BootWait: time.Minute}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.cfg.IPServices = []string{offline.URL}
+	u.clock = systemClock
+
+	if _, err := u.run(context.Background()); err == nil || strings.Contains(err.Error(), envBootWait) {
+		t.Fatalf("expected a run a day after boot to fail at once, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls)
+	}
+}
+
+func TestSystemUptime(t *testing.T) {
+	setUptime(t, "350.25 1200.10\n")
+	if uptime, err := systemUptime(); err != nil || uptime != 350250*time.Millisecond {
+		t.Fatalf("unexpected uptime %s, %v", uptime, err)
+	}
+	if left := bootWaitLeft(10 * time.Minute); left != 10*time.Minute-350250*time.Millisecond {
+		t.Fatalf("expected the wait to count from boot, got %s", left)
+	}
+
+	setUptime(t, "soon\n")
+	if _, err := systemUptime(); err == nil {
+		t.Fatalf("expected an invalid uptime to be rejected")
+	}
+	if left := bootWaitLeft(time.Minute); left != time.Minute {
+		t.Fatalf("expected the whole wait without an uptime, got %s", left)
+	}
+}
+
+func TestLoadConfigBootWait(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+
+	t.Setenv(envBootWait, "2m")
+	cfg, err := loadConfig()
+	if err != nil || cfg.BootWait != 2*time.Minute {
+		t.Fatalf("unexpected config %s, %v", cfg.BootWait, err)
+	}
+
+	t.Setenv(envBootWait, "soon")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envBootWait) {
+		t.Fatalf("expected an invalid value error, got %v", err)
+	}
+}
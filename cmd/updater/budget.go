@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+const defaultMaxAPICalls = 1000
+
+// errAPICallLimit is returned for Cloudflare calls made after a run used up
+// CF_MAX_API_CALLS.
+var errAPICallLimit = errors.New("Cloudflare API call limit reached")
+
+// callBudget caps the number of Cloudflare API calls in one run, guarding
+// against runaway pagination or retry loops burning the account's quota.
+// A nil budget, or one with a zero limit, allows any number of calls.
+type callBudget struct {
+	limit int64
+	calls atomic.Int64
+}
+
+func newCallBudget(limit int) *callBudget {
+	return &callBudget{limit: int64(limit)}
+}
+
+// reset starts counting a new run.
+func (b *callBudget) reset() {
+	if b != nil {
+		b.calls.Store(0)
+	}
+}
+
+// exceeded reports whether the current run attempted more calls than the
+// limit allows.
+func (b *callBudget) exceeded() bool {
+	return b != nil && b.limit > 0 && b.calls.Load() > b.limit
+}
+
+// middleware counts every call made through the client and fails those
+// beyond the limit without sending them. It sits inside the retry
+// middleware, so each attempt of a retried call counts.
+func (b *callBudget) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if n := b.calls.Add(1); b.limit > 0 && n > b.limit {
+			return nil, fmt.Errorf("%w (%s=%d)", errAPICallLimit, envMaxAPICalls, b.limit)
+		}
+		return next.RoundTrip(req)
+	})
+}
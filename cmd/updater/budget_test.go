@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUpdaterAbortsAtAPICallLimit(t *testing.T) {
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "198.51.100.1"),
+	)
+	cfg := Config{RecordNames: []string{"a.example.com", "b.example.com"}, Concurrency: 1, MaxAPICalls: 3}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.budget = newCallBudget(cfg.MaxAPICalls)
+	client, err := newCloudflareClient(api.client(), u.cfg, u.budget)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	u.cfClient = client
+
+	_, err = u.run(context.Background())
+	if !errors.Is(err, errAPICallLimit) {
+		t.Fatalf("expected the call limit to abort the run, got %v", err)
+	}
+	if api.updates != 1 {
+		t.Fatalf("expected calls past the limit not to be sent, got %d update(s)", api.updates)
+	}
+
+	// The count starts over with every run.
+	u.budget.limit = 4
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected a run within the limit to succeed, got %v", err)
+	}
+}
+
+func TestCallBudgetCountsRetryAttempts(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	api.unavailable = true
+	cfg := Config{RecordNames: []string{"example.com"}, MaxAPICalls: 3}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.cfg.Retry = RetryPolicy{Retries: 10, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Jitter: jitterNone}
+	u.budget = newCallBudget(cfg.MaxAPICalls)
+	var sent atomic.Int32
+	count := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent.Add(1)
+			return next.RoundTrip(req)
+		})
+	}
+	client, err := newCloudflareClient(withMiddlewares(api.client(), count), u.cfg, u.budget)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	u.cfClient = client
+
+	_, err = u.run(context.Background())
+	if !errors.Is(err, errAPICallLimit) {
+		t.Fatalf("expected the retries to exhaust the call limit, got %v", err)
+	}
+	if n := sent.Load(); n != 3 {
+		t.Fatalf("expected 3 attempts to be sent, got %d", n)
+	}
+}
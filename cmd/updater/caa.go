@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+// caaTags lists the CAA property tags Cloudflare accepts.
+var caaTags = map[string]bool{"issue": true, "issuewild": true, "iodef": true}
+
+// CAAData holds the components of a CAA record managed by the updater.
+type CAAData struct {
+	Flags int
+	Tag   string
+	Value string
+}
+
+// String formats the data in zone-file order so it can be logged and compared.
+func (d CAAData) String() string {
+	return fmt.Sprintf("%d %s %q", d.Flags, d.Tag, d.Value)
+}
+
+// parseCAAData fills in the flags and validates the tag and value. Flags
+// default to 0; tag and value are required.
+func parseCAAData(data *CAAData, flagsValue string) error {
+	if flagsValue != "" {
+		flags, err := strconv.Atoi(flagsValue)
+		if err != nil || flags < 0 || flags > 255 {
+			return fmt.Errorf("invalid %s value %q", envCAAFlags, flagsValue)
+		}
+		data.Flags = flags
+	}
+
+	data.Tag = strings.ToLower(strings.TrimSpace(data.Tag))
+	if data.Tag == "" {
+		return fmt.Errorf("%s is required for CAA records", envCAATag)
+	}
+	if !caaTags[data.Tag] {
+		return fmt.Errorf("invalid %s value %q (must be issue, issuewild or iodef)", envCAATag, data.Tag)
+	}
+
+	data.Value = strings.TrimSpace(data.Value)
+	if data.Value == "" {
+		return fmt.Errorf("%s is required for CAA records", envCAAValue)
+	}
+
+	return nil
+}
+
+func extractCAAData(record dns.Record) (CAAData, error) {
+	caaRecord, ok := record.AsUnion().(dns.CAARecord)
+	if !ok {
+		return CAAData{}, fmt.Errorf("record type %q is not supported", record.Type)
+	}
+
+	return CAAData{
+		Flags: int(caaRecord.Data.Flags),
+		Tag:   strings.ToLower(caaRecord.Data.Tag),
+		Value: caaRecord.Data.Value,
+	}, nil
+}
+
+func caaRecordParam(cfg Config) dns.CAARecordParam {
+	return dns.CAARecordParam{
+		Name: cloudflare.String(cfg.RecordName),
+		Type: cloudflare.F(dns.CAARecordTypeCAA),
+		TTL:  cloudflare.F(dns.TTL(float64(cfg.TTL))),
+		Data: cloudflare.F(dns.CAARecordDataParam{
+			Flags: cloudflare.F(float64(cfg.CAA.Flags)),
+			Tag:   cloudflare.String(cfg.CAA.Tag),
+			Value: cloudflare.String(cfg.CAA.Value),
+		}),
+	}
+}
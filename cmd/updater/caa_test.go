@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigCAA(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+	t.Setenv(envRecordType, "caa")
+	t.Setenv(envCAAFlags, "128")
+	t.Setenv(envCAATag, "Issue")
+	t.Setenv(envCAAValue, " letsencrypt.org ")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := CAAData{Flags: 128, Tag: "issue", Value: "letsencrypt.org"}
+	if cfg.CAA != expected {
+		t.Fatalf("unexpected CAA data %+v", cfg.CAA)
+	}
+}
+
+func TestLoadConfigCAARequiresTagAndValue(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+	t.Setenv(envRecordType, "CAA")
+	t.Setenv(envCAAValue, "letsencrypt.org")
+
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envCAATag) {
+		t.Fatalf("expected error when tag missing, got %v", err)
+	}
+
+	t.Setenv(envCAATag, "issuer")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envCAATag) {
+		t.Fatalf("expected error for unknown tag, got %v", err)
+	}
+
+	t.Setenv(envCAATag, "issue")
+	t.Setenv(envCAAFlags, "256")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envCAAFlags) {
+		t.Fatalf("expected error when flags out of range, got %v", err)
+	}
+
+	t.Setenv(envCAAFlags, "")
+	t.Setenv(envCAAValue, "")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envCAAValue) {
+		t.Fatalf("expected error when value missing, got %v", err)
+	}
+}
+
+func TestExtractCAAData(t *testing.T) {
+	record := recordFromJSON(t, `{"id":"caa","type":"CAA","name":"example.com","content":"0 issue \"letsencrypt.org\"","data":{"flags":0,"tag":"ISSUE","value":"letsencrypt.org"},"ttl":300}`)
+
+	content, err := extractRecordContent(record)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := `0 issue "letsencrypt.org"`; content != want {
+		t.Fatalf("unexpected content %q, want %q", content, want)
+	}
+}
+
+func TestUpdaterCAA(t *testing.T) {
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected IP service request for CAA-only configuration")
+	}))
+	t.Cleanup(ipServer.Close)
+
+	api := newMockCloudflare()
+	api.seed = true
+	cfg := Config{
+		RecordNames: []string{"example.com"},
+		RecordType:  "CAA",
+		CAA:         CAAData{Flags: 0, Tag: "issue", Value: "letsencrypt.org"},
+	}
+	u := newTestUpdater(t, cfg, api, "")
+	u.cfg.IPServices = []string{ipServer.URL}
+
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	data, _ := api.records["example.com"]["data"].(map[string]any)
+	if api.updates != 1 || data["tag"] != "issue" || data["value"] != "letsencrypt.org" {
+		t.Fatalf("unexpected record after update: %d update(s), %+v", api.updates, api.records["example.com"])
+	}
+
+	// The data now matches, so nothing is written.
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if api.updates != 1 {
+		t.Fatalf("expected no further update, got %d", api.updates)
+	}
+}
+
+func TestExplainDiscoveryCAA(t *testing.T) {
+	var out bytes.Buffer
+	cfg := Config{RecordNames: []string{"example.com"}, RecordType: "CAA", CAA: CAAData{Tag: "issue", Value: "letsencrypt.org"}}
+	explainDiscovery(&out, cfg, State{})
+	if !strings.Contains(out.String(), `CAA records (1): no discovery, data 0 issue "letsencrypt.org"`) {
+		t.Fatalf("unexpected explanation %q", out.String())
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/option"
+	"github.com/cloudflare/cloudflare-go/v2/user"
+	"github.com/cloudflare/cloudflare-go/v2/zones"
+)
+
+// zoneListPerPage is the page size requested when looking up a zone by name,
+// the largest the zones API allows.
+const zoneListPerPage = 50
+
+// check implements -check: it verifies the API token of every target, then
+// reads each configured zone with the credentials that will write to it and
+// prints the zone name, catching tokens that are valid but scoped to a
+// different zone. A zone configured only by name is resolved first.
+func (u *updater) check(ctx context.Context) error {
+	if u.cfg.ZoneID == "" {
+		if err := u.resolveZone(ctx); err != nil {
+			fmt.Fprintf(u.out, "zone %s: FAILED: %v\n", u.cfg.ZoneName, err)
+			return err
+		}
+		fmt.Fprintf(u.out, "zone %s: resolved to %s\n", u.cfg.ZoneName, u.cfg.ZoneID)
+	}
+
+	writeTargets := append([]writeTarget{{cfg: u.cfg, client: u.cfClient}}, u.targets...)
+
+	var failed int
+	for _, target := range writeTargets {
+		if target.cfg.AuthMethod == "token" {
+			if err := verifyToken(ctx, target.client); err != nil {
+				failed++
+				fmt.Fprintf(u.out, "zone %s: FAILED: API token verification failed: %v\n", target.cfg.ZoneID, err)
+				continue
+			}
+		}
+		name, err := readZone(ctx, target.client, target.cfg.ZoneID)
+		if err != nil {
+			failed++
+			fmt.Fprintf(u.out, "zone %s: FAILED: %v\n", target.cfg.ZoneID, err)
+			continue
+		}
+		fmt.Fprintf(u.out, "zone %s: ok (%s)\n", target.cfg.ZoneID, name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d zone(s) cannot be read with the configured credentials", failed, len(writeTargets))
+	}
+	return nil
+}
+
+// readZone fetches zoneID and returns its name.
+func readZone(ctx context.Context, client *cloudflare.Client, zoneID string) (string, error) {
+	var resp *http.Response
+	var envelope zones.ZoneGetResponseEnvelope
+	params := zones.ZoneGetParams{ZoneID: cloudflare.F(zoneID)}
+	if _, err := client.Zones.Get(ctx, params, option.WithResponseBodyInto(&envelope), option.WithResponseInto(&resp)); err != nil {
+		return "", withRayID(err, resp)
+	}
+	if err := checkSuccess(envelope.JSON.RawJSON()); err != nil {
+		return "", withRayID(err, resp)
+	}
+	if envelope.Result.Name == "" {
+		return "", withRayID(errors.New("zone response did not include a name"), resp)
+	}
+	return envelope.Result.Name, nil
+}
+
+// resolveZone looks up the ID of the zone configured only through
+// CF_ZONE_NAME. An API token is verified first, unless CF_MINIMAL is set, so
+// an invalid token and one that cannot see the zone fail with different
+// guidance. The ID is kept for
+// the life of the updater.
+func (u *updater) resolveZone(ctx context.Context) error {
+	if u.cfg.ZoneID != "" {
+		return nil
+	}
+
+	if u.cfg.AuthMethod == "token" && !u.cfg.Minimal {
+		if err := verifyToken(ctx, u.cfClient); err != nil {
+			return fmt.Errorf("API token verification failed: %w", err)
+		}
+	}
+
+	id, err := findZoneID(ctx, u.cfClient, u.cfg.ZoneName)
+	if err != nil {
+		return err
+	}
+	log.Printf("resolved zone %s to %s", u.cfg.ZoneName, id)
+	u.cfg.ZoneID = id
+	if u.zoneNames == nil {
+		u.zoneNames = make(map[string]string)
+	}
+	u.zoneNames[id] = u.cfg.ZoneName
+	return nil
+}
+
+// verifyToken checks that the client's API token is valid and active.
+func verifyToken(ctx context.Context, client *cloudflare.Client) error {
+	var resp *http.Response
+	var envelope user.TokenVerifyResponseEnvelope
+	if _, err := client.User.Tokens.Verify(ctx, option.WithResponseBodyInto(&envelope), option.WithResponseInto(&resp)); err != nil {
+		return withRayID(err, resp)
+	}
+	if err := checkSuccess(envelope.JSON.RawJSON()); err != nil {
+		return withRayID(err, resp)
+	}
+	if status := envelope.Result.Status; status != user.TokenVerifyResponseStatusActive {
+		return fmt.Errorf("token status is %q", status)
+	}
+	return nil
+}
+
+// findZoneID returns the ID of the zone called name, reading every page of
+// the listing. Zones the credentials cannot read are not listed, so a missing
+// zone usually means a token without Zone Read permission for it.
+func findZoneID(ctx context.Context, client *cloudflare.Client, name string) (string, error) {
+	for pageNumber := 1; ; pageNumber++ {
+		params := zones.ZoneListParams{
+			Name:    cloudflare.F(name),
+			Page:    cloudflare.F(float64(pageNumber)),
+			PerPage: cloudflare.F(float64(zoneListPerPage)),
+		}
+
+		var resp *http.Response
+		page, err := client.Zones.List(ctx, params, option.WithResponseInto(&resp))
+		if err != nil {
+			return "", withRayID(err, resp)
+		}
+		if err := checkSuccess(page.JSON.RawJSON()); err != nil {
+			return "", withRayID(err, resp)
+		}
+
+		for _, zone := range page.Result {
+			if strings.EqualFold(zone.Name, name) {
+				return zone.ID, nil
+			}
+		}
+		if len(page.Result) < zoneListPerPage {
+			break
+		}
+	}
+	return "", fmt.Errorf("no zone named %s is visible to these credentials; grant Zone Read on it or set %s", name, envZoneID)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUpdaterCheckZones(t *testing.T) {
+	api := newMockCloudflare()
+	api.zones = map[string]string{"zone-id": "example.com"}
+
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}}, api, "203.0.113.10")
+	var out bytes.Buffer
+	u.out = &out
+
+	if err := u.check(context.Background()); err != nil {
+		t.Fatalf("expected readable zone to pass, got %v", err)
+	}
+	if got := out.String(); got != "zone zone-id: ok (example.com)\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	target := Target{Name: "partner", ZoneID: "other-zone", AuthMethod: "token", AuthKey: "token-value", RecordNames: []string{"home.partner.example"}}
+	u.targets = []writeTarget{{cfg: target.config(u.cfg), client: u.cfClient}}
+	out.Reset()
+
+	err := u.check(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 zone(s)") {
+		t.Fatalf("expected wrong zone scope to fail, got %v", err)
+	}
+	if !strings.Contains(out.String(), "zone other-zone: FAILED: ") || !strings.Contains(out.String(), "9109") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestUpdaterResolvesZoneName(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	api.zones = map[string]string{"zone-id": "example.com", "other-id": "example.org"}
+
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}}, api, "203.0.113.10")
+	u.cfg.ZoneID = ""
+	u.cfg.ZoneName = "example.com"
+	var out bytes.Buffer
+	u.out = &out
+
+	if err := u.check(context.Background()); err != nil {
+		t.Fatalf("expected zone name to resolve, got %v", err)
+	}
+	if got := out.String(); got != "zone example.com: resolved to zone-id\nzone zone-id: ok (example.com)\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	if _, err := u.run(context.Background()); err != nil || api.updates != 1 {
+		t.Fatalf("expected update in the resolved zone, got %v after %d update(s)", err, api.updates)
+	}
+
+	u = newTestUpdater(t, Config{RecordNames: []string{"home.example.net"}}, api, "203.0.113.10")
+	u.cfg.ZoneID = ""
+	u.cfg.ZoneName = "example.net"
+	_, err := u.run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "grant Zone Read") {
+		t.Fatalf("expected guidance for an invisible zone, got %v", err)
+	}
+}
+
+func TestUpdaterCheckVerifiesTargetTokens(t *testing.T) {
+	api := newMockCloudflare()
+	api.disabledTokens = map[string]bool{"partner-token": true}
+
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}}, api, "203.0.113.10")
+	var out bytes.Buffer
+	u.out = &out
+
+	target := Target{Name: "partner", ZoneID: "other-zone", AuthMethod: "token", AuthKey: "partner-token", RecordNames: []string{"home.partner.example"}}
+	targetCfg := target.config(u.cfg)
+	client, err := newCloudflareClient(api.client(), targetCfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	u.targets = []writeTarget{{cfg: targetCfg, client: client}}
+
+	err = u.check(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 zone(s)") {
+		t.Fatalf("expected the disabled target token to fail, got %v", err)
+	}
+	want := "zone zone-id: ok (example.com)\nzone other-zone: FAILED: API token verification failed: token status is \"disabled\"\n"
+	if got := out.String(); got != want {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestFindZoneIDPaginates(t *testing.T) {
+	var pages []string
+	httpClient := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		page := req.URL.Query().Get("page")
+		pages = append(pages, page)
+		result := []map[string]any{}
+		if page == "1" {
+			// A listing that does not narrow by name fills the first page
+			// with other zones.
+			for i := range zoneListPerPage {
+				result = append(result, map[string]any{"id": fmt.Sprintf("other-%d", i), "name": fmt.Sprintf("zone%d.example", i)})
+			}
+		} else {
+			result = append(result, map[string]any{"id": "zone-id", "name": "example.com"})
+		}
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rec).Encode(map[string]any{"success": true, "errors": []any{}, "messages": []any{}, "result": result})
+		return rec.Result(), nil
+	})}
+	client, err := newCloudflareClient(httpClient, Config{AuthMethod: "token", AuthKey: "token-value"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	id, err := findZoneID(context.Background(), client, "example.com")
+	if err != nil || id != "zone-id" {
+		t.Fatalf("expected the zone on the second page, got %q, %v", id, err)
+	}
+	if !slices.Equal(pages, []string{"1", "2"}) {
+		t.Fatalf("expected two pages to be read, got %v", pages)
+	}
+}
+
+func TestFindZoneIDFailedEnvelope(t *testing.T) {
+	client, err := newCloudflareClient(staticJSONClient(http.StatusOK, failedEnvelope), Config{AuthMethod: "token", AuthKey: "token-value"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	if _, err := findZoneID(context.Background(), client, "example.com"); err == nil || !strings.Contains(err.Error(), "1004") {
+		t.Fatalf("expected the error envelope to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced Clock. Sleep advances the clock instead of
+// blocking, and tickers fire whenever Advance moves past their next deadline.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func newFakeClock(start time.Time) *fakeClock {
+	return &fakeClock{now: start}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Sleep(d time.Duration) {
+	f.Advance(d)
+}
+
+func (f *fakeClock) NewTicker(d time.Duration) Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{clock: f, ch: make(chan time.Time, 1), period: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward, delivering at most one pending tick per
+// ticker (matching time.Ticker's drop-on-slow-receiver behavior).
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	for _, t := range f.tickers {
+		if t.stopped || f.now.Before(t.next) {
+			continue
+		}
+		for !f.now.Before(t.next) {
+			t.next = t.next.Add(t.period)
+		}
+		select {
+		case t.ch <- f.now:
+		default:
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock   *fakeClock
+	ch      chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := newFakeClock(start)
+	ticker := clk.NewTicker(time.Minute)
+
+	clk.Advance(30 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatalf("ticker fired before its period elapsed")
+	default:
+	}
+
+	clk.Sleep(30 * time.Second)
+	select {
+	case got := <-ticker.C():
+		if !got.Equal(start.Add(time.Minute)) {
+			t.Fatalf("unexpected tick time %v", got)
+		}
+	default:
+		t.Fatalf("expected ticker to fire after one period")
+	}
+
+	ticker.Stop()
+	clk.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatalf("stopped ticker fired")
+	default:
+	}
+
+	if !clk.Now().Equal(start.Add(time.Hour + time.Minute)) {
+		t.Fatalf("unexpected clock time %v", clk.Now())
+	}
+}
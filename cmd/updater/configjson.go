@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// configJSONKeys lists the variables CF_CONFIG_JSON may set: every CF_*
+// variable except CF_CONFIG_JSON itself. The _FILE forms are left out, since
+// the JSON already carries the values.
+var configJSONKeys = []string{
+	envAuthEmail, envAuthMethod, envAuthKey, envZoneID, envRecordName,
+	envDisplayName, envRecordType, envTTL, envProxied, envIPServices,
+	envSRVPriority, envSRVWeight, envSRVPort, envSRVTarget, envCAAFlags,
+	envCAATag, envCAAValue, envDryRun, envReadOnly, envExcludeIPs,
+	envStateFile, envIPSticky, envIPShuffle, envIPInsecureTLS,
+	envRecordPattern, envSubdomains, envInterval, envOutput, envMissingOK,
+	envPreHook, envPostHook, envHookFailure, envRetries,
+	envRetryBaseDelay, envRetryJitter, envRetryMaxDelay,
+	envRetryMaxElapsed, envIPSource, envExpectedCountry,
+	envPrecheckTarget, envPrecheckTimeout, envFreezeFile, envGeoURL,
+	envRunTimeout, envIPv6Interface, envIPv6Prefer, envAPIBaseURL,
+	envRecordMode, envPrune, envPreserveMeta, envHealthAddr,
+	envMatchContent, envMultiMatch, envInferType, envTypeSuffixes,
+	envIPServiceRetries, envIPv6MatchPrefix, envIPConsensus,
+	envConsensusTiebreak, envAPIHostOverride, envAutoPrefer, envVerifyDNS,
+	envVerifyResolver, envVerifyTimeout, envTargetsFile, envLogFile,
+	envLogMaxSize, envLogMaxFiles, envLogJournal, envHistoryRetention,
+	envNameMatch, envZoneName, envIPv6Services, envNotifyURL, envNotifyOn,
+	envNotifyThreshold, envNotifyDiscordURL, envNetworkPrefixV4,
+	envNetworkPrefixV6, envStuckAfter, envMinimal, envExtraFields,
+	envConcurrency, envRunOnStart, envWebhookListenAddr, envWebhookSecret,
+	envRecheckInterval, envAlwaysFetch, envForce, envMaxRecordAge,
+	envReportFile, envMaxAPICalls, envIPSourceRecord, envContentCommand,
+	envRecordTag, envCustomHostnames, envAuditComment,
+	envDiscoveryRetries, envDiscoveryDelay, envMaxWritesPerHour,
+	envTextfilePath, envStrict, envDiscoveryTimeout, envMaskIP,
+	envBootWait, envMismatch, envOutageCooldown, envOutageThreshold,
+}
+
+// parseConfigJSON decodes CF_CONFIG_JSON, an object keyed by the names of
+// the CF_* variables, into the value each variable would hold. Strings are
+// taken as they are, arrays are joined with commas like the list variables,
+// and numbers, booleans and objects (for CF_EXTRA_FIELDS) keep their JSON
+// text. Every value then goes through the same checks as the environment.
+func parseConfigJSON(data string) (map[string]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", envConfigJSON, err)
+	}
+
+	values := make(map[string]string, len(fields))
+	for name, raw := range fields {
+		if !slices.Contains(configJSONKeys, name) {
+			return nil, fmt.Errorf("invalid %s: unsupported key %q", envConfigJSON, name)
+		}
+		value, err := configJSONValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value for %s: %w", envConfigJSON, name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// configJSONValue converts one CF_CONFIG_JSON value to its variable form.
+func configJSONValue(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return "", nil
+	case bytes.HasPrefix(raw, []byte(`"`)):
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return strings.TrimSpace(s), err
+	case bytes.HasPrefix(raw, []byte("[")):
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return "", err
+		}
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			if bytes.HasPrefix(bytes.TrimSpace(item), []byte("[")) {
+				return "", fmt.Errorf("nested arrays are not supported")
+			}
+			part, err := configJSONValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return string(raw), nil
+	}
+}
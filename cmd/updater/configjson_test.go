@@ -0,0 +1,124 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigJSON(t *testing.T) {
+	values, err := parseConfigJSON(`{
+		"CF_RECORD_NAME": ["a.example.com", "b.example.com"],
+		"CF_TTL": 300,
+		"CF_PROXIED": true,
+		"CF_ZONE_ID": " zone-id ",
+		"CF_EXTRA_FIELDS": {"settings": {"ipv4_only": true}},
+		"CF_ZONE_NAME": null
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		envRecordName:  "a.example.com,b.example.com",
+		envTTL:         "300",
+		envProxied:     "true",
+		envZoneID:      "zone-id",
+		envExtraFields: `{"settings": {"ipv4_only": true}}`,
+		envZoneName:    "",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("got %v, want %v", values, want)
+	}
+
+	for _, data := range []string{`[]`, `{"ttl": 300}`, `{"CF_AUTH_KEY_FILE": "/run/key"}`, `{"CF_RECORD_NAME": [["a"]]}`, `{"CF_CONFIG_JSON": "{}"}`} {
+		if _, err := parseConfigJSON(data); err == nil || !strings.Contains(err.Error(), envConfigJSON) {
+			t.Errorf("%s: expected an error naming %s, got %v", data, envConfigJSON, err)
+		}
+	}
+}
+
+func TestParseConfigJSONRejectsUnknownKey(t *testing.T) {
+	_, err := parseConfigJSON(`{"CF_RECORD_NAMS": "home.example.com"}`)
+	if err == nil || !strings.Contains(err.Error(), `unsupported key "CF_RECORD_NAMS"`) {
+		t.Fatalf("expected a misspelled key to be rejected, got %v", err)
+	}
+}
+
+// TestConfigJSONKeysCoverEveryVariable keeps configJSONKeys in step with the
+// env* constants declared in main.go.
+func TestConfigJSONKeysCoverEveryVariable(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parse main.go: %v", err)
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			values := spec.(*ast.ValueSpec)
+			for i, name := range values.Names {
+				if i >= len(values.Values) {
+					continue
+				}
+				lit, ok := values.Values[i].(*ast.BasicLit)
+				if !ok || !strings.HasPrefix(name.Name, "env") || lit.Kind != token.STRING {
+					continue
+				}
+				value, _ := strconv.Unquote(lit.Value)
+				if strings.HasPrefix(value, "CF_") && value != envConfigJSON && !slices.Contains(configJSONKeys, value) {
+					t.Errorf("%s (%s) is missing from configJSONKeys", name.Name, value)
+				}
+			}
+		}
+	}
+}
+
+func TestLoadConfigFromConfigJSON(t *testing.T) {
+	t.Setenv(envConfigJSON, `{"CF_AUTH_KEY": "token-value", "CF_ZONE_ID": "zone-id", "CF_RECORD_NAME": ["a.example.com", "b.example.com"], "CF_TTL": 300}`)
+	t.Setenv(envTTL, "600")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.AuthKey != "token-value" || !reflect.DeepEqual(cfg.RecordNames, []string{"a.example.com", "b.example.com"}) {
+		t.Fatalf("expected values from %s, got %+v", envConfigJSON, cfg)
+	}
+	if cfg.TTL != 600 {
+		t.Fatalf("expected %s to override the JSON value, got %d", envTTL, cfg.TTL)
+	}
+
+	t.Setenv(envConfigJSON, `{"CF_AUTH_KEY": "token-value", "CF_ZONE_ID": "zone-id", "CF_RECORD_NAME": "a.example.com", "CF_TTL": "soon"}`)
+	t.Setenv(envTTL, "")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envTTL) {
+		t.Fatalf("expected the JSON value to be validated, got %v", err)
+	}
+}
+
+func TestLoadConfigFromConfigJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"CF_AUTH_KEY": "token-value", "CF_ZONE_ID": "zone-id", "CF_RECORD_NAME": "a.example.com"}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv(envConfigJSON+fileEnvSuffix, path)
+
+	cfg, err := loadConfig()
+	if err != nil || cfg.RecordName != "a.example.com" {
+		t.Fatalf("expected the config read from the file, got %+v, %v", cfg, err)
+	}
+
+	t.Setenv(envConfigJSON+fileEnvSuffix, "")
+	t.Setenv(envConfigJSON, `{"CF_AUTH_KEY": }`)
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envConfigJSON) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	tiebreakError            = "error"
+	tiebreakPreferFirst      = "prefer-first"
+	tiebreakPreferMostRecent = "prefer-most-recent"
+)
+
+// ipVote counts how many services reported an address. last is the position
+// of its latest answer among all responses.
+type ipVote struct {
+	IP    string
+	Votes int
+	last  int
+}
+
+// discoverConsensus queries every service and returns the address reported
+// by at least threshold of them, breaking ties between equally voted
+// addresses according to tiebreak. When no service reports an address of
+// family but one reports the other family, the error wraps a
+// *familyMismatchError for CF_MISMATCH.
+func discoverConsensus(ctx context.Context, client *http.Client, services []string, family string, retries, threshold int, tiebreak string, observe queryObserver) (string, error) {
+	var votes []*ipVote
+	var mismatch *familyMismatchError
+	index := make(map[string]*ipVote)
+
+	var answers int
+	for _, svc := range services {
+		ip, err := queryIPServiceRetrying(ctx, client, svc, family, retries)
+		if ctx.Err() == nil {
+			observe.record(svc, err)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			errors.As(err, &mismatch)
+			continue
+		}
+
+		vote, ok := index[ip]
+		if !ok {
+			vote = &ipVote{IP: ip}
+			index[ip] = vote
+			votes = append(votes, vote)
+		}
+		vote.Votes++
+		vote.last = answers
+		answers++
+	}
+
+	if answers == 0 && mismatch != nil {
+		return "", fmt.Errorf("no service reported an %s address: %w", family, mismatch)
+	}
+	return decideConsensus(votes, threshold, tiebreak)
+}
+
+// decideConsensus picks the winning address from votes, which are ordered by
+// first appearance. Only addresses meeting threshold are eligible; a tie
+// among the best eligible addresses is resolved by tiebreak.
+func decideConsensus(votes []*ipVote, threshold int, tiebreak string) (string, error) {
+	var best []*ipVote
+	for _, vote := range votes {
+		switch {
+		case vote.Votes < threshold:
+		case len(best) == 0 || vote.Votes > best[0].Votes:
+			best = []*ipVote{vote}
+		case vote.Votes == best[0].Votes:
+			best = append(best, vote)
+		}
+	}
+
+	switch {
+	case len(best) == 0:
+		return "", fmt.Errorf("no address was reported by at least %d service(s): %s", threshold, formatVotes(votes))
+	case len(best) == 1:
+		return best[0].IP, nil
+	}
+
+	switch tiebreak {
+	case tiebreakPreferFirst:
+		return best[0].IP, nil
+	case tiebreakPreferMostRecent:
+		winner := best[0]
+		for _, vote := range best[1:] {
+			if vote.last > winner.last {
+				winner = vote
+			}
+		}
+		return winner.IP, nil
+	default:
+		return "", errors.New("services disagree with equal votes: " + formatVotes(best))
+	}
+}
+
+func formatVotes(votes []*ipVote) string {
+	if len(votes) == 0 {
+		return "no answers"
+	}
+	parts := make([]string, 0, len(votes))
+	for _, vote := range votes {
+		parts = append(parts, fmt.Sprintf("%s=%d", vote.IP, vote.Votes))
+	}
+	return strings.Join(parts, ", ")
+}
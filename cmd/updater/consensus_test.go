@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecideConsensus(t *testing.T) {
+	votes := func() []*ipVote {
+		return []*ipVote{
+			{IP: "203.0.113.10", Votes: 2, last: 2},
+			{IP: "198.51.100.1", Votes: 2, last: 3},
+			{IP: "192.0.2.1", Votes: 1, last: 4},
+		}
+	}
+
+	if _, err := decideConsensus(votes(), 2, tiebreakError); err == nil {
+		t.Fatalf("expected tie to be an error by default")
+	}
+	if ip, err := decideConsensus(votes(), 2, tiebreakPreferFirst); err != nil || ip != "203.0.113.10" {
+		t.Fatalf("expected first address, got %s %v", ip, err)
+	}
+	if ip, err := decideConsensus(votes(), 2, tiebreakPreferMostRecent); err != nil || ip != "198.51.100.1" {
+		t.Fatalf("expected most recent address, got %s %v", ip, err)
+	}
+	if _, err := decideConsensus(votes(), 3, tiebreakPreferFirst); err == nil {
+		t.Fatalf("expected no consensus below the threshold")
+	}
+
+	single := []*ipVote{{IP: "203.0.113.10", Votes: 3}, {IP: "198.51.100.1", Votes: 1}}
+	if ip, err := decideConsensus(single, 2, tiebreakError); err != nil || ip != "203.0.113.10" {
+		t.Fatalf("expected majority address, got %s %v", ip, err)
+	}
+}
+
+func TestDiscoverConsensus(t *testing.T) {
+	server := func(ip string) string {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(ip))
+		}))
+		t.Cleanup(s.Close)
+		return s.URL
+	}
+
+	services := []string{server("198.51.100.1"), server("203.0.113.10"), server("garbage"), server("203.0.113.10")}
+	ip, err := discoverConsensus(context.Background(), &http.Client{}, services, familyIPv4, 0, 2, tiebreakError, nil)
+	if err != nil || ip != "203.0.113.10" {
+		t.Fatalf("expected consensus on 203.0.113.10, got %s %v", ip, err)
+	}
+}
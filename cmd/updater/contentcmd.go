@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runContentCommand runs CF_CONTENT_COMMAND through /bin/sh and returns its
+// trimmed stdout. DDNS_RECORD_TYPE tells the command which record type the
+// content is for. Anything written to stderr is logged.
+func runContentCommand(ctx context.Context, command, recordType string) (string, error) {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), "DDNS_RECORD_TYPE="+recordType)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	scanner := bufio.NewScanner(&stderr)
+	for scanner.Scan() {
+		log.Printf("content command: %s", scanner.Text())
+	}
+	if err != nil {
+		return "", fmt.Errorf("content command failed: %w", err)
+	}
+
+	content := strings.TrimSpace(stdout.String())
+	if content == "" {
+		return "", errors.New("content command printed nothing")
+	}
+	return content, nil
+}
+
+// parseSRVContent parses SRV data in zone-file order, "priority weight port
+// target", as printed by a content command.
+func parseSRVContent(content string) (SRVData, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 4 {
+		return SRVData{}, fmt.Errorf("invalid SRV content %q (must be 'priority weight port target')", content)
+	}
+
+	data := SRVData{Target: fields[3]}
+	if err := parseSRVData(&data, fields[0], fields[1], fields[2]); err != nil {
+		return SRVData{}, fmt.Errorf("invalid SRV content %q: %w", content, err)
+	}
+	return data, nil
+}
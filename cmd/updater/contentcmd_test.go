@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunContentCommand(t *testing.T) {
+	got, err := runContentCommand(context.Background(), `echo "  $DDNS_RECORD_TYPE content  "`, "A")
+	if err != nil || got != "A content" {
+		t.Fatalf("expected trimmed output, got %q, %v", got, err)
+	}
+
+	if _, err := runContentCommand(context.Background(), "echo oops >&2; exit 3", "A"); err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("expected the exit status in the error, got %v", err)
+	}
+	if _, err := runContentCommand(context.Background(), "true", "A"); err == nil || !strings.Contains(err.Error(), "printed nothing") {
+		t.Fatalf("expected empty output to fail, got %v", err)
+	}
+}
+
+func TestParseSRVContent(t *testing.T) {
+	got, err := parseSRVContent("10 5 25565 Game.Example.com.")
+	want := SRVData{Priority: 10, Weight: 5, Port: 25565, Target: "game.example.com"}
+	if err != nil || got != want {
+		t.Fatalf("got %+v, %v; want %+v", got, err, want)
+	}
+
+	for _, content := range []string{"10 5 25565", "10 5 0 game.example.com", "a b c d"} {
+		if _, err := parseSRVContent(content); err == nil {
+			t.Errorf("expected %q to be rejected", content)
+		}
+	}
+}
+
+func TestUpdaterContentCommand(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"home.example.com"}, ContentCommand: "echo 203.0.113.77"}
+	u := newTestUpdater(t, cfg, api, "192.0.2.1")
+
+	results, err := u.run(context.Background())
+	if err != nil || results[0].NewIP != "203.0.113.77" {
+		t.Fatalf("expected the command's address, got %+v, %v", results, err)
+	}
+
+	u.cfg.ContentCommand = "echo not-an-ip"
+	if _, err := u.run(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid IP") {
+		t.Fatalf("expected invalid output to fail the run, got %v", err)
+	}
+	if api.updates != 1 {
+		t.Fatalf("expected no update from invalid output, got %d update(s)", api.updates)
+	}
+}
+
+func TestUpdaterContentCommandSRV(t *testing.T) {
+	api := newMockCloudflare()
+	api.seed = true
+	cfg := Config{
+		RecordNames:    []string{"_minecraft._tcp.example.com"},
+		RecordType:     "SRV",
+		ContentCommand: "echo 10 5 25565 game.example.com",
+	}
+	u := newTestUpdater(t, cfg, api, "")
+
+	results, err := u.run(context.Background())
+	if err != nil || results[0].Action != actionChanged || results[0].NewIP != "10 5 25565 game.example.com" {
+		t.Fatalf("expected the command's SRV data to be published, got %+v, %v", results, err)
+	}
+}
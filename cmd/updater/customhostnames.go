@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/custom_hostnames"
+	"github.com/cloudflare/cloudflare-go/v2/option"
+)
+
+// customHostnameOrigins maps the Cloudflare for SaaS custom hostnames in
+// cfg.RecordNames to the DNS records they are served from, for
+// CF_CUSTOM_HOSTNAMES. A custom hostname cannot hold an address itself: its
+// traffic goes to its custom origin server, or to the zone's fallback origin
+// when it has none, and that record is the one to update. Hostnames sharing
+// an origin yield it once.
+func customHostnameOrigins(ctx context.Context, client *cloudflare.Client, cfg Config) ([]string, error) {
+	var origins []string
+	var fallback string
+	for _, name := range cfg.RecordNames {
+		origin, err := customOriginServer(ctx, client, cfg.ZoneID, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up custom hostname %s: %w", name, err)
+		}
+		if origin == "" {
+			if fallback == "" {
+				if fallback, err = fallbackOrigin(ctx, client, cfg.ZoneID); err != nil {
+					return nil, fmt.Errorf("failed to look up the fallback origin for custom hostname %s: %w", name, err)
+				}
+			}
+			origin = fallback
+		}
+		log.Printf("custom hostname %s is served from origin %s", name, origin)
+		if !slices.Contains(origins, origin) {
+			origins = append(origins, origin)
+		}
+	}
+	return origins, nil
+}
+
+// customOriginServer returns the custom origin server of the custom hostname
+// name in zoneID, or "" when it uses the fallback origin.
+func customOriginServer(ctx context.Context, client *cloudflare.Client, zoneID, name string) (string, error) {
+	params := custom_hostnames.CustomHostnameListParams{
+		ZoneID:   cloudflare.F(zoneID),
+		Hostname: cloudflare.F(name),
+	}
+
+	var resp *http.Response
+	page, err := client.CustomHostnames.List(ctx, params, option.WithResponseInto(&resp))
+	if err != nil {
+		return "", withRayID(err, resp)
+	}
+	if err := checkSuccess(page.JSON.RawJSON()); err != nil {
+		return "", withRayID(err, resp)
+	}
+	for _, hostname := range page.Result {
+		if normalizeName(hostname.Hostname, "") == normalizeName(name, "") {
+			return hostname.CustomOriginServer, nil
+		}
+	}
+	return "", errRecordNotFound
+}
+
+// fallbackOrigin returns the fallback origin of the Cloudflare for SaaS zone
+// zoneID.
+func fallbackOrigin(ctx context.Context, client *cloudflare.Client, zoneID string) (string, error) {
+	params := custom_hostnames.FallbackOriginGetParams{ZoneID: cloudflare.F(zoneID)}
+
+	var resp *http.Response
+	var envelope custom_hostnames.FallbackOriginGetResponseEnvelope
+	if _, err := client.CustomHostnames.FallbackOrigin.Get(ctx, params, option.WithResponseBodyInto(&envelope), option.WithResponseInto(&resp)); err != nil {
+		return "", withRayID(err, resp)
+	}
+	if err := checkSuccess(envelope.JSON.RawJSON()); err != nil {
+		return "", withRayID(err, resp)
+	}
+
+	// The SDK leaves the result untyped.
+	var result struct {
+		Origin string `json:"origin"`
+	}
+	if err := json.Unmarshal([]byte(envelope.JSON.Result.Raw()), &result); err != nil || result.Origin == "" {
+		return "", withRayID(fmt.Errorf("no fallback origin is set"), resp)
+	}
+	return result.Origin, nil
+}
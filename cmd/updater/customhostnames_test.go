@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestUpdaterCustomHostnames(t *testing.T) {
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "origin.saas.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "fallback.saas.example.com", "198.51.100.1"),
+	)
+	api.customHostnames = map[string]string{
+		"shop.customer.test":  "origin.saas.example.com",
+		"store.customer.test": "origin.saas.example.com",
+		"blog.customer.test":  "",
+	}
+	api.fallbackOrigin = "fallback.saas.example.com"
+
+	cfg := Config{RecordNames: []string{"shop.customer.test", "store.customer.test", "blog.customer.test"}, CustomHostnames: true}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 || results[0].Record != "origin.saas.example.com" || results[1].Record != "fallback.saas.example.com" {
+		t.Fatalf("expected each origin updated once, got %+v", results)
+	}
+	if api.updates != 2 || api.records["origin.saas.example.com"]["content"] != "203.0.113.10" {
+		t.Fatalf("expected both origins updated, got %d update(s)", api.updates)
+	}
+}
+
+func TestUpdaterCustomHostnameErrors(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "origin.saas.example.com", "198.51.100.1"))
+	api.customHostnames = map[string]string{"blog.customer.test": ""}
+
+	cases := map[string]string{
+		"missing.customer.test": "custom hostname missing.customer.test",
+		"blog.customer.test":    "fallback origin",
+	}
+	for name, want := range cases {
+		u := newTestUpdater(t, Config{RecordNames: []string{name}, CustomHostnames: true}, api, "203.0.113.10")
+		if _, err := u.run(context.Background()); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected error mentioning %q, got %v", name, want, err)
+		}
+	}
+	if api.updates != 0 {
+		t.Fatalf("expected no updates, got %d", api.updates)
+	}
+}
+
+func TestLoadConfigCustomHostnamesConflict(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "shop.customer.test")
+	t.Setenv(envCustomHostnames, "true")
+	t.Setenv(envNameMatch, nameMatchRelative)
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envCustomHostnames) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
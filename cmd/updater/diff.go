@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+// fieldChange describes one record field as it is now and as it would be
+// after an update.
+type fieldChange struct {
+	Field string
+	Old   string
+	New   string
+	// Ignored, when set, explains why a difference does not count as a change.
+	Ignored string
+}
+
+// Changed reports whether the difference in the field warrants an update.
+func (c fieldChange) Changed() bool {
+	return c.Ignored == "" && c.Old != c.New
+}
+
+// String renders the change as "field: old -> new" or "field: value (unchanged)".
+func (c fieldChange) String() string {
+	if c.Old == c.New {
+		return fmt.Sprintf("%s: %s (unchanged)", c.Field, c.Old)
+	}
+	if c.Ignored != "" {
+		return fmt.Sprintf("%s: %s -> %s (ignored: %s)", c.Field, c.Old, c.New, c.Ignored)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Field, c.Old, c.New)
+}
+
+// diffRecord compares every field the updater manages on record against the
+// desired state. current is the record's extracted content and content the
+// value that would be written. The TTL of a record that stays proxied is not
+// compared.
+func diffRecord(record dns.Record, cfg Config, current, content string) []fieldChange {
+	contentChange := fieldChange{Field: "content", Old: current, New: content}
+	if cfg.RecordType == "AAAA" && cfg.IPv6MatchPrefix > 0 && sameIPv6Prefix(current, content, cfg.IPv6MatchPrefix) {
+		contentChange.Ignored = fmt.Sprintf("same /%d prefix", cfg.IPv6MatchPrefix)
+	}
+
+	ttlChange := fieldChange{Field: "ttl", Old: strconv.Itoa(int(record.TTL)), New: strconv.Itoa(cfg.TTL)}
+	if record.Proxied && cfg.Proxied {
+		// Cloudflare keeps proxied records at TTL 1 (auto) whatever is sent.
+		ttlChange.Ignored = "proxied records use automatic TTL"
+	}
+
+	changes := []fieldChange{contentChange, ttlChange}
+
+	if isAddressType(cfg.RecordType) {
+		changes = append(changes, fieldChange{
+			Field: "proxied",
+			Old:   strconv.FormatBool(record.Proxied),
+			New:   strconv.FormatBool(cfg.Proxied),
+		})
+	}
+
+	if cfg.RecordMode == recordModeSync {
+		// The audit comment changes with every write, so only the managed
+		// marker is compared.
+		want := managedComment
+		if isManaged(record.Comment) {
+			want = record.Comment
+		}
+		changes = append(changes, fieldChange{Field: "comment", Old: record.Comment, New: want})
+	}
+
+	if cfg.RecordTag != "" {
+		tags := recordTags(record)
+		changes = append(changes, fieldChange{
+			Field: "tags",
+			Old:   strings.Join(tags, ","),
+			New:   strings.Join(withTag(tags, cfg.RecordTag), ","),
+		})
+	}
+
+	return changes
+}
+
+func hasChanges(changes []fieldChange) bool {
+	for _, change := range changes {
+		if change.Changed() {
+			return true
+		}
+	}
+	return false
+}
+
+// diff implements the diff subcommand: it runs the updater, which must be
+// configured for a dry run, prints every managed field of each record next
+// to its desired value and fails when any record differs from the
+// configuration, is missing, or would be pruned. Since nothing is changed,
+// it can detect dashboard edits in CI.
+func (u *updater) diff(ctx context.Context) error {
+	results, err := u.run(ctx)
+	drift := writeDiff(u.out, results)
+	if err != nil {
+		return err
+	}
+	if drift > 0 {
+		return fmt.Errorf("%d of %d record(s) differ from the configuration", drift, len(results))
+	}
+	return nil
+}
+
+// writeDiff prints the field comparison for every result and returns the
+// number of records that differ from the configuration.
+func writeDiff(w io.Writer, results []Result) int {
+	var drift int
+	for _, r := range results {
+		fmt.Fprintf(w, "%s (%s):\n", orDash(r.Record), orDash(r.Type))
+		switch {
+		case r.Action == actionError:
+			fmt.Fprintf(w, "  error: %v\n", r.Err)
+		case r.Action == actionSkipped:
+			fmt.Fprintf(w, "  skipped\n")
+		case r.Action == actionDryRun && r.Changes == nil:
+			drift++
+			if r.NewIP == "" {
+				fmt.Fprintf(w, "  stale record, would be deleted\n")
+			} else {
+				fmt.Fprintf(w, "  missing, would be created with %s\n", r.NewIP)
+			}
+		default:
+			if r.Action == actionDryRun {
+				drift++
+			}
+			for _, change := range r.Changes {
+				fmt.Fprintf(w, "  %s\n", change)
+			}
+		}
+	}
+	return drift
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+func TestDiffRecord(t *testing.T) {
+	var record dns.Record
+	payload := []byte(`{"id":"record-id","type":"A","name":"example.com","content":"1.2.3.4","proxied":false,"tags":[],"ttl":300}`)
+	if err := json.Unmarshal(payload, &record); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+
+	cfg := Config{RecordType: "A", TTL: 300, Proxied: true}
+	changes := diffRecord(record, cfg, "1.2.3.4", "5.6.7.8")
+
+	expected := []string{
+		"content: 1.2.3.4 -> 5.6.7.8",
+		"ttl: 300 (unchanged)",
+		"proxied: false -> true",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+	for i, change := range changes {
+		if change.String() != expected[i] {
+			t.Fatalf("change %d: expected %q, got %q", i, expected[i], change.String())
+		}
+	}
+	if !hasChanges(changes) {
+		t.Fatalf("expected changes to be detected")
+	}
+
+	cfg.Proxied = false
+	if hasChanges(diffRecord(record, cfg, "1.2.3.4", "1.2.3.4")) {
+		t.Fatalf("expected no changes for identical state")
+	}
+}
+
+func TestDiffRecordIPv6Prefix(t *testing.T) {
+	var record dns.Record
+	payload := []byte(`{"id":"record-id","type":"AAAA","name":"example.com","content":"2001:db8:1:2::aaaa","proxied":false,"tags":[],"ttl":300}`)
+	if err := json.Unmarshal(payload, &record); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+
+	cfg := Config{RecordType: "AAAA", TTL: 300, IPv6MatchPrefix: 64}
+	changes := diffRecord(record, cfg, "2001:db8:1:2::aaaa", "2001:db8:1:2::bbbb")
+	if hasChanges(changes) {
+		t.Fatalf("expected suffix rotation to be ignored, got %v", changes)
+	}
+	if got := changes[0].String(); got != "content: 2001:db8:1:2::aaaa -> 2001:db8:1:2::bbbb (ignored: same /64 prefix)" {
+		t.Fatalf("unexpected rendering %q", got)
+	}
+
+	if !hasChanges(diffRecord(record, cfg, "2001:db8:1:2::aaaa", "2001:db8:1:3::aaaa")) {
+		t.Fatalf("expected prefix change to be detected")
+	}
+}
+
+func TestSyncRecordProxiedAutoTTL(t *testing.T) {
+	record := aRecordFixture("id-1", "home.example.com", "203.0.113.10")
+	record["proxied"], record["ttl"] = true, 1
+	api := newMockCloudflare(record)
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}, TTL: 300, Proxied: true}, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil || len(results) != 1 || results[0].Action != actionUnchanged {
+		t.Fatalf("expected the proxied record to be up to date, got %+v, %v", results, err)
+	}
+	if api.updates != 0 {
+		t.Fatalf("expected no update for the automatic TTL, got %d", api.updates)
+	}
+	if got := results[0].Changes[1].String(); got != "ttl: 1 -> 300 (ignored: proxied records use automatic TTL)" {
+		t.Fatalf("unexpected rendering %q", got)
+	}
+}
+
+func TestUpdaterDiff(t *testing.T) {
+	record := aRecordFixture("id-1", "example.com", "203.0.113.10")
+	api := newMockCloudflare(record)
+	cfg := Config{RecordNames: []string{"example.com"}, TTL: 300, DryRun: true}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	var out bytes.Buffer
+	u.out = &out
+
+	if err := u.diff(context.Background()); err != nil {
+		t.Fatalf("expected no drift, got %v", err)
+	}
+	if !strings.Contains(out.String(), "content: 203.0.113.10 (unchanged)") {
+		t.Fatalf("unexpected diff output %q", out.String())
+	}
+
+	record["ttl"] = 60
+	out.Reset()
+	if err := u.diff(context.Background()); err == nil {
+		t.Fatalf("expected drift to fail")
+	}
+	if !strings.Contains(out.String(), "ttl: 60 -> 300") {
+		t.Fatalf("unexpected diff output %q", out.String())
+	}
+	if api.updates != 0 {
+		t.Fatalf("expected diff to change nothing, got %d update(s)", api.updates)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdaterDiscoveryRetries(t *testing.T) {
+	var calls int
+	outage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("203.0.113.10"))
+	}))
+	t.Cleanup(outage.Close)
+
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"home.example.com"}, DiscoveryRetries: 2, DiscoveryRetryDelay: time.Millisecond}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.cfg.IPServices = []string{outage.URL, outage.URL}
+	u.clock = systemClock
+
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected the third discovery phase to succeed, got %v", err)
+	}
+	if calls != 5 || api.updates != 1 {
+		t.Fatalf("expected two failed phases before success, got %d call(s) and %d update(s)", calls, api.updates)
+	}
+
+	u.cfg.DiscoveryRetries = 1
+	u.cfg.IPServices = []string{outage.URL}
+	calls = -10 // keep the service failing for the rest of the test
+	_, err := u.run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to determine public IP") {
+		t.Fatalf("expected discovery to fail once retries run out, got %v", err)
+	}
+	if calls != -8 {
+		t.Fatalf("expected one query per phase, got %d call(s)", calls+10)
+	}
+}
+
+func TestLoadConfigDiscoveryRetries(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+
+	t.Setenv(envDiscoveryRetries, "3")
+	t.Setenv(envDiscoveryDelay, "2s")
+	cfg, err := loadConfig()
+	if err != nil || cfg.DiscoveryRetries != 3 || cfg.DiscoveryRetryDelay != 2*time.Second {
+		t.Fatalf("unexpected config %d, %s, %v", cfg.DiscoveryRetries, cfg.DiscoveryRetryDelay, err)
+	}
+
+	t.Setenv(envDiscoveryRetries, "")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envDiscoveryRetries) {
+		t.Fatalf("expected the delay to require retries, got %v", err)
+	}
+
+	t.Setenv(envDiscoveryRetries, "11")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envDiscoveryRetries) {
+		t.Fatalf("expected an out-of-range error, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// errDiscoveryTimeout is the cause of a discovery pass cut short by
+// CF_DISCOVERY_TIMEOUT.
+var errDiscoveryTimeout = errors.New("discovery timeout reached")
+
+// withDiscoveryTimeout bounds ctx by timeout for one pass over the IP
+// services. A zero timeout leaves ctx unbounded. The cause of an expired
+// deadline is errDiscoveryTimeout, which tells it apart from CF_RUN_TIMEOUT
+// or a shutdown.
+func withDiscoveryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, timeout, errDiscoveryTimeout)
+}
+
+// logSkippedServices reports the service that was being asked when the
+// discovery deadline passed and the ones that were never asked.
+func logSkippedServices(current string, remaining []string) {
+	if len(remaining) == 0 {
+		log.Printf("%s reached while querying %s; no services left to try", envDiscoveryTimeout, current)
+		return
+	}
+	log.Printf("%s reached while querying %s; skipped %s", envDiscoveryTimeout, current, strings.Join(remaining, ", "))
+}
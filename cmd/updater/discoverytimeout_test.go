@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiscoverIPTimeoutSkipsRemainingServices(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+	var laterCalls atomic.Int32
+	later := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		laterCalls.Add(1)
+		fmt.Fprint(w, "203.0.113.10")
+	}))
+	defer later.Close()
+
+	var logs bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(prev) })
+
+	ctx, cancel := withDiscoveryTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := discoverIP(ctx, &http.Client{}, []string{slow.URL, later.URL}, familyIPv4, 0, nil)
+	if !errors.Is(err, errDiscoveryTimeout) {
+		t.Fatalf("expected errDiscoveryTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("discovery took %s despite the deadline", elapsed)
+	}
+	if n := laterCalls.Load(); n != 0 {
+		t.Fatalf("expected the remaining service to be skipped, got %d call(s)", n)
+	}
+	if !strings.Contains(logs.String(), "skipped "+later.URL) {
+		t.Fatalf("expected the skipped service to be logged, got %q", logs.String())
+	}
+}
+
+func TestDiscoverIPTimeoutAllowsFastAnswer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "203.0.113.10")
+	}))
+	defer server.Close()
+
+	ctx, cancel := withDiscoveryTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ip, _, err := discoverIP(ctx, &http.Client{}, []string{server.URL}, familyIPv4, 0, nil)
+	if err != nil || ip != "203.0.113.10" {
+		t.Fatalf("unexpected result %q, %v", ip, err)
+	}
+}
+
+func TestDiscoverIPParentCancelIsNotDiscoveryTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	parent, cancelParent := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelParent()
+	ctx, cancel := withDiscoveryTimeout(parent, time.Minute)
+	defer cancel()
+	_, _, err := discoverIP(ctx, &http.Client{}, []string{server.URL}, familyIPv4, 0, nil)
+	if err == nil || errors.Is(err, errDiscoveryTimeout) {
+		t.Fatalf("expected the parent's error, got %v", err)
+	}
+}
+
+func TestLoadConfigDiscoveryTimeout(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+
+	t.Setenv(envDiscoveryTimeout, "10s")
+	cfg, err := loadConfig()
+	if err != nil || cfg.DiscoveryTimeout != 10*time.Second {
+		t.Fatalf("unexpected config %s, %v", cfg.DiscoveryTimeout, err)
+	}
+
+	t.Setenv(envDiscoveryTimeout, "0s")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envDiscoveryTimeout) {
+		t.Fatalf("expected an invalid value error, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ipSourceDNSRecord publishes the address another hostname resolves to, for
+// hosts that follow a source of truth maintained elsewhere.
+const ipSourceDNSRecord = "dns-record"
+
+// resolveSourceRecord resolves host with lookup and returns its first
+// address of family.
+func resolveSourceRecord(ctx context.Context, lookup lookupFunc, host, family string) (string, error) {
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		if (ip.To4() != nil) == (family == familyIPv4) {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no %s address (resolved to %s)", host, family, orDash(strings.Join(addrs, ", ")))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveSourceRecord(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		return []string{"2001:db8::10", "203.0.113.10"}, nil
+	}
+
+	if ip, err := resolveSourceRecord(context.Background(), lookup, "origin.example.net", familyIPv4); err != nil || ip != "203.0.113.10" {
+		t.Fatalf("expected IPv4 address, got %q, %v", ip, err)
+	}
+	if ip, err := resolveSourceRecord(context.Background(), lookup, "origin.example.net", familyIPv6); err != nil || ip != "2001:db8::10" {
+		t.Fatalf("expected IPv6 address, got %q, %v", ip, err)
+	}
+
+	v6only := func(ctx context.Context, host string) ([]string, error) { return []string{"2001:db8::10"}, nil }
+	if _, err := resolveSourceRecord(context.Background(), v6only, "origin.example.net", familyIPv4); err == nil || !strings.Contains(err.Error(), "no IPv4 address") {
+		t.Fatalf("expected missing family to fail, got %v", err)
+	}
+}
+
+func TestUpdaterFollowsSourceRecord(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"home.example.com"}, IPSource: ipSourceDNSRecord, IPSourceRecord: "origin.example.net"}
+	u := newTestUpdater(t, cfg, api, "192.0.2.1")
+	u.sourceLookup = func(ctx context.Context, host string) ([]string, error) {
+		if host != "origin.example.net" {
+			t.Errorf("unexpected lookup of %s", host)
+		}
+		return []string{"203.0.113.10"}, nil
+	}
+
+	results, err := u.run(context.Background())
+	if err != nil || results[0].NewIP != "203.0.113.10" {
+		t.Fatalf("expected the source record's address, got %+v, %v", results, err)
+	}
+
+	u.sourceLookup = func(ctx context.Context, host string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+	if _, err := u.run(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to resolve origin.example.net") {
+		t.Fatalf("expected resolution failure to fail the run, got %v", err)
+	}
+	if api.updates != 1 {
+		t.Fatalf("expected no update after a failed resolution, got %d update(s)", api.updates)
+	}
+}
+
+func TestSourceRecordUsesSystemResolver(t *testing.T) {
+	cfg := Config{
+		AuthMethod: "token", AuthKey: "token-value", ZoneID: "zone-id", RecordNames: []string{"home.example.com"},
+		RecordType: "A", IPSource: ipSourceDNSRecord, IPSourceRecord: "localhost", VerifyResolver: "192.0.2.1:53",
+	}
+	u, err := newUpdater(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The resolver in CF_VERIFY_RESOLVER is unreachable, but the source
+	// record is resolved from the hosts file.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ip, err := resolveSourceRecord(ctx, u.sourceLookup, cfg.IPSourceRecord, familyIPv4)
+	if err != nil || ip != "127.0.0.1" {
+		t.Fatalf("expected localhost through the system resolver, got %q, %v", ip, err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// discoveryPlan is the order in which discover looks for the address of one
+// record type. discover follows it and -explain-discovery prints it.
+type discoveryPlan struct {
+	Family string
+	// Command, when set, is the only source: the address is what it prints.
+	Command string
+	// SourceRecord, when set, is the only source: the address is that of
+	// another hostname.
+	SourceRecord string
+	// Interface, when set, is the only source: the address is read locally.
+	Interface string
+	// UPnP asks the gateway before falling back to Services.
+	UPnP bool
+	// Services are the HTTP services in the order they are asked.
+	Services []string
+	// Sticky is set when Services starts with the last successful service.
+	Sticky bool
+	// Shuffle asks Services in a random order instead.
+	Shuffle bool
+	// Consensus, when non-zero, asks every service and needs that many to
+	// agree.
+	Consensus int
+}
+
+// planDiscovery works out the discovery order for cfg.RecordType, which must
+// be A or AAAA.
+func planDiscovery(cfg Config, state State) discoveryPlan {
+	if cfg.ContentCommand != "" {
+		family := familyIPv4
+		if cfg.RecordType == "AAAA" {
+			family = familyIPv6
+		}
+		return discoveryPlan{Family: family, Command: cfg.ContentCommand}
+	}
+	if cfg.IPSource == ipSourceDNSRecord {
+		family := familyIPv4
+		if cfg.RecordType == "AAAA" {
+			family = familyIPv6
+		}
+		return discoveryPlan{Family: family, SourceRecord: cfg.IPSourceRecord}
+	}
+	if cfg.RecordType == "AAAA" {
+		if cfg.IPv6Interface != "" {
+			return discoveryPlan{Family: familyIPv6, Interface: cfg.IPv6Interface}
+		}
+		return discoveryPlan{Family: familyIPv6, Services: cfg.IPv6Services, Shuffle: cfg.IPShuffle && cfg.IPConsensus == 0, Consensus: cfg.IPConsensus}
+	}
+
+	plan := discoveryPlan{
+		Family:    familyIPv4,
+		UPnP:      cfg.IPSource == ipSourceUPnP,
+		Services:  cfg.IPServices,
+		Shuffle:   cfg.IPShuffle && cfg.IPConsensus == 0,
+		Consensus: cfg.IPConsensus,
+	}
+	// Stickiness remembers a single service, which is kept for IPv4, and
+	// has no effect when every service is asked anyway.
+	if cfg.IPSticky && cfg.IPConsensus == 0 {
+		plan.Sticky = true
+		plan.Services = preferService(plan.Services, state.LastService)
+	}
+	return plan
+}
+
+// explainDiscovery implements -explain-discovery: it prints the discovery
+// order for every record type the configuration publishes.
+func explainDiscovery(w io.Writer, cfg Config, state State) {
+	for _, group := range groupByType(cfg) {
+		recordTypes := []string{group.RecordType}
+		switch group.RecordType {
+		case "SRV":
+			if cfg.ContentCommand != "" {
+				fmt.Fprintf(w, "SRV records (%d): data from command %s\n", len(group.RecordNames), cfg.ContentCommand)
+				continue
+			}
+			fmt.Fprintf(w, "SRV records (%d): no discovery, target %s\n", len(group.RecordNames), group.SRV)
+			continue
+		case "CAA":
+			fmt.Fprintf(w, "CAA records (%d): no discovery, data %s\n", len(group.RecordNames), group.CAA)
+			continue
+		case recordTypeAuto:
+			recordTypes = []string{"A", "AAAA"}
+			if cfg.AutoPrefer == autoPreferIPv6 {
+				recordTypes = []string{"AAAA", "A"}
+			}
+			fmt.Fprintf(w, "AUTO records (%d): first family that succeeds, in this order\n", len(group.RecordNames))
+		}
+
+		for _, recordType := range recordTypes {
+			typed := group
+			typed.RecordType = recordType
+			plan := planDiscovery(typed, state)
+			fmt.Fprintf(w, "%s records (%d), %s:\n", recordType, len(group.RecordNames), plan.Family)
+			writePlan(w, plan, state)
+		}
+	}
+}
+
+func writePlan(w io.Writer, plan discoveryPlan, state State) {
+	if plan.Command != "" {
+		fmt.Fprintf(w, "  1. command %s\n", plan.Command)
+		return
+	}
+	if plan.SourceRecord != "" {
+		fmt.Fprintf(w, "  1. DNS record %s\n", plan.SourceRecord)
+		return
+	}
+	if plan.Interface != "" {
+		fmt.Fprintf(w, "  1. interface %s\n", plan.Interface)
+		return
+	}
+
+	step := 1
+	if plan.UPnP {
+		fmt.Fprintf(w, "  %d. UPnP gateway\n", step)
+		step++
+	}
+	if plan.Consensus > 0 {
+		fmt.Fprintf(w, "  %d. all of the following, %d must agree:\n", step, plan.Consensus)
+		for _, svc := range plan.Services {
+			fmt.Fprintf(w, "     - %s\n", svc)
+		}
+		return
+	}
+	if plan.Shuffle {
+		fmt.Fprintf(w, "  %d. the following, in a random order each run:\n", step)
+		for _, svc := range plan.Services {
+			fmt.Fprintf(w, "     - %s\n", svc)
+		}
+		return
+	}
+	for _, svc := range plan.Services {
+		note := ""
+		if plan.Sticky && svc == state.LastService {
+			note = " (last successful)"
+		}
+		fmt.Fprintf(w, "  %d. %s%s\n", step, svc, note)
+		step++
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestExplainDiscovery(t *testing.T) {
+	cfg := Config{
+		RecordNames:  []string{"home.example.com"},
+		RecordType:   recordTypeAuto,
+		AutoPrefer:   autoPreferIPv4,
+		IPSource:     ipSourceUPnP,
+		IPServices:   []string{"https://one", "https://two"},
+		IPv6Services: []string{"https://six"},
+		IPSticky:     true,
+	}
+	state := State{LastService: "https://two"}
+
+	var out bytes.Buffer
+	explainDiscovery(&out, cfg, state)
+
+	expected := `AUTO records (1): first family that succeeds, in this order
+A records (1), IPv4:
+  1. UPnP gateway
+  2. https://two (last successful)
+  3. https://one
+AAAA records (1), IPv6:
+  1. https://six
+`
+	if out.String() != expected {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	cfg.RecordType = "AAAA"
+	cfg.IPv6Interface = "eth0"
+	out.Reset()
+	explainDiscovery(&out, cfg, state)
+	if out.String() != "AAAA records (1), IPv6:\n  1. interface eth0\n" {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestExplainDiscoveryShuffle(t *testing.T) {
+	cfg := Config{
+		RecordNames: []string{"home.example.com"},
+		RecordType:  "A",
+		IPServices:  []string{"https://one", "https://two"},
+		IPShuffle:   true,
+	}
+
+	var out bytes.Buffer
+	explainDiscovery(&out, cfg, State{})
+
+	expected := `A records (1), IPv4:
+  1. the following, in a random order each run:
+     - https://one
+     - https://two
+`
+	if out.String() != expected {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestShuffleServices(t *testing.T) {
+	services := []string{"https://one", "https://two", "https://three"}
+	firsts := make(map[string]bool)
+	for range 200 {
+		shuffled := shuffleServices(services)
+		if !slices.Equal(slices.Sorted(slices.Values(shuffled)), slices.Sorted(slices.Values(services))) {
+			t.Fatalf("shuffle changed the services: %v", shuffled)
+		}
+		firsts[shuffled[0]] = true
+	}
+	if len(firsts) != len(services) {
+		t.Fatalf("expected every service to come first at some point, got %v", firsts)
+	}
+	if services[0] != "https://one" {
+		t.Fatalf("shuffle modified the configured order: %v", services)
+	}
+}
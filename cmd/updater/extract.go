@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+// recordExtractor returns the value of a record that is compared against the
+// desired value when deciding whether the record needs a write.
+type recordExtractor func(record dns.Record) (string, error)
+
+// recordExtractors maps each record type to the field that holds its current
+// value. Most types keep it in content, while SRV and CAA keep it in the data
+// object.
+var recordExtractors = map[dns.RecordType]recordExtractor{
+	dns.RecordTypeA:     extractARecordIP,
+	dns.RecordTypeAAAA:  extractAAAARecordIP,
+	dns.RecordTypeCNAME: extractCNAMETarget,
+	dns.RecordTypeTXT:   extractTXTContent,
+	dns.RecordTypeSRV:   extractSRVContent,
+	dns.RecordTypeCAA:   extractCAAContent,
+}
+
+// extractRecordContent returns the comparable value of record using the
+// extractor for its type: the address for A and AAAA records, the target for
+// CNAME records, the text for TXT records and the formatted data for SRV and
+// CAA records.
+func extractRecordContent(record dns.Record) (string, error) {
+	extract, ok := recordExtractors[record.Type]
+	if !ok {
+		return "", fmt.Errorf("record type %q is not supported", record.Type)
+	}
+	return extract(record)
+}
+
+func extractARecordIP(record dns.Record) (string, error) {
+	aRecord, ok := record.AsUnion().(dns.ARecord)
+	if !ok {
+		return "", fmt.Errorf("record type %q is not supported", record.Type)
+	}
+	return strings.TrimSpace(aRecord.Content), nil
+}
+
+func extractAAAARecordIP(record dns.Record) (string, error) {
+	aaaaRecord, ok := record.AsUnion().(dns.AAAARecord)
+	if !ok {
+		return "", fmt.Errorf("record type %q is not supported", record.Type)
+	}
+	return strings.TrimSpace(aaaaRecord.Content), nil
+}
+
+// extractCNAMETarget returns the target in lower case without a trailing dot,
+// since Cloudflare accepts either form for the same name.
+func extractCNAMETarget(record dns.Record) (string, error) {
+	cnameRecord, ok := record.AsUnion().(dns.CNAMERecord)
+	if !ok {
+		return "", fmt.Errorf("record type %q is not supported", record.Type)
+	}
+	target, ok := cnameRecord.Content.(string)
+	if !ok {
+		return "", fmt.Errorf("CNAME record %s has no target", record.ID)
+	}
+	target = strings.TrimSpace(target)
+	return strings.ToLower(strings.TrimSuffix(target, ".")), nil
+}
+
+// extractTXTContent returns the text of a TXT record. A single quoted string
+// is unquoted so it compares equal to the plain value; anything else, such as
+// several quoted strings, is returned as is.
+func extractTXTContent(record dns.Record) (string, error) {
+	txtRecord, ok := record.AsUnion().(dns.TXTRecord)
+	if !ok {
+		return "", fmt.Errorf("record type %q is not supported", record.Type)
+	}
+	content := strings.TrimSpace(txtRecord.Content)
+	if len(content) >= 2 && strings.HasPrefix(content, `"`) && strings.HasSuffix(content, `"`) {
+		if unquoted, err := strconv.Unquote(content); err == nil {
+			return unquoted, nil
+		}
+	}
+	return content, nil
+}
+
+func extractSRVContent(record dns.Record) (string, error) {
+	data, err := extractSRVData(record)
+	if err != nil {
+		return "", err
+	}
+	return data.String(), nil
+}
+
+func extractCAAContent(record dns.Record) (string, error) {
+	data, err := extractCAAData(record)
+	if err != nil {
+		return "", err
+	}
+	return data.String(), nil
+}
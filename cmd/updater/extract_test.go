@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+func recordFromJSON(t *testing.T, payload string) dns.Record {
+	t.Helper()
+	var record dns.Record
+	if err := json.Unmarshal([]byte(payload), &record); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+	return record
+}
+
+func TestExtractRecordContentA(t *testing.T) {
+	record := recordFromJSON(t, `{"id":"a","type":"A","name":"home.example.com","content":" 203.0.113.10 ","ttl":300}`)
+	content, err := extractRecordContent(record)
+	if err != nil || content != "203.0.113.10" {
+		t.Fatalf("unexpected content %q, err %v", content, err)
+	}
+}
+
+func TestExtractRecordContentAAAA(t *testing.T) {
+	record := recordFromJSON(t, `{"id":"aaaa","type":"AAAA","name":"home.example.com","content":"2001:db8::1","ttl":300}`)
+	content, err := extractRecordContent(record)
+	if err != nil || content != "2001:db8::1" {
+		t.Fatalf("unexpected content %q, err %v", content, err)
+	}
+}
+
+func TestExtractRecordContentCNAME(t *testing.T) {
+	record := recordFromJSON(t, `{"id":"cname","type":"CNAME","name":"www.example.com","content":"Home.Example.com.","ttl":300}`)
+	content, err := extractRecordContent(record)
+	if err != nil || content != "home.example.com" {
+		t.Fatalf("unexpected content %q, err %v", content, err)
+	}
+}
+
+func TestExtractRecordContentTXT(t *testing.T) {
+	cases := map[string]string{
+		`"v=spf1 -all"`:         "v=spf1 -all",
+		`v=spf1 -all`:           "v=spf1 -all",
+		`"part one" "part two"`: `"part one" "part two"`,
+	}
+	for raw, want := range cases {
+		payload, _ := json.Marshal(map[string]any{"id": "txt", "type": "TXT", "name": "example.com", "content": raw, "ttl": 300})
+		content, err := extractRecordContent(recordFromJSON(t, string(payload)))
+		if err != nil || content != want {
+			t.Fatalf("content %s: got %q, err %v; want %q", raw, content, err, want)
+		}
+	}
+}
+
+func TestExtractRecordContentSRVUsesData(t *testing.T) {
+	record := recordFromJSON(t, `{"id":"srv","type":"SRV","name":"_sip._udp.example.com","content":"stale","data":{"priority":1,"weight":2,"port":5060,"target":"sip.example.com"},"ttl":300}`)
+	content, err := extractRecordContent(record)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := SRVData{Priority: 1, Weight: 2, Port: 5060, Target: "sip.example.com"}.String()
+	if content != want {
+		t.Fatalf("unexpected content %q, want %q", content, want)
+	}
+}
+
+func TestExtractRecordContentUnsupportedType(t *testing.T) {
+	record := recordFromJSON(t, `{"id":"mx","type":"MX","name":"example.com","content":"mail.example.com","priority":10,"ttl":300}`)
+	if _, err := extractRecordContent(record); err == nil {
+		t.Fatalf("expected error for MX record")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/cloudflare/cloudflare-go/v2/option"
+)
+
+// extraFieldName restricts CF_EXTRA_FIELDS keys to plain field names, as
+// Cloudflare uses them, so they can be set in the request body as-is.
+var extraFieldName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// parseExtraFields parses the JSON object in CF_EXTRA_FIELDS. Values are
+// kept as raw JSON so fields the updater does not model pass through
+// unchanged.
+func parseExtraFields(value string) (map[string]json.RawMessage, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(value)))
+	var fields map[string]json.RawMessage
+	if err := dec.Decode(&fields); err != nil || fields == nil {
+		return nil, fmt.Errorf("invalid %s value: must be a JSON object", envExtraFields)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid %s value: unexpected data after the JSON object", envExtraFields)
+	}
+	for name := range fields {
+		if !extraFieldName.MatchString(name) {
+			return nil, fmt.Errorf("invalid %s field name %q", envExtraFields, name)
+		}
+	}
+	return fields, nil
+}
+
+// extraFieldOptions returns request options setting each extra field in the
+// body of a record update or creation, replacing any value the updater
+// would send for the same field.
+func extraFieldOptions(fields map[string]json.RawMessage) []option.RequestOption {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	opts := make([]option.RequestOption, 0, len(names))
+	for _, name := range names {
+		opts = append(opts, option.WithJSONSet(name, fields[name]))
+	}
+	return opts
+}
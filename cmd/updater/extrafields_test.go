@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseExtraFields(t *testing.T) {
+	fields, err := parseExtraFields(`{"settings": {"ipv4_only": true}, "comment": "home"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(fields["comment"]) != `"home"` || len(fields) != 2 {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	for _, value := range []string{`[1, 2]`, `{"settings":`, `null`, `{} {}`, `{"data.port": 1}`} {
+		if _, err := parseExtraFields(value); err == nil {
+			t.Errorf("expected error for %s", value)
+		}
+	}
+}
+
+func TestUpdaterSendsExtraFields(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	cfg := Config{
+		RecordNames: []string{"example.com"},
+		ExtraFields: map[string]json.RawMessage{"settings": json.RawMessage(`{"ipv4_only":true}`)},
+	}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	record := api.records["example.com"]
+	settings, ok := record["settings"].(map[string]any)
+	if !ok || settings["ipv4_only"] != true || record["content"] != "203.0.113.10" {
+		t.Fatalf("expected extra fields in the update body, got %v", record)
+	}
+}
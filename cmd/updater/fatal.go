@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+)
+
+// Error types reported in the "type" field of a JSON error.
+const (
+	errorTypeConfig        = "config"
+	errorTypeAuth          = "auth"
+	errorTypeNotFound      = "not_found"
+	errorTypeAPI           = "api"
+	errorTypeCallLimit     = "api_call_limit"
+	errorTypeTimeout       = "timeout"
+	errorTypeChangesNeeded = "changes_needed"
+	errorTypeFrozen        = "frozen"
+	errorTypeWarnings      = "warnings"
+	errorTypeOther         = "error"
+)
+
+// configError marks an error in the configuration, as opposed to one met
+// while running.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string {
+	return "configuration error: " + e.err.Error()
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+// jsonError is the form of a fatal error on stderr with CF_OUTPUT=json.
+type jsonError struct {
+	Error string `json:"error"`
+	Type  string `json:"type"`
+	// Code is the first Cloudflare error code behind the failure, if any.
+	Code int64 `json:"code,omitempty"`
+	// RunID identifies the cycle that failed, if the error came from one.
+	RunID string `json:"run_id,omitempty"`
+}
+
+// classifyError returns the type of err and the Cloudflare error code
+// behind it, or zero when there is none.
+func classifyError(err error) (string, int64) {
+	var code int64
+	var failure *APIFailureError
+	var sdkErr *cloudflare.Error
+	isFailure, isSDK := errors.As(err, &failure), errors.As(err, &sdkErr)
+	switch {
+	case isFailure && len(failure.Errors) > 0:
+		code = failure.Errors[0].Code
+	case isSDK && len(sdkErr.Errors) > 0:
+		code = sdkErr.Errors[0].Code
+	}
+
+	var cfgErr *configError
+	var permErr *PermissionError
+	switch {
+	case errors.As(err, &cfgErr):
+		return errorTypeConfig, code
+	case errors.Is(err, errRunTimeout):
+		return errorTypeTimeout, code
+	case errors.Is(err, errChangesNeeded):
+		return errorTypeChangesNeeded, code
+	case errors.Is(err, errFrozen):
+		return errorTypeFrozen, code
+	case errors.Is(err, errWarnings):
+		return errorTypeWarnings, code
+	case errors.Is(err, errAPICallLimit):
+		return errorTypeCallLimit, code
+	case errors.As(err, &permErr), permissionCodes[code],
+		isSDK && (sdkErr.StatusCode == http.StatusUnauthorized || sdkErr.StatusCode == http.StatusForbidden):
+		return errorTypeAuth, code
+	case errors.Is(err, errRecordNotFound):
+		return errorTypeNotFound, code
+	case isFailure || isSDK:
+		return errorTypeAPI, code
+	}
+	return errorTypeOther, code
+}
+
+// exitStatus returns the process exit status for a run that failed with err.
+func exitStatus(err error) int {
+	switch {
+	case errors.Is(err, errRunTimeout):
+		return exitTimeout
+	case errors.Is(err, errChangesNeeded):
+		return exitChangesNeeded
+	case errors.Is(err, errFrozen):
+		return exitFrozen
+	}
+	return 1
+}
+
+// writeJSONError writes err to w as a single-line jsonError.
+func writeJSONError(w io.Writer, err error) error {
+	kind, code := classifyError(err)
+	return json.NewEncoder(w).Encode(jsonError{Error: err.Error(), Type: kind, Code: code, RunID: errorRunID(err)})
+}
+
+// fatal reports err and exits with status. With asJSON the error goes to
+// stderr as a JSON object for wrappers to branch on; otherwise it is logged
+// as text, prefixed with the ID of the cycle it came from, if any.
+func fatal(asJSON bool, status int, err error) {
+	if id := errorRunID(err); id != "" {
+		log.SetPrefix(runIDPrefix(id))
+	} else {
+		log.SetPrefix("")
+	}
+	if asJSON {
+		if werr := writeJSONError(os.Stderr, err); werr != nil {
+			log.Printf("failed to write JSON error: %v", werr)
+			log.Print(err)
+		}
+	} else {
+		log.Print(err)
+	}
+	os.Exit(status)
+}
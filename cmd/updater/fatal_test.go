@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err      error
+		wantType string
+		wantCode int64
+	}{
+		{&configError{err: errors.New("CF_AUTH_KEY is required")}, errorTypeConfig, 0},
+		{fmt.Errorf("wrapped: %w", errRunTimeout), errorTypeTimeout, 0},
+		{fmt.Errorf("%w: 1 of 1 record(s) need changes", errChangesNeeded), errorTypeChangesNeeded, 0},
+		{fmt.Errorf("%w: held back", errFrozen), errorTypeFrozen, 0},
+		{fmt.Errorf("%w: 2 warnings with CF_STRICT set", errWarnings), errorTypeWarnings, 0},
+		{&APIFailureError{Errors: []apiMessage{{Code: 9109, Message: "Unauthorized"}}}, errorTypeAuth, 9109},
+		{&APIFailureError{Errors: []apiMessage{{Code: 81057, Message: "Record already exists."}}}, errorTypeAPI, 81057},
+		{fmt.Errorf("%w for home.example.com", errRecordNotFound), errorTypeNotFound, 0},
+		{errors.New("boom"), errorTypeOther, 0},
+	}
+	for _, tc := range cases {
+		gotType, gotCode := classifyError(tc.err)
+		if gotType != tc.wantType || gotCode != tc.wantCode {
+			t.Errorf("classifyError(%v) = %s, %d; want %s, %d", tc.err, gotType, gotCode, tc.wantType, tc.wantCode)
+		}
+	}
+}
+
+func TestWriteJSONErrorForFailedRun(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	api.readOnly = true
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}}, api, "203.0.113.10")
+
+	_, err := u.run(context.Background())
+	if err == nil {
+		t.Fatalf("expected the refused write to fail the run")
+	}
+	if status := exitStatus(err); status != 1 {
+		t.Fatalf("expected exit status 1, got %d", status)
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSONError(&buf, err); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var got jsonError
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("expected one JSON object, got %q: %v", buf.String(), err)
+	}
+	if got.Type != errorTypeAuth || got.Code != 10000 || got.Error != "1 of 1 record(s) failed to update" {
+		t.Fatalf("unexpected JSON error %+v", got)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Targets of the generate subcommand.
+const (
+	generateSystemd = "systemd"
+	generateCron    = "cron"
+)
+
+// defaultScheduleInterval is how often generated jobs run when CF_INTERVAL
+// is not set.
+const defaultScheduleInterval = 5 * time.Minute
+
+// generateName names the generated systemd units.
+const generateName = "cloudflare-ddns"
+
+// scheduleEnv returns the CF_ variables from environ, sorted by name, that
+// the generated job needs. CF_INTERVAL is left out because the scheduler
+// takes over the loop.
+func scheduleEnv(environ []string) []string {
+	var vars []string
+	for _, kv := range environ {
+		name, _, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, "CF_") || name == envInterval {
+			continue
+		}
+		vars = append(vars, kv)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// writeSchedule implements the generate subcommand. It writes a systemd
+// service and timer, or a crontab line, that runs command every interval
+// with the CF_ variables from environ. The output holds the credentials of
+// the current environment.
+func writeSchedule(w io.Writer, target string, interval time.Duration, environ, command []string) error {
+	if interval <= 0 {
+		interval = defaultScheduleInterval
+	}
+	vars := scheduleEnv(environ)
+
+	switch target {
+	case generateSystemd:
+		return writeSystemdUnits(w, interval, vars, command)
+	case generateCron:
+		return writeCronLine(w, interval, vars, command)
+	}
+	return fmt.Errorf("unsupported -target %q (must be '%s' or '%s')", target, generateSystemd, generateCron)
+}
+
+func writeSystemdUnits(w io.Writer, interval time.Duration, vars, command []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# /etc/systemd/system/%s.service\n", generateName)
+	b.WriteString("# Holds credentials; keep it readable by root only.\n")
+	b.WriteString("[Unit]\nDescription=Cloudflare DDNS update\n")
+	b.WriteString("Wants=network-online.target\nAfter=network-online.target\n\n")
+	b.WriteString("[Service]\nType=oneshot\n")
+	for _, kv := range vars {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(kv))
+	}
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		// ExecStart expands $NAME, which the arguments never mean.
+		quoted[i] = strings.ReplaceAll(systemdQuote(arg), "$", "$$")
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n\n", strings.Join(quoted, " "))
+
+	fmt.Fprintf(&b, "# /etc/systemd/system/%s.timer\n", generateName)
+	fmt.Fprintf(&b, "[Unit]\nDescription=Run the Cloudflare DDNS update every %s\n\n", interval)
+	seconds := int64((interval + time.Second - 1) / time.Second)
+	fmt.Fprintf(&b, "[Timer]\nOnBootSec=%d\nOnUnitActiveSec=%d\n\n", seconds, seconds)
+	b.WriteString("[Install]\nWantedBy=timers.target\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// systemdQuote quotes s as one word of a systemd unit setting when it
+// holds anything but plain characters. Percent signs are doubled so they
+// are not read as specifiers.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\n\"'\\;") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func writeCronLine(w io.Writer, interval time.Duration, vars, command []string) error {
+	spec, err := cronSpec(interval)
+	if err != nil {
+		return err
+	}
+	words := make([]string, 0, len(vars)+len(command))
+	for _, kv := range vars {
+		name, value, _ := strings.Cut(kv, "=")
+		words = append(words, name+"="+cronQuote(value))
+	}
+	for _, arg := range command {
+		words = append(words, cronQuote(arg))
+	}
+	_, err = fmt.Fprintf(w, "# Holds credentials; add it with crontab -e for the user that runs the updater.\n%s %s\n", spec, strings.Join(words, " "))
+	return err
+}
+
+// cronSpec returns the schedule fields for running every interval, rounded
+// up to what cron can express: whole minutes below an hour, whole hours
+// below a day, and otherwise daily.
+func cronSpec(interval time.Duration) (string, error) {
+	minutes := int((interval + time.Minute - 1) / time.Minute)
+	switch {
+	case minutes < 1:
+		return "", errors.New("interval must be positive")
+	case minutes == 1:
+		return "* * * * *", nil
+	case minutes < 60:
+		return fmt.Sprintf("*/%d * * * *", minutes), nil
+	}
+	hours := (minutes + 59) / 60
+	if hours < 24 {
+		return fmt.Sprintf("0 */%d * * *", hours), nil
+	}
+	return "0 0 * * *", nil
+}
+
+// cronQuote quotes s for the shell cron runs the line with. Percent signs
+// are escaped since cron turns them into newlines.
+func cronQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:,=@+") == "" {
+		return s
+	}
+	quoted := "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	return strings.ReplaceAll(quoted, "%", `\%`)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteScheduleSystemd(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"CF_ZONE_ID=zone-id",
+		"CF_AUTH_KEY=token-value",
+		"CF_INTERVAL=10m",
+		`CF_RECORD_DISPLAY_NAME=My "home" 100%`,
+	}
+	var out bytes.Buffer
+	err := writeSchedule(&out, generateSystemd, 10*time.Minute, environ, []string{"/usr/local/bin/updater", "-mode", "once", "-json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"# /etc/systemd/system/cloudflare-ddns.service\n",
+		"Type=oneshot\n",
+		"Environment=CF_AUTH_KEY=token-value\nEnvironment=\"CF_RECORD_DISPLAY_NAME=My \\\"home\\\" 100%%\"\nEnvironment=CF_ZONE_ID=zone-id\n",
+		"ExecStart=/usr/local/bin/updater -mode once -json\n",
+		"# /etc/systemd/system/cloudflare-ddns.timer\n",
+		"OnUnitActiveSec=600\n",
+		"WantedBy=timers.target\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "PATH=") || strings.Contains(got, envInterval) {
+		t.Errorf("expected only CF_ variables other than %s, got:\n%s", envInterval, got)
+	}
+}
+
+func TestWriteScheduleCron(t *testing.T) {
+	environ := []string{"CF_ZONE_ID=zone-id", "CF_IP_SERVICES=https://a|1,https://b", "CF_WEBHOOK_SECRET=it's 50%"}
+	var out bytes.Buffer
+	if err := writeSchedule(&out, generateCron, 0, environ, []string{"/usr/local/bin/updater", "-mode", "once"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `*/5 * * * * CF_IP_SERVICES='https://a|1,https://b' CF_WEBHOOK_SECRET='it'\''s 50\%' CF_ZONE_ID=zone-id /usr/local/bin/updater -mode once` + "\n"
+	if !strings.HasSuffix(out.String(), want) {
+		t.Fatalf("unexpected cron line:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWriteScheduleUnknownTarget(t *testing.T) {
+	if err := writeSchedule(&bytes.Buffer{}, "launchd", 0, nil, []string{"updater"}); err == nil {
+		t.Fatalf("expected an error for an unknown target")
+	}
+}
+
+func TestCronSpec(t *testing.T) {
+	cases := map[time.Duration]string{
+		30 * time.Second: "* * * * *",
+		5 * time.Minute:  "*/5 * * * *",
+		90 * time.Second: "*/2 * * * *",
+		90 * time.Minute: "0 */2 * * *",
+		48 * time.Hour:   "0 0 * * *",
+	}
+	for interval, want := range cases {
+		if got, err := cronSpec(interval); err != nil || got != want {
+			t.Errorf("cronSpec(%s) = %q, %v; want %q", interval, got, err, want)
+		}
+	}
+}
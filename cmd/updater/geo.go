@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultGeoURL  = "https://ipinfo.io/{ip}/country"
+	geoPlaceholder = "{ip}"
+	maxGeoBodySize = 64 << 10
+)
+
+// lookupCountry asks the geolocation endpoint for the country of ip. The
+// endpoint may answer with a bare country code (ipinfo.io/<ip>/country) or a
+// JSON object carrying one in "country_code", "countryCode" or "country".
+func lookupCountry(ctx context.Context, client *http.Client, endpoint, ip string) (string, error) {
+	url := strings.ReplaceAll(endpoint, geoPlaceholder, ip)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("geo lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGeoBodySize))
+	if err != nil {
+		return "", fmt.Errorf("geo lookup failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("geo lookup returned %s", resp.Status)
+	}
+
+	country := strings.TrimSpace(string(body))
+	if strings.HasPrefix(country, "{") {
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return "", fmt.Errorf("invalid geo lookup response: %w", err)
+		}
+		country = ""
+		for _, key := range []string{"country_code", "countryCode", "country"} {
+			if value, ok := fields[key].(string); ok && value != "" {
+				country = value
+				break
+			}
+		}
+	}
+
+	if country == "" {
+		return "", fmt.Errorf("geo lookup returned no country for %s", ip)
+	}
+	return strings.ToUpper(country), nil
+}
+
+// isCountryCode reports whether code looks like an ISO 3166-1 alpha-2 code.
+func isCountryCode(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
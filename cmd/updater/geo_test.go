@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLookupCountry(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{body: "de\n", want: "DE"},
+		{body: `{"ip":"203.0.113.10","country":"NL"}`, want: "NL"},
+		{body: `{"countryCode":"US","country":"United States"}`, want: "US"},
+		{body: `{"country_code":"FR","country_name":"France"}`, want: "FR"},
+	}
+
+	for _, tc := range cases {
+		var path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			w.Write([]byte(tc.body))
+		}))
+
+		got, err := lookupCountry(context.Background(), &http.Client{}, server.URL+"/{ip}/country", "203.0.113.10")
+		server.Close()
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.body, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%q: expected %s, got %s", tc.body, tc.want, got)
+		}
+		if path != "/203.0.113.10/country" {
+			t.Fatalf("expected IP in lookup path, got %s", path)
+		}
+	}
+}
+
+func TestGeoCheckRefusesUnexpectedCountry(t *testing.T) {
+	geo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("SE"))
+	}))
+	t.Cleanup(geo.Close)
+
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"example.com"}, ExpectedCountry: "DE", GeoURL: geo.URL + "/{ip}"}
+
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected refusal to be a warning, got %v", err)
+	}
+	if results[0].Action != actionSkipped || api.updates != 0 {
+		t.Fatalf("expected update to be skipped, got %+v with %d updates", results[0], api.updates)
+	}
+
+	u.cfg.ExpectedCountry = "SE"
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected matching country to update, got %v", err)
+	}
+	if api.updates != 1 {
+		t.Fatalf("expected one update, got %d", api.updates)
+	}
+}
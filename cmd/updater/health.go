@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthState tracks the outcome of the most recent watch cycle for the
+// /readyz probe.
+type healthState struct {
+	mu       sync.Mutex
+	clock    Clock
+	interval time.Duration
+	lastRun  time.Time
+	lastErr  error
+}
+
+func newHealthState(clock Clock, interval time.Duration) *healthState {
+	return &healthState{clock: clock, interval: interval}
+}
+
+// record stores the outcome of a completed cycle.
+func (h *healthState) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastRun = h.clock.Now()
+	h.lastErr = err
+}
+
+// ready reports whether the last cycle succeeded within twice the interval,
+// and why not otherwise.
+func (h *healthState) ready() (bool, string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case h.lastRun.IsZero():
+		return false, "no run has completed yet"
+	case h.clock.Now().Sub(h.lastRun) > 2*h.interval:
+		return false, fmt.Sprintf("last run at %s is older than %s", h.lastRun.UTC().Format(time.RFC3339), 2*h.interval)
+	case h.lastErr != nil:
+		return false, fmt.Sprintf("last run failed: %v", h.lastErr)
+	}
+	return true, "ok"
+}
+
+// ServeHTTP answers /healthz while the process is alive and /readyz only while
+// cycles are succeeding on schedule.
+func (h *healthState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	switch r.URL.Path {
+	case "/healthz":
+		fmt.Fprintln(w, "ok")
+	case "/readyz":
+		ok, reason := h.ready()
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintln(w, reason)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveHealth exposes h on addr until ctx is done.
+func serveHealth(ctx context.Context, addr string, h *healthState) error {
+	logger := outsideRunLogger()
+	server := &http.Server{Addr: addr, Handler: h, ReadHeaderTimeout: defaultHTTPTimeout, ErrorLog: logger}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	logger.Printf("health endpoints listening on %s", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	h := newHealthState(clock, time.Minute)
+
+	status := func(path string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if got := status("/healthz"); got != http.StatusOK {
+		t.Fatalf("expected healthz to be OK, got %d", got)
+	}
+	if got := status("/readyz"); got != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready before the first run, got %d", got)
+	}
+
+	h.record(nil)
+	if got := status("/readyz"); got != http.StatusOK {
+		t.Fatalf("expected ready after a successful run, got %d", got)
+	}
+
+	clock.Advance(3 * time.Minute)
+	if got := status("/readyz"); got != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready after a stale run, got %d", got)
+	}
+
+	h.record(errors.New("boom"))
+	if got := status("/readyz"); got != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready after a failed run, got %d", got)
+	}
+	if got := status("/healthz"); got != http.StatusOK {
+		t.Fatalf("expected healthz to stay OK, got %d", got)
+	}
+}
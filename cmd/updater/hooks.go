@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+)
+
+const (
+	hookFailureWarn  = "warn"
+	hookFailureFatal = "fatal"
+)
+
+// hookEnv describes a pending or completed change to a hook command.
+func hookEnv(r Result) []string {
+	return []string{
+		"DDNS_RECORD=" + r.Record,
+		"DDNS_RECORD_TYPE=" + r.Type,
+		"DDNS_OLD_IP=" + r.OldIP,
+		"DDNS_NEW_IP=" + r.NewIP,
+	}
+}
+
+// runHook executes command through /bin/sh with env added to the process
+// environment, logging each line of its combined output.
+func runHook(ctx context.Context, name, command string, env []string) error {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+
+	output, err := cmd.CombinedOutput()
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		log.Printf("%s: %s", name, scanner.Text())
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// runConfiguredHook runs command when it is set and applies the configured
+// failure policy: with "fatal" the error is returned, otherwise it is logged.
+func runConfiguredHook(ctx context.Context, cfg Config, name, command string, r Result) error {
+	if command == "" {
+		return nil
+	}
+
+	err := runHook(ctx, name, command, hookEnv(r))
+	if err == nil || cfg.HookFailure == hookFailureFatal {
+		return err
+	}
+
+	log.Printf("warning: %v", err)
+	return nil
+}
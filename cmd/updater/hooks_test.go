@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunHookEnvironment(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook.out")
+	r := Result{Record: "example.com", Type: "A", OldIP: "198.51.100.1", NewIP: "203.0.113.10"}
+
+	if err := runHook(context.Background(), "post-hook", `echo "$DDNS_RECORD $DDNS_OLD_IP $DDNS_NEW_IP" > `+out, hookEnv(r)); err != nil {
+		t.Fatalf("expected hook to succeed, got %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read hook output: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "example.com 198.51.100.1 203.0.113.10" {
+		t.Fatalf("unexpected hook output %q", data)
+	}
+}
+
+func TestHookFailurePolicy(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"example.com"}, PreHook: "exit 3", HookFailure: hookFailureFatal}
+
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	if _, err := u.run(context.Background()); err == nil {
+		t.Fatalf("expected fatal pre-hook failure to fail the run")
+	}
+	if api.updates != 0 {
+		t.Fatalf("expected no update after fatal pre-hook failure")
+	}
+
+	cfg.HookFailure = hookFailureWarn
+	u = newTestUpdater(t, cfg, api, "203.0.113.10")
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected pre-hook failure to be a warning, got %v", err)
+	}
+	if api.updates != 1 {
+		t.Fatalf("expected update to proceed, got %d updates", api.updates)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// recordTypeAuto publishes whichever address family can be discovered.
+	recordTypeAuto = "AUTO"
+
+	autoPreferIPv4 = "ipv4"
+	autoPreferIPv6 = "ipv6"
+)
+
+// defaultTypeSuffixes maps the end of a name's first label to a record type,
+// so home4.example.com is an A record and home6.example.com an AAAA record.
+const defaultTypeSuffixes = "4=A,6=AAAA"
+
+// typeSuffix is one CF_TYPE_SUFFIXES rule.
+type typeSuffix struct {
+	Suffix string
+	Type   string
+}
+
+// parseTypeSuffixes parses comma-separated suffix=TYPE rules.
+func parseTypeSuffixes(value string) ([]typeSuffix, error) {
+	var rules []typeSuffix
+	for _, entry := range splitList(value) {
+		suffix, recordType, ok := strings.Cut(entry, "=")
+		suffix = strings.TrimSpace(suffix)
+		recordType = strings.ToUpper(strings.TrimSpace(recordType))
+		if !ok || suffix == "" || (recordType != "A" && recordType != "AAAA") {
+			return nil, fmt.Errorf("invalid %s entry %q (must be suffix=A or suffix=AAAA)", envTypeSuffixes, entry)
+		}
+		rules = append(rules, typeSuffix{Suffix: suffix, Type: recordType})
+	}
+	return rules, nil
+}
+
+// inferRecordType returns the type of the first rule whose suffix ends the
+// first label of name, or fallback when none does.
+func inferRecordType(name string, rules []typeSuffix, fallback string) string {
+	label, _, _ := strings.Cut(name, ".")
+	for _, rule := range rules {
+		if strings.HasSuffix(label, rule.Suffix) {
+			return rule.Type
+		}
+	}
+	return fallback
+}
+
+// groupByType splits cfg into one configuration per record type, keeping the
+// order in which types first appear. Without inference cfg is returned as is.
+func groupByType(cfg Config) []Config {
+	if len(cfg.TypeSuffixes) == 0 {
+		return []Config{cfg}
+	}
+
+	var groups []Config
+	index := make(map[string]int)
+	for _, name := range cfg.RecordNames {
+		recordType := inferRecordType(name, cfg.TypeSuffixes, cfg.RecordType)
+		i, ok := index[recordType]
+		if !ok {
+			group := cfg
+			group.RecordType = recordType
+			group.RecordName = name
+			group.RecordNames = nil
+			i = len(groups)
+			index[recordType] = i
+			groups = append(groups, group)
+		}
+		groups[i].RecordNames = append(groups[i].RecordNames, name)
+	}
+	return groups
+}
+
+// isAddressType reports whether records of recordType hold the public IP and
+// therefore need IP discovery.
+func isAddressType(recordType string) bool {
+	switch recordType {
+	case "A", "AAAA", recordTypeAuto:
+		return true
+	}
+	return false
+}
+
+// needsAddress reports whether any record configured in cfg needs the public
+// IP. When none does, a run makes no requests to IP services at all.
+func needsAddress(cfg Config) bool {
+	for _, group := range groupByType(cfg) {
+		if isAddressType(group.RecordType) {
+			return true
+		}
+	}
+	return false
+}
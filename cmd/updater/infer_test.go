@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInferRecordType(t *testing.T) {
+	rules, err := parseTypeSuffixes(defaultTypeSuffixes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := map[string]string{
+		"home4.example.com": "A",
+		"home6.example.com": "AAAA",
+		"home.example.com":  "A",
+		"v6.example.com":    "AAAA",
+		"home.6example.com": "A",
+	}
+	for name, want := range cases {
+		if got := inferRecordType(name, rules, "A"); got != want {
+			t.Fatalf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
+
+func TestParseTypeSuffixesInvalid(t *testing.T) {
+	for _, value := range []string{"4", "=A", "4=MX"} {
+		if _, err := parseTypeSuffixes(value); err == nil {
+			t.Fatalf("expected error for %q", value)
+		}
+	}
+}
+
+func TestGroupByType(t *testing.T) {
+	cfg := Config{
+		RecordType:   "A",
+		RecordNames:  []string{"a4.example.com", "a6.example.com", "b4.example.com"},
+		TypeSuffixes: []typeSuffix{{Suffix: "4", Type: "A"}, {Suffix: "6", Type: "AAAA"}},
+	}
+
+	groups := groupByType(cfg)
+	if len(groups) != 2 {
+		t.Fatalf("expected two groups, got %+v", groups)
+	}
+	if groups[0].RecordType != "A" || !reflect.DeepEqual(groups[0].RecordNames, []string{"a4.example.com", "b4.example.com"}) {
+		t.Fatalf("unexpected A group %+v", groups[0])
+	}
+	if groups[1].RecordType != "AAAA" || groups[1].RecordName != "a6.example.com" {
+		t.Fatalf("unexpected AAAA group %+v", groups[1])
+	}
+}
+
+func TestLoadConfigInferType(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "home4.example.com,home6.example.com")
+	t.Setenv(envInferType, "true")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groupByType(cfg)) != 2 {
+		t.Fatalf("expected records to be split by inferred type, got %+v", cfg.TypeSuffixes)
+	}
+
+	t.Setenv(envRecordType, "A")
+	cfg, err = loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TypeSuffixes != nil {
+		t.Fatalf("expected explicit %s to disable inference", envRecordType)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Address families, named as they appear in log and error messages.
+	familyIPv4 = "IPv4"
+	familyIPv6 = "IPv6"
+
+	ipv6PreferStable    = "stable"
+	ipv6PreferTemporary = "temporary"
+
+	// procIfInet6 lists the kernel's IPv6 addresses along with their flags.
+	procIfInet6 = "/proc/net/if_inet6"
+
+	ifaFlagTemporary  = 0x01
+	ifaFlagDeprecated = 0x20
+)
+
+// ipv6Candidate is a global IPv6 address assigned to an interface.
+type ipv6Candidate struct {
+	IP         net.IP
+	Temporary  bool
+	Deprecated bool
+}
+
+// discoverInterfaceIPv6 picks the global IPv6 address of the named interface
+// that matches prefer, falling back to the other kind when none matches.
+func discoverInterfaceIPv6(name, prefer string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("failed to list addresses of %s: %w", name, err)
+	}
+
+	// Without kernel flags (non-Linux, or /proc unavailable) temporary
+	// addresses are told apart by their randomized interface identifier.
+	flags, _ := readIfInet6Flags(procIfInet6, name)
+
+	var candidates []ipv6Candidate
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() != nil || !ipNet.IP.IsGlobalUnicast() || ipNet.IP.IsPrivate() {
+			continue
+		}
+
+		candidate := ipv6Candidate{IP: ipNet.IP, Temporary: !isEUI64(ipNet.IP)}
+		if f, ok := flags[ipNet.IP.String()]; ok {
+			candidate.Temporary = f&ifaFlagTemporary != 0
+			candidate.Deprecated = f&ifaFlagDeprecated != 0
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	ip, ok := selectIPv6(candidates, prefer)
+	if !ok {
+		return "", fmt.Errorf("no global IPv6 address on interface %s", name)
+	}
+	return ip.String(), nil
+}
+
+// selectIPv6 returns the first candidate of the preferred kind. Deprecated
+// addresses are only used when nothing else is available.
+func selectIPv6(candidates []ipv6Candidate, prefer string) (net.IP, bool) {
+	wantTemporary := prefer == ipv6PreferTemporary
+
+	rank := func(c ipv6Candidate) int {
+		r := 0
+		if c.Temporary != wantTemporary {
+			r++
+		}
+		if c.Deprecated {
+			r += 2
+		}
+		return r
+	}
+
+	var best *ipv6Candidate
+	for i := range candidates {
+		if best == nil || rank(candidates[i]) < rank(*best) {
+			best = &candidates[i]
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	return best.IP, true
+}
+
+// isEUI64 reports whether ip has a modified EUI-64 interface identifier,
+// which is derived from the MAC address and therefore stable.
+func isEUI64(ip net.IP) bool {
+	ip = ip.To16()
+	return ip != nil && ip[11] == 0xff && ip[12] == 0xfe
+}
+
+// readIfInet6Flags parses the Linux if_inet6 table and returns the address
+// flags of every address on the named interface.
+func readIfInet6Flags(path, name string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	flags := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// address ifindex prefixlen scope flags name
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 6 || fields[5] != name {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[0])
+		if err != nil || len(raw) != net.IPv6len {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil {
+			continue
+		}
+		flags[net.IP(raw).String()] = value
+	}
+	return flags, scanner.Err()
+}
+
+// sameIPv6Prefix reports whether a and b are IPv6 addresses sharing their
+// first bits bits.
+func sameIPv6Prefix(a, b string, bits int) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil || ipA.To4() != nil || ipB.To4() != nil {
+		return false
+	}
+
+	mask := net.CIDRMask(bits, 8*net.IPv6len)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectIPv6(t *testing.T) {
+	stable := ipv6Candidate{IP: net.ParseIP("2001:db8::211:22ff:fe33:4455")}
+	temporary := ipv6Candidate{IP: net.ParseIP("2001:db8::8c1d:4f2a:91e3:7b10"), Temporary: true}
+	oldTemporary := ipv6Candidate{IP: net.ParseIP("2001:db8::1234:5678:9abc:def0"), Temporary: true, Deprecated: true}
+
+	candidates := []ipv6Candidate{oldTemporary, temporary, stable}
+
+	if ip, _ := selectIPv6(candidates, ipv6PreferStable); !ip.Equal(stable.IP) {
+		t.Fatalf("expected stable address, got %s", ip)
+	}
+	if ip, _ := selectIPv6(candidates, ipv6PreferTemporary); !ip.Equal(temporary.IP) {
+		t.Fatalf("expected current temporary address, got %s", ip)
+	}
+	if ip, _ := selectIPv6([]ipv6Candidate{oldTemporary, temporary}, ipv6PreferStable); !ip.Equal(temporary.IP) {
+		t.Fatalf("expected fallback to temporary address, got %s", ip)
+	}
+	if _, ok := selectIPv6(nil, ipv6PreferStable); ok {
+		t.Fatalf("expected no selection without candidates")
+	}
+}
+
+func TestIsEUI64(t *testing.T) {
+	if !isEUI64(net.ParseIP("2001:db8::211:22ff:fe33:4455")) {
+		t.Fatalf("expected EUI-64 address to be detected")
+	}
+	if isEUI64(net.ParseIP("2001:db8::8c1d:4f2a:91e3:7b10")) {
+		t.Fatalf("expected random identifier not to be EUI-64")
+	}
+}
+
+func TestReadIfInet6Flags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "if_inet6")
+	table := "20010db8000000000000000000000001 02 40 00 80     eth0\n" +
+		"20010db80000000012345678abcdef01 02 40 00 01     eth0\n" +
+		"fe800000000000000000000000000001 02 40 20 80     eth0\n" +
+		"20010db8000000000000000000000002 03 40 00 80     wlan0\n"
+	if err := os.WriteFile(path, []byte(table), 0o644); err != nil {
+		t.Fatalf("write table: %v", err)
+	}
+
+	flags, err := readIfInet6Flags(path, "eth0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(flags) != 3 {
+		t.Fatalf("expected eth0 addresses only, got %v", flags)
+	}
+	if flags["2001:db8::1234:5678:abcd:ef01"]&ifaFlagTemporary == 0 {
+		t.Fatalf("expected temporary flag, got %v", flags)
+	}
+	if flags["2001:db8::1"]&ifaFlagTemporary != 0 {
+		t.Fatalf("expected permanent address, got %v", flags)
+	}
+}
+
+func TestLoadConfigAAAA(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+	t.Setenv(envRecordType, "aaaa")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.IPv6Services, defaultIPServicesV6) {
+		t.Fatalf("expected default IPv6 services without %s, got %v", envIPv6Interface, cfg.IPv6Services)
+	}
+
+	t.Setenv(envIPv6Interface, "eth0")
+	cfg, err = loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RecordType != "AAAA" || cfg.IPv6Prefer != ipv6PreferStable {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	t.Setenv(envIPv6Prefer, "random")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected error for unknown %s", envIPv6Prefer)
+	}
+}
+
+func TestUpdaterDiscoversIPv6FromServices(t *testing.T) {
+	record := aRecordFixture("id-1", "example.com", "2001:db8::1")
+	record["type"] = "AAAA"
+	api := newMockCloudflare(record)
+
+	cfg := Config{RecordNames: []string{"example.com"}, RecordType: "AAAA"}
+	u := newTestUpdater(t, cfg, api, "2001:db8:0:0::10\n")
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if r := results[0]; r.Action != actionChanged || r.NewIP != "2001:db8::10" {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	u = newTestUpdater(t, cfg, api, "203.0.113.10")
+	if _, err := u.run(context.Background()); err == nil {
+		t.Fatalf("expected an IPv4 answer to be rejected for AAAA records")
+	}
+}
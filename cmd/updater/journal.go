@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+)
+
+// journalSocket is where systemd-journald accepts native protocol datagrams.
+const journalSocket = "/run/systemd/journal/socket"
+
+// Syslog priorities used for journal entries.
+const (
+	journalPriorityErr     = "3"
+	journalPriorityWarning = "4"
+	journalPriorityInfo    = "6"
+)
+
+// journalWriter sends each log line to journald as a structured entry for
+// CF_LOG_JOURNAL. Besides MESSAGE and PRIORITY, entries logged after an IP
+// was detected carry it as DDNS_IP, so `journalctl DDNS_IP=<ip>` finds them,
+// and entries logged during a run carry its ID as DDNS_RUN_ID.
+type journalWriter struct {
+	conn *net.UnixConn
+
+	mu    sync.Mutex
+	ip    string
+	runID string
+}
+
+func newJournalWriter(path string) (*journalWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journalWriter{conn: conn}, nil
+}
+
+// setIP records the detected IP attached to later entries. It is a no-op on
+// a nil writer, so callers need not check whether the journal is in use.
+func (w *journalWriter) setIP(ip string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ip = ip
+}
+
+// setRunID records the ID of the current run. Its log prefix is moved from
+// MESSAGE to the DDNS_RUN_ID field. Like setIP, it is a no-op on a nil writer.
+func (w *journalWriter) setRunID(id string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.runID = id
+}
+
+func (w *journalWriter) Write(p []byte) (int, error) {
+	message := strings.TrimSuffix(string(p), "\n")
+
+	w.mu.Lock()
+	ip, runID := w.ip, w.runID
+	w.mu.Unlock()
+
+	var fromRun bool
+	if runID != "" {
+		message, fromRun = strings.CutPrefix(message, runIDPrefix(runID))
+	}
+
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", message)
+	appendJournalField(&buf, "PRIORITY", journalPriority(message))
+	appendJournalField(&buf, "SYSLOG_IDENTIFIER", "cloudflare-ddns")
+	if ip != "" {
+		appendJournalField(&buf, "DDNS_IP", ip)
+	}
+	if fromRun {
+		appendJournalField(&buf, "DDNS_RUN_ID", runID)
+	}
+
+	if _, err := w.conn.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *journalWriter) Close() error {
+	return w.conn.Close()
+}
+
+// journalPriority maps a log line to a syslog priority. The updater's logs
+// carry no level, so warnings are recognised by their prefix and errors by
+// their wording.
+func journalPriority(message string) string {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.HasPrefix(lower, "warning"):
+		return journalPriorityWarning
+	case strings.Contains(lower, "error"), strings.Contains(lower, "failed"):
+		return journalPriorityErr
+	default:
+		return journalPriorityInfo
+	}
+}
+
+// appendJournalField encodes one field of the native protocol. Values
+// containing a newline use the length-prefixed binary form.
+func appendJournalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(key + "=" + value + "\n")
+		return
+	}
+	buf.WriteString(key + "\n")
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournalWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	w, err := newJournalWriter(path)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	read := func() string {
+		buf := make([]byte, 4096)
+		n, err := server.Read(buf)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return string(buf[:n])
+	}
+
+	w.Write([]byte("starting\n"))
+	if got := read(); got != "MESSAGE=starting\nPRIORITY=6\nSYSLOG_IDENTIFIER=cloudflare-ddns\n" {
+		t.Fatalf("unexpected entry %q", got)
+	}
+
+	w.setIP("203.0.113.10")
+	w.Write([]byte("warning: slow service\n"))
+	if got := read(); !strings.Contains(got, "PRIORITY=4\n") || !strings.HasSuffix(got, "DDNS_IP=203.0.113.10\n") {
+		t.Fatalf("expected a warning tagged with the IP, got %q", got)
+	}
+
+	w.setRunID("0a1b2c3d")
+	w.Write([]byte("[0a1b2c3d] warning: slow service\n"))
+	if got := read(); !strings.HasPrefix(got, "MESSAGE=warning: slow service\nPRIORITY=4\n") || !strings.HasSuffix(got, "DDNS_RUN_ID=0a1b2c3d\n") {
+		t.Fatalf("expected the run ID moved to its own field, got %q", got)
+	}
+	w.Write([]byte("watching for changes\n"))
+	if got := read(); strings.Contains(got, "DDNS_RUN_ID") {
+		t.Fatalf("expected no run ID on a line logged outside the run, got %q", got)
+	}
+}
+
+func TestAppendJournalFieldMultiline(t *testing.T) {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", "a\nb")
+
+	var want bytes.Buffer
+	want.WriteString("MESSAGE\n")
+	binary.Write(&want, binary.LittleEndian, uint64(3))
+	want.WriteString("a\nb\n")
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Fatalf("got %q, want %q", buf.Bytes(), want.Bytes())
+	}
+}
+
+func TestJournalPriority(t *testing.T) {
+	cases := map[string]string{
+		"detected public IP: 203.0.113.10":    journalPriorityInfo,
+		"warning: failed to save state file":  journalPriorityWarning,
+		"run failed: 1 of 2 record(s) failed": journalPriorityErr,
+	}
+	for message, want := range cases {
+		if got := journalPriority(message); got != want {
+			t.Errorf("journalPriority(%q) = %s, want %s", message, got, want)
+		}
+	}
+}
+
+func TestOpenLogOutputJournalConflict(t *testing.T) {
+	t.Setenv(envLogJournal, "true")
+	t.Setenv(envLogFile, filepath.Join(t.TempDir(), "updater.log"))
+	if _, err := openLogOutput(&envReader{}, systemClock); err == nil || !strings.Contains(err.Error(), envLogFile) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLogMaxFiles = 3
+	maxLogMaxFiles     = 100
+
+	// logTimeLayout is the timestamp log.LstdFlags puts at the start of
+	// every entry.
+	logTimeLayout = "2006/01/02 15:04:05"
+)
+
+// openLogOutput returns the destination configured by CF_LOG_FILE or
+// CF_LOG_JOURNAL, or nil when logs should stay on stderr. It is read before
+// loadConfig so that configuration warnings already go to the file. The
+// CF_HISTORY_RETENTION window is measured back from clock.
+func openLogOutput(env *envReader, clock Clock) (io.WriteCloser, error) {
+	path := env.get(envLogFile)
+	maxSizeValue := env.get(envLogMaxSize)
+	maxFilesValue := env.get(envLogMaxFiles)
+	journalValue := env.get(envLogJournal)
+	retentionValue := env.get(envHistoryRetention)
+	if env.err != nil {
+		return nil, env.err
+	}
+
+	journal, err := parseBool(envLogJournal, journalValue)
+	if err != nil {
+		return nil, err
+	}
+	if journal {
+		if path != "" {
+			return nil, fmt.Errorf("%s cannot be combined with %s", envLogJournal, envLogFile)
+		}
+		w, err := newJournalWriter(journalSocket)
+		if err != nil {
+			log.Printf("warning: %s is set but the systemd journal is not available (%v); logging to stderr", envLogJournal, err)
+			return nil, nil
+		}
+		return w, nil
+	}
+	if path == "" {
+		if retentionValue != "" {
+			return nil, fmt.Errorf("%s requires %s", envHistoryRetention, envLogFile)
+		}
+		return nil, nil
+	}
+
+	var maxSize int64
+	if maxSizeValue != "" {
+		size, err := parseSize(maxSizeValue)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid %s value %q", envLogMaxSize, maxSizeValue)
+		}
+		maxSize = size
+	}
+
+	keep := defaultLogMaxFiles
+	if maxFilesValue != "" {
+		n, err := strconv.Atoi(maxFilesValue)
+		if err != nil || n < 0 || n > maxLogMaxFiles {
+			return nil, fmt.Errorf("invalid %s value %q (must be between 0 and %d)", envLogMaxFiles, maxFilesValue, maxLogMaxFiles)
+		}
+		keep = n
+	}
+
+	if retentionValue != "" {
+		var cutoff time.Time
+		var maxEntries int
+		var kept string
+		if n, err := strconv.Atoi(retentionValue); err == nil {
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid %s value %q", envHistoryRetention, retentionValue)
+			}
+			maxEntries, kept = n, fmt.Sprintf("the last %d entries", n)
+		} else {
+			retention, err := time.ParseDuration(retentionValue)
+			if err != nil || retention <= 0 {
+				return nil, fmt.Errorf("invalid %s value %q", envHistoryRetention, retentionValue)
+			}
+			cutoff, kept = clock.Now().Add(-retention), "entries newer than "+retention.String()
+		}
+		dropped, err := compactLog(path, cutoff, maxEntries)
+		if err != nil {
+			log.Printf("warning: failed to compact %s: %v", path, err)
+		} else if dropped > 0 {
+			log.Printf("compacted %s: dropped %d line(s), keeping %s", path, dropped, kept)
+		}
+	}
+
+	w, err := newRotatingWriter(path, maxSize, keep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", envLogFile, err)
+	}
+	return w, nil
+}
+
+// compactLog drops the entries of the log file at path written before
+// cutoff, when it is set, or all but the last maxEntries, when that is
+// positive, and returns how many lines were dropped. Entries start with the
+// standard log timestamp; a line without one, such as the rest of a
+// multi-line message, goes with the entry before it. The file is rewritten
+// atomically, and left alone when there is nothing to drop.
+func compactLog(path string, cutoff time.Time, maxEntries int) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	var starts []int // index of the first line of each entry
+	var dates []time.Time
+	for i, line := range lines {
+		if len(line) < len(logTimeLayout) {
+			continue
+		}
+		t, err := time.ParseInLocation(logTimeLayout, string(line[:len(logTimeLayout)]), time.Local)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, i)
+		dates = append(dates, t)
+	}
+	if len(starts) == 0 {
+		// Nothing is known to be old, e.g. a file written without timestamps.
+		return 0, nil
+	}
+
+	first := len(starts) // the first entry kept
+	switch {
+	case maxEntries > 0:
+		first = max(len(starts)-maxEntries, 0)
+	case !cutoff.IsZero():
+		for i, t := range dates {
+			if !t.Before(cutoff) {
+				first = i
+				break
+			}
+		}
+	}
+	if first == 0 {
+		return 0, nil
+	}
+	keepFrom := len(lines)
+	if first < len(starts) {
+		keepFrom = starts[first]
+	}
+	dropped := keepFrom
+	if keepFrom == len(lines) && len(lines[len(lines)-1]) == 0 {
+		dropped-- // SplitAfter's empty remainder after the final newline
+	}
+	if dropped <= 0 {
+		return 0, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(path, bytes.Join(lines[keepFrom:], nil)); err != nil {
+		return 0, err
+	}
+	return dropped, os.Chmod(path, info.Mode().Perm())
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix (powers of
+// 1024, optionally followed by B).
+func parseSize(value string) (int64, error) {
+	s := strings.TrimSuffix(strings.ToUpper(value), "B")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
+// rotatingWriter appends to a file and, once a write would push it past
+// maxSize, renames it to path.1 (shifting older copies up to path.keep) and
+// starts a new one. A maxSize of zero never rotates.
+type rotatingWriter struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	file    *os.File
+	size    int64
+}
+
+func newRotatingWriter(path string, maxSize int64, keep int) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path, maxSize: maxSize, keep: keep}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the existing files and reopens path empty. With keep set to
+// zero the current file is simply truncated.
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	if w.keep == 0 {
+		if err := os.Truncate(w.path, 0); err != nil {
+			return err
+		}
+		return w.open()
+	}
+
+	for i := w.keep - 1; i >= 1; i-- {
+		src := fmt.Sprintf("%s.%d", w.path, i)
+		if _, err := os.Stat(src); err == nil {
+			if err := os.Rename(src, fmt.Sprintf("%s.%d", w.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// rotatedLogFiles lists the rotated copies of path (path.1, path.2, ...) that
+// exist, in rotation order.
+func rotatedLogFiles(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(path) + "."
+	var indexes []int
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(suffix); err == nil && n > 0 && strconv.Itoa(n) == suffix {
+			indexes = append(indexes, n)
+		}
+	}
+	slices.Sort(indexes)
+
+	rotated := make([]string, 0, len(indexes))
+	for _, n := range indexes {
+		rotated = append(rotated, fmt.Sprintf("%s.%d", path, n))
+	}
+	return rotated, nil
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{"512": 512, "10K": 10 << 10, "10kb": 10 << 10, "5M": 5 << 20, "1GB": 1 << 30}
+	for value, want := range cases {
+		got, err := parseSize(value)
+		if err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	if _, err := parseSize("ten"); err == nil {
+		t.Errorf("expected error for invalid size")
+	}
+}
+
+func TestRotatingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updater.log")
+	w, err := newRotatingWriter(path, 10, 2)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	expected := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for file, want := range expected {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		if string(data) != want {
+			t.Fatalf("%s: expected %q, got %q", file, want, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatalf("expected only two rotated files to be kept")
+	}
+}
+
+func TestOpenLogOutputDefaultsToStderr(t *testing.T) {
+	out, err := openLogOutput(&envReader{}, systemClock)
+	if err != nil || out != nil {
+		t.Fatalf("expected no log file without %s, got %v, %v", envLogFile, out, err)
+	}
+
+	t.Setenv(envLogFile, filepath.Join(t.TempDir(), "updater.log"))
+	t.Setenv(envLogMaxSize, "big")
+	if _, err := openLogOutput(&envReader{}, systemClock); err == nil {
+		t.Fatalf("expected error for invalid %s", envLogMaxSize)
+	}
+}
+
+func TestCompactLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updater.log")
+	content := "2024/01/01 10:00:00 old run\n" +
+		"  content: a -> b\n" +
+		"2024/01/05 10:00:00 recent run\n" +
+		"  content: b -> c\n" +
+		"2024/01/06 10:00:00 latest run\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cutoff := time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local)
+	dropped, err := compactLog(path, cutoff, 0)
+	if err != nil || dropped != 2 {
+		t.Fatalf("expected two lines to be dropped, got %d, %v", dropped, err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := content[strings.Index(content, "2024/01/05"):]; string(data) != want {
+		t.Fatalf("expected %q, got %q", want, data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected the file mode to be kept, got %v", info.Mode())
+	}
+
+	if dropped, err := compactLog(path, cutoff, 0); err != nil || dropped != 0 {
+		t.Fatalf("expected nothing left to drop, got %d, %v", dropped, err)
+	}
+	if dropped, err := compactLog(path, time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), 0); err != nil || dropped != 3 {
+		t.Fatalf("expected every line to be dropped, got %d, %v", dropped, err)
+	}
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Fatalf("expected an empty file, got %q", data)
+	}
+}
+
+func TestCompactLogMaxEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updater.log")
+	content := "2024/01/01 10:00:00 first run\n" +
+		"2024/01/02 10:00:00 second run\n" +
+		"  content: a -> b\n" +
+		"2024/01/03 10:00:00 third run\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if dropped, err := compactLog(path, time.Time{}, 2); err != nil || dropped != 1 {
+		t.Fatalf("expected the oldest entry to be dropped, got %d, %v", dropped, err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := content[strings.Index(content, "2024/01/02"):]; string(data) != want {
+		t.Fatalf("expected %q, got %q", want, data)
+	}
+	if dropped, err := compactLog(path, time.Time{}, 5); err != nil || dropped != 0 {
+		t.Fatalf("expected nothing to drop below the limit, got %d, %v", dropped, err)
+	}
+	if dropped, err := compactLog(path, time.Time{}, 1); err != nil || dropped != 2 {
+		t.Fatalf("expected the entry and its detail line to be dropped, got %d, %v", dropped, err)
+	}
+}
+
+func TestCompactLogKeepsUndatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updater.log")
+	if err := os.WriteFile(path, []byte("no timestamps here\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if dropped, err := compactLog(path, time.Now(), 0); err != nil || dropped != 0 {
+		t.Fatalf("expected an undated file to be left alone, got %d, %v", dropped, err)
+	}
+	if dropped, err := compactLog(filepath.Join(t.TempDir(), "missing.log"), time.Now(), 0); err != nil || dropped != 0 {
+		t.Fatalf("expected a missing file to be ignored, got %d, %v", dropped, err)
+	}
+}
+
+func TestOpenLogOutputRetentionUsesClock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updater.log")
+	content := "2024/01/01 10:00:00 old run\n2024/01/05 10:00:00 recent run\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv(envLogFile, path)
+	t.Setenv(envHistoryRetention, "72h")
+
+	clock := newFakeClock(time.Date(2024, 1, 6, 0, 0, 0, 0, time.Local))
+	out, err := openLogOutput(&envReader{}, clock)
+	if err != nil {
+		t.Fatalf("openLogOutput: %v", err)
+	}
+	out.Close()
+	data, _ := os.ReadFile(path)
+	if want := "2024/01/05 10:00:00 recent run\n"; string(data) != want {
+		t.Fatalf("expected the cutoff to follow the clock, got %q", data)
+	}
+
+	content = "2024/01/05 10:00:00 recent run\n2024/01/06 10:00:00 latest run\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv(envHistoryRetention, "1")
+	if out, err = openLogOutput(&envReader{}, clock); err != nil {
+		t.Fatalf("openLogOutput: %v", err)
+	}
+	out.Close()
+	data, _ = os.ReadFile(path)
+	if want := "2024/01/06 10:00:00 latest run\n"; string(data) != want {
+		t.Fatalf("expected only the last entry to be kept, got %q", data)
+	}
+}
+
+func TestOpenLogOutputRetentionRequiresFile(t *testing.T) {
+	t.Setenv(envHistoryRetention, "720h")
+	if _, err := openLogOutput(&envReader{}, systemClock); err == nil {
+		t.Fatalf("expected %s without %s to be rejected", envHistoryRetention, envLogFile)
+	}
+	t.Setenv(envLogFile, filepath.Join(t.TempDir(), "updater.log"))
+	for _, value := range []string{"a month", "0", "-5"} {
+		t.Setenv(envHistoryRetention, value)
+		if _, err := openLogOutput(&envReader{}, systemClock); err == nil {
+			t.Fatalf("expected error for invalid %s %q", envHistoryRetention, value)
+		}
+	}
+}
@@ -2,15 +2,22 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/cloudflare/cloudflare-go/v2"
@@ -22,25 +29,179 @@ const (
 	defaultTTL        = 300
 	defaultRecordType = "A"
 
-	envAuthEmail  = "CF_AUTH_EMAIL"
-	envAuthMethod = "CF_AUTH_METHOD"
-	envAuthKey    = "CF_AUTH_KEY"
-	envZoneID     = "CF_ZONE_ID"
-	envRecordName = "CF_RECORD_NAME"
-	envRecordType = "CF_RECORD_TYPE"
-	envTTL        = "CF_TTL"
-	envProxied    = "CF_PROXIED"
-	envIPServices = "CF_IP_SERVICES"
+	// freePlanMinTTL is the lowest TTL Cloudflare accepts for unproxied
+	// records on the Free plan; paid plans allow lower values.
+	freePlanMinTTL = 120
+
+	envAuthEmail         = "CF_AUTH_EMAIL"
+	envAuthMethod        = "CF_AUTH_METHOD"
+	envAuthKey           = "CF_AUTH_KEY"
+	envZoneID            = "CF_ZONE_ID"
+	envRecordName        = "CF_RECORD_NAME"
+	envDisplayName       = "CF_RECORD_DISPLAY_NAME"
+	envRecordType        = "CF_RECORD_TYPE"
+	envTTL               = "CF_TTL"
+	envProxied           = "CF_PROXIED"
+	envIPServices        = "CF_IP_SERVICES"
+	envSRVPriority       = "CF_SRV_PRIORITY"
+	envSRVWeight         = "CF_SRV_WEIGHT"
+	envSRVPort           = "CF_SRV_PORT"
+	envSRVTarget         = "CF_SRV_TARGET"
+	envCAAFlags          = "CF_CAA_FLAGS"
+	envCAATag            = "CF_CAA_TAG"
+	envCAAValue          = "CF_CAA_VALUE"
+	envDryRun            = "CF_DRY_RUN"
+	envReadOnly          = "CF_READONLY"
+	envExcludeIPs        = "CF_EXCLUDE_IPS"
+	envStateFile         = "CF_STATE_FILE"
+	envIPSticky          = "CF_IP_STICKY"
+	envIPShuffle         = "CF_IP_SHUFFLE"
+	envIPInsecureTLS     = "CF_IP_INSECURE_TLS"
+	envRecordPattern     = "CF_RECORD_PATTERN"
+	envSubdomains        = "CF_SUBDOMAINS"
+	envInterval          = "CF_INTERVAL"
+	envOutput            = "CF_OUTPUT"
+	envMissingOK         = "CF_MISSING_OK"
+	envPreHook           = "CF_PRE_HOOK"
+	envPostHook          = "CF_POST_HOOK"
+	envHookFailure       = "CF_HOOK_FAILURE"
+	envRetries           = "CF_RETRIES"
+	envRetryBaseDelay    = "CF_RETRY_BASE_DELAY"
+	envRetryJitter       = "CF_RETRY_JITTER"
+	envRetryMaxDelay     = "CF_RETRY_MAX_DELAY"
+	envRetryMaxElapsed   = "CF_RETRY_MAX_ELAPSED"
+	envIPSource          = "CF_IP_SOURCE"
+	envExpectedCountry   = "CF_EXPECTED_COUNTRY"
+	envPrecheckTarget    = "CF_PRECHECK_TARGET"
+	envPrecheckTimeout   = "CF_PRECHECK_TIMEOUT"
+	envFreezeFile        = "CF_FREEZE_FILE"
+	envGeoURL            = "CF_GEO_URL"
+	envRunTimeout        = "CF_RUN_TIMEOUT"
+	envIPv6Interface     = "CF_IPV6_INTERFACE"
+	envIPv6Prefer        = "CF_IPV6_PREFER"
+	envAPIBaseURL        = "CF_API_BASE_URL"
+	envRecordMode        = "CF_MODE"
+	envPrune             = "CF_PRUNE"
+	envPreserveMeta      = "CF_PRESERVE_META"
+	envHealthAddr        = "CF_HEALTH_ADDR"
+	envMatchContent      = "CF_MATCH_CONTENT"
+	envMultiMatch        = "CF_MULTI_MATCH"
+	envInferType         = "CF_INFER_TYPE_FROM_NAME"
+	envTypeSuffixes      = "CF_TYPE_SUFFIXES"
+	envIPServiceRetries  = "CF_IP_SERVICE_RETRIES"
+	envIPv6MatchPrefix   = "CF_IPV6_MATCH_PREFIX"
+	envIPConsensus       = "CF_IP_CONSENSUS"
+	envConsensusTiebreak = "CF_CONSENSUS_TIEBREAK"
+	envAPIHostOverride   = "CF_API_HOST_OVERRIDE"
+	envAutoPrefer        = "CF_AUTO_PREFER"
+	envVerifyDNS         = "CF_VERIFY_DNS"
+	envVerifyResolver    = "CF_VERIFY_RESOLVER"
+	envVerifyTimeout     = "CF_VERIFY_TIMEOUT"
+	envTargetsFile       = "CF_TARGETS_FILE"
+	envLogFile           = "CF_LOG_FILE"
+	envLogMaxSize        = "CF_LOG_MAX_SIZE"
+	envLogMaxFiles       = "CF_LOG_MAX_FILES"
+	envLogJournal        = "CF_LOG_JOURNAL"
+	envHistoryRetention  = "CF_HISTORY_RETENTION"
+	envNameMatch         = "CF_NAME_MATCH"
+	envZoneName          = "CF_ZONE_NAME"
+	envIPv6Services      = "CF_IPV6_SERVICES"
+	envNotifyURL         = "CF_NOTIFY_URL"
+	envNotifyOn          = "CF_NOTIFY_ON"
+	envNotifyThreshold   = "CF_NOTIFY_THRESHOLD"
+	envNotifyDiscordURL  = "CF_NOTIFY_DISCORD_URL"
+	envNetworkPrefixV4   = "CF_NETWORK_PREFIX_V4"
+	envNetworkPrefixV6   = "CF_NETWORK_PREFIX_V6"
+	envStuckAfter        = "CF_STUCK_AFTER"
+	envMinimal           = "CF_MINIMAL"
+	envExtraFields       = "CF_EXTRA_FIELDS"
+	envConcurrency       = "CF_CONCURRENCY"
+	envRunOnStart        = "CF_RUN_ON_START"
+	envWebhookListenAddr = "CF_WEBHOOK_LISTEN_ADDR"
+	envWebhookSecret     = "CF_WEBHOOK_SECRET"
+	envRecheckInterval   = "CF_RECHECK_INTERVAL"
+	envAlwaysFetch       = "CF_ALWAYS_FETCH"
+	envForce             = "CF_FORCE"
+	envMaxRecordAge      = "CF_MAX_RECORD_AGE"
+	envReportFile        = "CF_REPORT_FILE"
+	envMaxAPICalls       = "CF_MAX_API_CALLS"
+	envIPSourceRecord    = "CF_IP_SOURCE_RECORD"
+	envContentCommand    = "CF_CONTENT_COMMAND"
+	envRecordTag         = "CF_RECORD_TAG"
+	envCustomHostnames   = "CF_CUSTOM_HOSTNAMES"
+	envAuditComment      = "CF_AUDIT_COMMENT"
+	envConfigJSON        = "CF_CONFIG_JSON"
+	envDiscoveryRetries  = "CF_DISCOVERY_RETRIES"
+	envDiscoveryDelay    = "CF_DISCOVERY_RETRY_DELAY"
+	envMaxWritesPerHour  = "CF_MAX_WRITES_PER_HOUR"
+	envTextfilePath      = "CF_TEXTFILE_PATH"
+	envStrict            = "CF_STRICT"
+	envDiscoveryTimeout  = "CF_DISCOVERY_TIMEOUT"
+	envMaskIP            = "CF_MASK_IP"
+	envBootWait          = "CF_BOOT_WAIT"
+	envMismatch          = "CF_MISMATCH"
+	envOutageCooldown    = "CF_OUTAGE_COOLDOWN"
+	envOutageThreshold   = "CF_OUTAGE_THRESHOLD"
+
+	fileEnvSuffix = "_FILE"
+
+	subdomainPlaceholder = "{sub}"
 )
 
+// errRecordNotFound is returned by DNSProvider.Fetch when no record matches
+// the configured name and type.
+var errRecordNotFound = errors.New("no matching record")
+
+// errMultipleRecords is returned when a name has several records and
+// CF_MULTI_MATCH=error.
+var errMultipleRecords = errors.New("multiple matching records")
+
+// exitTimeout is the exit status when CF_RUN_TIMEOUT cuts a run short,
+// matching timeout(1) so cron wrappers can tell it apart from other failures.
+const exitTimeout = 124
+
+// exitChangesNeeded is the exit status of a CF_READONLY run that found
+// records to change, so monitoring can tell drift apart from failures.
+const exitChangesNeeded = 3
+
+// exitFrozen is the exit status of a run that left changes unmade because
+// CF_FREEZE_FILE exists.
+const exitFrozen = 4
+
+// errFrozen is returned by a run that found records to change while
+// CF_FREEZE_FILE exists.
+var errFrozen = errors.New("updates frozen")
+
+// errChangesNeeded is returned by a CF_READONLY run that found records to
+// change.
+var errChangesNeeded = errors.New("changes needed")
+
 var (
 	defaultHTTPTimeout = 15 * time.Second
+	minInterval        = 30 * time.Second
+
+	defaultIPServiceRetries = 1
+	maxIPServiceRetries     = 5
+
+	defaultDiscoveryRetryDelay = 5 * time.Second
+	maxDiscoveryRetries        = 10
+
+	// bootRetryInterval is the wait between discovery attempts during
+	// CF_BOOT_WAIT.
+	bootRetryInterval = 5 * time.Second
+
+	defaultConcurrency = 4
+	maxConcurrency     = 32
 
 	defaultIPServices = []string{
 		"https://api.ipify.org",
 		"https://ipv4.icanhazip.com",
 		"https://ipinfo.io/ip",
 	}
+	defaultIPServicesV6 = []string{
+		"https://api6.ipify.org",
+		"https://ipv6.icanhazip.com",
+	}
 )
 
 // Config contains the runtime configuration required to talk to Cloudflare and
@@ -50,77 +211,567 @@ type Config struct {
 	AuthMethod string
 	AuthKey    string
 	ZoneID     string
-	RecordName string
-	RecordType string
-	TTL        int
-	Proxied    bool
-	IPServices []string
+	// RecordName is the record being synchronized. loadConfig sets it to the
+	// first entry of RecordNames.
+	RecordName  string
+	RecordNames []string
+	// NotifyURL and NotifyDiscordURL are the notification channels; every
+	// configured one receives the events in NotifyOn. Streak and recovery
+	// events need NotifyThreshold consecutive failed runs.
+	NotifyURL        string
+	NotifyDiscordURL string
+	NotifyOn         map[string]bool
+	NotifyThreshold  int
+	// NetworkPrefixV4 and NetworkPrefixV6 are the prefix lengths an address
+	// must leave for a change to count as a move to a new network.
+	NetworkPrefixV4 int
+	NetworkPrefixV6 int
+	// StuckAfter, when non-zero, warns once an address has stayed the same
+	// for this long, as a sign of a stuck link.
+	StuckAfter time.Duration
+	// NameMatch selects how configured names are compared with Cloudflare's:
+	// exact, or relative to the zone named ZoneName (looked up when empty).
+	NameMatch string
+	ZoneName  string
+	// DisplayNames maps normalized record names to the labels shown for
+	// them in logs and notifications; the API always uses the real name.
+	DisplayNames map[string]string
+	// Targets are further records updated with their own zone and
+	// credentials, using the address discovered for the main records.
+	Targets []Target
+	// DisabledNames are a target's records switched off in its file entry;
+	// they are left alone, and CF_PRUNE does not delete them.
+	DisabledNames []string
+	RecordType    string
+	TTL           int
+	Proxied       bool
+	// ExtraFields are set verbatim in the body of record updates and
+	// creations, for record fields the updater does not model.
+	ExtraFields map[string]json.RawMessage
+	IPServices  []string
+	// IPv6Services discover AAAA addresses when no IPv6Interface is set.
+	IPv6Services []string
+	// IPServiceRetries is how often a service returning an empty or invalid
+	// body is asked again before falling back to the next one.
+	IPServiceRetries int
+	// DiscoveryRetries is how often the whole discovery phase is run again
+	// when it fails, waiting DiscoveryRetryDelay before the first retry and
+	// twice as long before each further one.
+	DiscoveryRetries    int
+	DiscoveryRetryDelay time.Duration
+	// DiscoveryTimeout, when non-zero, bounds each pass over the IP
+	// services, however many are left to ask.
+	DiscoveryTimeout time.Duration
+	// BootWait, when non-zero, keeps retrying discovery in the first run
+	// of the process until it succeeds or this long has passed.
+	BootWait time.Duration
+	// Concurrency is the number of records updated in parallel.
+	Concurrency int
+	// OutageCooldown, when non-zero, pauses runs for this long once
+	// OutageThreshold Cloudflare API calls in a row fail with a 5xx status.
+	OutageCooldown  time.Duration
+	OutageThreshold int
+	// MaxAPICalls caps the Cloudflare API calls per run; zero disables
+	// the cap.
+	MaxAPICalls int
+	// IPConsensus, when non-zero, queries every service and requires that
+	// many to agree; ConsensusTiebreak resolves equally voted addresses.
+	IPConsensus       int
+	ConsensusTiebreak string
+	// Mismatch, set by CF_MISMATCH, decides what happens when discovery
+	// only finds an address of the other family than the record type.
+	Mismatch string
+	SRV      SRVData
+	CAA      CAAData
+	DryRun   bool
+	// ReadOnly reports the changes a run would make without writing,
+	// failing it with errChangesNeeded when there are any.
+	ReadOnly bool
+	// FreezeFile, while it exists, makes every run behave as ReadOnly and
+	// fail with errFrozen when changes are held back.
+	FreezeFile string
+	ExcludeIPs []*net.IPNet
+	StateFile  string
+	IPSticky   bool
+	// IPShuffle asks the HTTP services in a random order every run.
+	IPShuffle bool
+	// IPInsecureTLS disables certificate verification for IP discovery only;
+	// the Cloudflare client always verifies.
+	IPInsecureTLS bool
+	// Interval is the delay between runs in watch mode; zero means run once.
+	Interval time.Duration
+	// RunOnStart makes watch mode run immediately instead of waiting for
+	// the first interval to pass.
+	RunOnStart bool
+	// RecheckInterval, when non-zero, skips looking up records the state
+	// file shows were checked with the same content more recently.
+	RecheckInterval time.Duration
+	// Output selects the stdout format: text (logs only) or json.
+	Output string
+	// ReportFile receives a JSON snapshot of every run, replacing the
+	// previous one.
+	ReportFile string
+	// TextfilePath receives the metrics of every run for node_exporter's
+	// textfile collector.
+	TextfilePath string
+	// MaskIP, set by CF_MASK_IP, masks addresses in notifications. Log
+	// output is masked by main before the configuration is loaded.
+	MaskIP bool
+	// MissingOK downgrades a missing record from an error to a warning.
+	MissingOK bool
+	// AlwaysFetch looks up every record and logs its fields, even when
+	// RecheckInterval would skip it. Force writes records that are already
+	// up to date. The two are independent.
+	AlwaysFetch bool
+	Force       bool
+	// MaxRecordAge, when non-zero, rewrites up-to-date records whose
+	// modified_on is at least this old.
+	MaxRecordAge time.Duration
+	// MaxWritesPerHour, when non-zero, caps the writes to each record within
+	// any hour, counted across runs in the state file. RecentWrites is the
+	// count for cfg.RecordName, set by syncRecords.
+	MaxWritesPerHour int
+	RecentWrites     int
+	// Minimal saves Cloudflare API calls: records are looked up with one
+	// list call per type and the API token is not verified. loadConfig
+	// also applies the rest of the CF_MINIMAL preset.
+	Minimal bool
+	// TypeSuffixes is set when record types are inferred from names; records
+	// matching no rule keep RecordType.
+	TypeSuffixes []typeSuffix
+	// MatchContent selects, among records sharing the name and type, the one
+	// whose current content equals it.
+	MatchContent string
+	// MultiMatch is what to do when a name has several records of its type:
+	// manage the first (first), every one (all), or fail (error).
+	MultiMatch string
+	// RecordTag, when set, is preferred when picking among records of the
+	// same name and added to every record written. Tags holds the live
+	// record's tags, kept when the record is rewritten.
+	RecordTag string
+	Tags      []string
+	// PreserveMeta keeps the live record's TTL and proxied values so only the
+	// content is ever changed.
+	PreserveMeta bool
+	// PreHook and PostHook are shell commands run around a DNS change.
+	PreHook     string
+	PostHook    string
+	HookFailure string
+	Retry       RetryPolicy
+	// IPSource selects how the public IP is discovered: http or upnp.
+	IPSource string
+	// IPSourceRecord is the hostname whose address is published when
+	// IPSource is dns-record.
+	IPSourceRecord string
+	// ContentCommand, when set, is a shell command whose output is
+	// published instead of a discovered address or the CF_SRV_* values.
+	ContentCommand string
+	// RecordMode is update (only touch existing records) or sync (also create
+	// missing ones); Prune additionally deletes stale managed records.
+	RecordMode string
+	Prune      bool
+	// APIBaseURL overrides the Cloudflare API endpoint, e.g. for -mock-server.
+	APIBaseURL string
+	// APIHostOverride is sent as the Host header and TLS server name of API
+	// requests, for gateways that route on them.
+	APIHostOverride string
+	// IPv6Interface is the interface AAAA addresses are read from, and
+	// IPv6Prefer chooses between its stable and temporary addresses.
+	IPv6Interface string
+	IPv6Prefer    string
+	// AutoPrefer picks the family used for RecordType AUTO when both the
+	// IPv4 and the IPv6 address can be discovered.
+	AutoPrefer string
+	// IPv6MatchPrefix, when non-zero, limits AAAA change detection to the
+	// first that many bits; the full address is still written.
+	IPv6MatchPrefix int
+	// HealthAddr, when set in watch mode, serves /healthz and /readyz.
+	HealthAddr string
+	// WebhookListenAddr, when set in watch mode, accepts pushes that trigger
+	// an immediate cycle, authenticated with WebhookSecret when it is set.
+	WebhookListenAddr string
+	WebhookSecret     string
+	// RunTimeout bounds a whole update cycle, including retries and hooks.
+	RunTimeout time.Duration
+	// VerifyDNS resolves every published record after the update, through
+	// VerifyResolver (host:port, or the system resolver when empty), until
+	// it answers with the new content or VerifyTimeout passes. The resolver
+	// also serves CF_IP_SOURCE=dns-record.
+	VerifyDNS      bool
+	VerifyResolver string
+	VerifyTimeout  time.Duration
+	// ExpectedCountry enables the geo check: updates are refused unless the
+	// detected IP geolocates to this ISO country code via GeoURL.
+	ExpectedCountry string
+	GeoURL          string
+	// PrecheckTarget, when set, is a host:port (with {ip} standing for the
+	// detected address) that must accept a TCP connection within
+	// PrecheckTimeout before the address is published.
+	PrecheckTarget  string
+	PrecheckTimeout time.Duration
+	// CustomHostnames treats RecordNames as Cloudflare for SaaS custom
+	// hostnames and updates the origin records they are served from.
+	CustomHostnames bool
+	// AuditComment, set by CF_AUDIT_COMMENT, is the provenance written to
+	// the comment of every record the updater writes. syncRecord stamps it
+	// with the time of the write.
+	AuditComment string
 }
 
 func main() {
-	log.SetFlags(log.LstdFlags)
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
 
-	cfg, err := loadConfig()
+	modeFlag := flag.String("mode", "", "run mode: 'once' or 'watch' (defaults to watch when "+envInterval+" is set)")
+	jsonFlag := flag.Bool("json", false, "print a JSON result per record to stdout (same as "+envOutput+"=json)")
+	explainFlag := flag.Bool("explain-discovery", false, "print the order in which IP discovery sources are tried, then exit")
+	checkFlag := flag.Bool("check", false, "check that the credentials can read every configured zone, then exit")
+	reportFlag := flag.Bool("report", false, "print a summary table of the run to stdout (same as "+envOutput+"=report)")
+	mockFlag := flag.String("mock-server", "", "serve an in-memory Cloudflare API on `addr` for testing instead of updating")
+	flag.Parse()
+
+	// Errors are printed as JSON in JSON output mode, which must be known
+	// even when the rest of the configuration is invalid.
+	jsonErrors := *jsonFlag || (!*reportFlag && strings.EqualFold((&envReader{}).get(envOutput), outputJSON))
+
+	strict, err := parseBool(envStrict, (&envReader{}).get(envStrict))
 	if err != nil {
-		log.Fatalf("configuration error: %v", err)
+		fatal(jsonErrors, 1, &configError{err: err})
+	}
+	// With CF_STRICT every warning is counted, including those logged
+	// while the configuration is read.
+	var warnings *warningCounter
+	if strict {
+		warnings = &warningCounter{out: log.Writer()}
+		log.SetOutput(warnings)
+	}
+	mask, err := parseBool(envMaskIP, (&envReader{}).get(envMaskIP))
+	if err != nil {
+		fatal(jsonErrors, 1, &configError{err: err})
+	}
+	var masker *ipMasker
+	if mask {
+		masker = &ipMasker{out: log.Writer()}
+		log.SetOutput(masker)
 	}
 
-	httpClient := &http.Client{Timeout: defaultHTTPTimeout}
-
-	ip, err := discoverIP(httpClient, cfg.IPServices)
+	logOutput, err := openLogOutput(&envReader{}, systemClock)
 	if err != nil {
-		log.Fatalf("failed to determine public IP: %v", err)
+		fatal(jsonErrors, 1, &configError{err: err})
+	}
+	journal, _ := logOutput.(*journalWriter)
+	setLogOutput := func(w io.Writer) {
+		switch {
+		case warnings != nil:
+			warnings.setOutput(w)
+		case masker != nil:
+			masker.setOutput(w)
+		default:
+			log.SetOutput(w)
+		}
+	}
+	if logOutput != nil {
+		defer logOutput.Close()
+		setLogOutput(logOutput)
+	}
+	if journal != nil {
+		// journald timestamps every entry itself.
+		log.SetFlags(log.Lmsgprefix)
 	}
-	log.Printf("detected public IP: %s", ip)
 
-	cfClient, err := newCloudflareClient(httpClient, cfg)
+	if flag.Arg(0) == "service-stats" {
+		if err := printServiceStats(os.Stdout, &envReader{}); err != nil {
+			fatal(jsonErrors, 1, fmt.Errorf("service-stats failed: %w", err))
+		}
+		return
+	}
+
+	if flag.Arg(0) == "reset" {
+		if logOutput != nil && journal == nil {
+			// CF_LOG_FILE is among the files removed, so report to stderr.
+			setLogOutput(os.Stderr)
+		}
+		removed, err := resetLocalFiles(&envReader{})
+		for _, path := range removed {
+			log.Printf("removed %s", path)
+		}
+		if err != nil {
+			fatal(jsonErrors, 1, fmt.Errorf("reset failed: %w", err))
+		}
+		if len(removed) == 0 {
+			log.Printf("nothing to reset")
+		}
+		return
+	}
+
+	if *mockFlag != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := runMockServer(ctx, *mockFlag); err != nil {
+			stop()
+			fatal(jsonErrors, 1, fmt.Errorf("mock server failed: %w", err))
+		}
+		return
+	}
+
+	// failOnWarnings ends a finished run with an error when CF_STRICT is set
+	// and anything was logged as a warning.
+	failOnWarnings := func() {
+		if warnings == nil {
+			return
+		}
+		if err := warnings.err(); err != nil {
+			fatal(jsonErrors, 1, err)
+		}
+	}
+
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("failed to configure Cloudflare client: %v", err)
+		fatal(jsonErrors, 1, &configError{err: err})
+	}
+	if *jsonFlag {
+		cfg.Output = outputJSON
+	}
+	if *reportFlag {
+		cfg.Output = outputReport
 	}
 
-	ctx := context.Background()
+	if *explainFlag {
+		var state State
+		if cfg.StateFile != "" {
+			if state, err = loadState(cfg.StateFile); err != nil {
+				fatal(jsonErrors, 1, fmt.Errorf("failed to load state file: %w", err))
+			}
+		}
+		explainDiscovery(os.Stdout, cfg, state)
+		return
+	}
+
+	if flag.Arg(0) == "generate" {
+		generateFlags := flag.NewFlagSet("generate", flag.ExitOnError)
+		targetFlag := generateFlags.String("target", generateSystemd, "what to generate: '"+generateSystemd+"' for a service and timer, or '"+generateCron+"' for a crontab line")
+		generateFlags.Parse(flag.Args()[1:])
+
+		exe, err := os.Executable()
+		if err != nil {
+			fatal(jsonErrors, 1, fmt.Errorf("generate failed: %w", err))
+		}
+		// The scheduler repeats the run, so the job runs once; output
+		// flags given with generate are kept.
+		command := []string{exe, "-mode", "once"}
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "json" || f.Name == "report" {
+				command = append(command, "-"+f.Name)
+			}
+		})
+		if err := writeSchedule(os.Stdout, *targetFlag, cfg.Interval, os.Environ(), command); err != nil {
+			fatal(jsonErrors, 1, fmt.Errorf("generate failed: %w", err))
+		}
+		return
+	}
 
-	record, err := fetchDNSRecord(ctx, cfClient, cfg)
+	mode, err := resolveMode(*modeFlag, cfg.Interval)
 	if err != nil {
-		log.Fatalf("failed to fetch DNS record: %v", err)
+		fatal(jsonErrors, 1, &configError{err: err})
+	}
+
+	if flag.Arg(0) == "diff" {
+		// diff is a dry run that reports drift instead of logging it.
+		cfg.DryRun = true
+		cfg.VerifyDNS = false
 	}
 
-	currentIP, err := extractARecordIP(record)
+	u, err := newUpdater(cfg)
 	if err != nil {
-		log.Fatalf("unexpected DNS record content: %v", err)
+		fatal(jsonErrors, 1, &configError{err: fmt.Errorf("failed to configure Cloudflare client: %w", err)})
 	}
+	u.journal = journal
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if currentIP == ip {
-		log.Printf("Cloudflare record %s already up to date", record.Name)
+	if flag.Arg(0) == "diff" {
+		if err := u.diff(ctx); err != nil {
+			stop()
+			fatal(jsonErrors, 1, fmt.Errorf("diff: %w", err))
+		}
+		failOnWarnings()
 		return
 	}
 
-	if err := updateDNSRecord(ctx, cfClient, cfg, record.ID, ip); err != nil {
-		log.Fatalf("failed to update DNS record: %v", err)
+	if *checkFlag {
+		if err := u.check(ctx); err != nil {
+			stop()
+			fatal(jsonErrors, 1, fmt.Errorf("check failed: %w", err))
+		}
+		failOnWarnings()
+		return
 	}
 
-	log.Printf("successfully updated %s from %s to %s", record.Name, currentIP, ip)
+	if mode == modeWatch {
+		if strict {
+			log.Printf("warning: %s is only used in once mode", envStrict)
+		}
+		if cfg.HealthAddr != "" {
+			u.health = newHealthState(systemClock, cfg.Interval)
+			go func() {
+				if err := serveHealth(ctx, cfg.HealthAddr, u.health); err != nil {
+					fatal(jsonErrors, 1, fmt.Errorf("health server failed: %w", err))
+				}
+			}()
+		}
+
+		var trigger <-chan struct{}
+		if cfg.WebhookListenAddr != "" {
+			if cfg.WebhookSecret == "" {
+				log.Printf("warning: %s is not set; anyone who can reach %s can trigger updates", envWebhookSecret, cfg.WebhookListenAddr)
+			}
+			u.push = newPushServer(cfg.WebhookSecret)
+			trigger = u.push.trigger
+			go func() {
+				if err := servePush(ctx, cfg.WebhookListenAddr, u.push); err != nil {
+					fatal(jsonErrors, 1, fmt.Errorf("push webhook server failed: %w", err))
+				}
+			}()
+		}
+
+		log.Printf("watching for changes every %s", cfg.Interval)
+		watch(ctx, systemClock, cfg.Interval, cfg.RunOnStart, trigger, u.cycle)
+		return
+	}
+	if cfg.HealthAddr != "" {
+		log.Printf("warning: %s is only used in watch mode", envHealthAddr)
+	}
+	if cfg.WebhookListenAddr != "" {
+		log.Printf("warning: %s is only used in watch mode", envWebhookListenAddr)
+	}
+
+	if err := u.cycle(ctx); err != nil {
+		stop()
+		fatal(jsonErrors, exitStatus(err), err)
+	}
+	failOnWarnings()
 }
 
 func loadConfig() (Config, error) {
+	env := &envReader{}
 	cfg := Config{
-		AuthEmail:  strings.TrimSpace(os.Getenv(envAuthEmail)),
-		AuthMethod: strings.ToLower(strings.TrimSpace(os.Getenv(envAuthMethod))),
-		AuthKey:    strings.TrimSpace(os.Getenv(envAuthKey)),
-		ZoneID:     strings.TrimSpace(os.Getenv(envZoneID)),
-		RecordName: strings.TrimSpace(os.Getenv(envRecordName)),
-		RecordType: strings.ToUpper(strings.TrimSpace(os.Getenv(envRecordType))),
+		AuthEmail:  env.get(envAuthEmail),
+		AuthMethod: strings.ToLower(env.get(envAuthMethod)),
+		AuthKey:    env.get(envAuthKey),
+		ZoneID:     env.get(envZoneID),
+		RecordName: env.get(envRecordName),
+		RecordType: strings.ToUpper(env.get(envRecordType)),
+	}
+	ttlValue := env.get(envTTL)
+	proxiedValue := env.get(envProxied)
+	dryRunValue := env.get(envDryRun)
+	readOnlyValue := env.get(envReadOnly)
+	excludeValue := env.get(envExcludeIPs)
+	recordPattern := env.get(envRecordPattern)
+	subdomainsValue := env.get(envSubdomains)
+	displayNameValue := env.get(envDisplayName)
+	cfg.StateFile = env.get(envStateFile)
+	targetsFile := env.get(envTargetsFile)
+	cfg.NotifyURL = env.get(envNotifyURL)
+	extraFieldsValue := env.get(envExtraFields)
+	cfg.NotifyDiscordURL = env.get(envNotifyDiscordURL)
+	notifyOnValue := env.get(envNotifyOn)
+	notifyThresholdValue := env.get(envNotifyThreshold)
+	networkPrefixV4Value := env.get(envNetworkPrefixV4)
+	networkPrefixV6Value := env.get(envNetworkPrefixV6)
+	stuckAfterValue := env.get(envStuckAfter)
+	cfg.NameMatch = strings.ToLower(env.get(envNameMatch))
+	cfg.ZoneName = strings.ToLower(strings.TrimSuffix(env.get(envZoneName), "."))
+	intervalValue := env.get(envInterval)
+	runOnStartValue := env.get(envRunOnStart)
+	recheckValue := env.get(envRecheckInterval)
+	runTimeoutValue := env.get(envRunTimeout)
+	verifyValue := env.get(envVerifyDNS)
+	cfg.VerifyResolver = env.get(envVerifyResolver)
+	verifyTimeoutValue := env.get(envVerifyTimeout)
+	cfg.HealthAddr = env.get(envHealthAddr)
+	cfg.WebhookListenAddr = env.get(envWebhookListenAddr)
+	cfg.WebhookSecret = env.get(envWebhookSecret)
+	cfg.IPv6Interface = env.get(envIPv6Interface)
+	cfg.APIBaseURL = env.get(envAPIBaseURL)
+	cfg.APIHostOverride = env.get(envAPIHostOverride)
+	cfg.RecordMode = strings.ToLower(env.get(envRecordMode))
+	pruneValue := env.get(envPrune)
+	cfg.IPv6Prefer = strings.ToLower(env.get(envIPv6Prefer))
+	matchPrefixValue := env.get(envIPv6MatchPrefix)
+	cfg.AutoPrefer = strings.ToLower(env.get(envAutoPrefer))
+	cfg.Output = strings.ToLower(env.get(envOutput))
+	cfg.ReportFile = env.get(envReportFile)
+	cfg.TextfilePath = env.get(envTextfilePath)
+	maskIPValue := env.get(envMaskIP)
+	missingOKValue := env.get(envMissingOK)
+	alwaysFetchValue := env.get(envAlwaysFetch)
+	forceValue := env.get(envForce)
+	maxRecordAgeValue := env.get(envMaxRecordAge)
+	maxWritesValue := env.get(envMaxWritesPerHour)
+	outageCooldownValue := env.get(envOutageCooldown)
+	outageThresholdValue := env.get(envOutageThreshold)
+	minimalValue := env.get(envMinimal)
+	preserveMetaValue := env.get(envPreserveMeta)
+	auditCommentValue := env.get(envAuditComment)
+	cfg.MatchContent = env.get(envMatchContent)
+	cfg.MultiMatch = strings.ToLower(env.get(envMultiMatch))
+	recordTagValue := env.get(envRecordTag)
+	customHostnamesValue := env.get(envCustomHostnames)
+	inferTypeValue := env.get(envInferType)
+	typeSuffixesValue := env.get(envTypeSuffixes)
+	cfg.PreHook = env.get(envPreHook)
+	cfg.PostHook = env.get(envPostHook)
+	cfg.HookFailure = strings.ToLower(env.get(envHookFailure))
+	cfg.IPSource = strings.ToLower(env.get(envIPSource))
+	cfg.IPSourceRecord = strings.ToLower(strings.TrimSuffix(env.get(envIPSourceRecord), "."))
+	cfg.ContentCommand = env.get(envContentCommand)
+	cfg.ExpectedCountry = strings.ToUpper(env.get(envExpectedCountry))
+	cfg.FreezeFile = env.get(envFreezeFile)
+	precheckTargetValue := env.get(envPrecheckTarget)
+	precheckTimeoutValue := env.get(envPrecheckTimeout)
+	cfg.GeoURL = env.get(envGeoURL)
+	retriesValue := env.get(envRetries)
+	retryBaseDelayValue := env.get(envRetryBaseDelay)
+	retryJitterValue := strings.ToLower(env.get(envRetryJitter))
+	retryMaxDelayValue := env.get(envRetryMaxDelay)
+	retryMaxElapsedValue := env.get(envRetryMaxElapsed)
+	stickyValue := env.get(envIPSticky)
+	shuffleValue := env.get(envIPShuffle)
+	insecureValue := env.get(envIPInsecureTLS)
+	servicesValue := env.get(envIPServices)
+	servicesV6Value := env.get(envIPv6Services)
+	serviceRetriesValue := env.get(envIPServiceRetries)
+	discoveryRetriesValue := env.get(envDiscoveryRetries)
+	discoveryDelayValue := env.get(envDiscoveryDelay)
+	discoveryTimeoutValue := env.get(envDiscoveryTimeout)
+	bootWaitValue := env.get(envBootWait)
+	concurrencyValue := env.get(envConcurrency)
+	maxAPICallsValue := env.get(envMaxAPICalls)
+	consensusValue := env.get(envIPConsensus)
+	cfg.ConsensusTiebreak = strings.ToLower(env.get(envConsensusTiebreak))
+	cfg.Mismatch = strings.ToLower(env.get(envMismatch))
+	srvPriorityValue := env.get(envSRVPriority)
+	srvWeightValue := env.get(envSRVWeight)
+	srvPortValue := env.get(envSRVPort)
+	cfg.SRV.Target = env.get(envSRVTarget)
+	caaFlagsValue := env.get(envCAAFlags)
+	cfg.CAA.Tag = env.get(envCAATag)
+	cfg.CAA.Value = env.get(envCAAValue)
+	if env.err != nil {
+		return Config{}, env.err
 	}
 
 	if cfg.AuthMethod == "" {
-		cfg.AuthMethod = "token"
+		cfg.AuthMethod = authMethodAuto
 	}
 
+	explicitType := cfg.RecordType != ""
 	if cfg.RecordType == "" {
 		cfg.RecordType = defaultRecordType
 	}
 
-	ttlValue := strings.TrimSpace(os.Getenv(envTTL))
+	var err error
 	if ttlValue == "" {
 		cfg.TTL = defaultTTL
 	} else {
@@ -131,39 +782,438 @@ func loadConfig() (Config, error) {
 		cfg.TTL = ttl
 	}
 
-	proxiedValue := strings.TrimSpace(os.Getenv(envProxied))
-	switch strings.ToLower(proxiedValue) {
-	case "", "false":
-		cfg.Proxied = false
-	case "true":
-		cfg.Proxied = true
-	default:
-		return Config{}, fmt.Errorf("invalid %s value %q", envProxied, proxiedValue)
+	if cfg.Proxied, err = parseBool(envProxied, proxiedValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.TTL < freePlanMinTTL && !cfg.Proxied {
+		log.Printf("warning: %s=%d is below the Free plan minimum of %d; Cloudflare will reject the update unless the zone is on a paid plan", envTTL, cfg.TTL, freePlanMinTTL)
 	}
 
-	servicesValue := strings.TrimSpace(os.Getenv(envIPServices))
-	if servicesValue == "" {
+	if cfg.ExtraFields, err = parseExtraFields(extraFieldsValue); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.DryRun, err = parseBool(envDryRun, dryRunValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.ReadOnly, err = parseBool(envReadOnly, readOnlyValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.ReadOnly && cfg.DryRun {
+		return Config{}, fmt.Errorf("%s cannot be combined with %s", envReadOnly, envDryRun)
+	}
+
+	if cfg.IPServices, err = parseIPServices(servicesValue); err != nil {
+		return Config{}, err
+	}
+	if len(cfg.IPServices) == 0 {
 		cfg.IPServices = append([]string{}, defaultIPServices...)
-	} else {
-		raw := strings.Split(servicesValue, ",")
-		for _, svc := range raw {
-			trimmed := strings.TrimSpace(svc)
-			if trimmed != "" {
-				cfg.IPServices = append(cfg.IPServices, trimmed)
-			}
+	}
+	if cfg.IPv6Services, err = parseIPServices(servicesV6Value); err != nil {
+		return Config{}, err
+	}
+	if len(cfg.IPv6Services) == 0 {
+		cfg.IPv6Services = append([]string{}, defaultIPServicesV6...)
+	}
+
+	if intervalValue != "" {
+		interval, err := time.ParseDuration(intervalValue)
+		if err != nil || interval < minInterval {
+			return Config{}, fmt.Errorf("invalid %s value %q (must be a duration of at least %s)", envInterval, intervalValue, minInterval)
+		}
+		cfg.Interval = interval
+	}
+
+	if recheckValue != "" {
+		if cfg.RecheckInterval, err = time.ParseDuration(recheckValue); err != nil || cfg.RecheckInterval < 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envRecheckInterval, recheckValue)
+		}
+		if cfg.StateFile == "" {
+			return Config{}, fmt.Errorf("%s requires %s", envRecheckInterval, envStateFile)
+		}
+	}
+
+	cfg.RunOnStart = true
+	if runOnStartValue != "" {
+		if cfg.RunOnStart, err = parseBool(envRunOnStart, runOnStartValue); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if cfg.VerifyDNS, err = parseBool(envVerifyDNS, verifyValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.VerifyDNS && cfg.Proxied {
+		return Config{}, fmt.Errorf("%s cannot be used with %s (proxied records resolve to Cloudflare addresses)", envVerifyDNS, envProxied)
+	}
+	switch strings.ToLower(cfg.VerifyResolver) {
+	case "":
+		cfg.VerifyResolver = defaultVerifyResolver
+	case resolverSystem:
+		cfg.VerifyResolver = ""
+	default:
+		if _, _, err := net.SplitHostPort(cfg.VerifyResolver); err != nil {
+			cfg.VerifyResolver = net.JoinHostPort(cfg.VerifyResolver, "53")
+		}
+		_, port, _ := net.SplitHostPort(cfg.VerifyResolver)
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envVerifyResolver, cfg.VerifyResolver)
+		}
+	}
+	cfg.VerifyTimeout = defaultVerifyTimeout
+	if verifyTimeoutValue != "" {
+		timeout, err := time.ParseDuration(verifyTimeoutValue)
+		if err != nil || timeout <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envVerifyTimeout, verifyTimeoutValue)
+		}
+		cfg.VerifyTimeout = timeout
+	}
+
+	if runTimeoutValue != "" {
+		timeout, err := time.ParseDuration(runTimeoutValue)
+		if err != nil || timeout <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envRunTimeout, runTimeoutValue)
+		}
+		cfg.RunTimeout = timeout
+	}
+
+	if cfg.MissingOK, err = parseBool(envMissingOK, missingOKValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.AlwaysFetch, err = parseBool(envAlwaysFetch, alwaysFetchValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.Force, err = parseBool(envForce, forceValue); err != nil {
+		return Config{}, err
+	}
+	if maxRecordAgeValue != "" {
+		if cfg.MaxRecordAge, err = time.ParseDuration(maxRecordAgeValue); err != nil || cfg.MaxRecordAge <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envMaxRecordAge, maxRecordAgeValue)
+		}
+	}
+
+	if cfg.TextfilePath != "" && !strings.HasSuffix(cfg.TextfilePath, ".prom") {
+		log.Printf("warning: %s %s does not end in .prom; node_exporter's textfile collector will ignore it", envTextfilePath, cfg.TextfilePath)
+	}
+
+	if cfg.MaskIP, err = parseBool(envMaskIP, maskIPValue); err != nil {
+		return Config{}, err
+	}
+
+	if maxWritesValue != "" {
+		if cfg.MaxWritesPerHour, err = strconv.Atoi(maxWritesValue); err != nil || cfg.MaxWritesPerHour < 1 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envMaxWritesPerHour, maxWritesValue)
+		}
+		if cfg.StateFile == "" {
+			return Config{}, fmt.Errorf("%s requires %s", envMaxWritesPerHour, envStateFile)
+		}
+	}
+
+	if outageCooldownValue != "" {
+		cooldown, err := time.ParseDuration(outageCooldownValue)
+		if err != nil || cooldown <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envOutageCooldown, outageCooldownValue)
+		}
+		if cfg.StateFile == "" {
+			return Config{}, fmt.Errorf("%s requires %s", envOutageCooldown, envStateFile)
+		}
+		cfg.OutageCooldown = cooldown
+		cfg.OutageThreshold = defaultOutageThreshold
+	}
+	if outageThresholdValue != "" {
+		if cfg.OutageCooldown == 0 {
+			return Config{}, fmt.Errorf("%s requires %s", envOutageThreshold, envOutageCooldown)
+		}
+		if cfg.OutageThreshold, err = strconv.Atoi(outageThresholdValue); err != nil || cfg.OutageThreshold < 1 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envOutageThreshold, outageThresholdValue)
+		}
+	}
+
+	if cfg.Minimal, err = parseBool(envMinimal, minimalValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.Minimal {
+		if err := applyMinimal(&cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if recordTagValue != "" {
+		if cfg.RecordTag, err = parseRecordTag(recordTagValue); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if cfg.PreserveMeta, err = parseBool(envPreserveMeta, preserveMetaValue); err != nil {
+		return Config{}, err
+	}
+
+	auditComment, err := parseBool(envAuditComment, auditCommentValue)
+	if err != nil {
+		return Config{}, err
+	}
+	if auditComment {
+		cfg.AuditComment = auditSource()
+	}
+
+	switch cfg.IPSource {
+	case "":
+		cfg.IPSource = ipSourceHTTP
+	case ipSourceHTTP, ipSourceUPnP:
+	case ipSourceDNSRecord:
+		if cfg.IPSourceRecord == "" {
+			return Config{}, fmt.Errorf("%s=%s requires %s", envIPSource, ipSourceDNSRecord, envIPSourceRecord)
+		}
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s', '%s' or '%s')", envIPSource, cfg.IPSource, ipSourceHTTP, ipSourceUPnP, ipSourceDNSRecord)
+	}
+	if cfg.IPSourceRecord != "" && cfg.IPSource != ipSourceDNSRecord {
+		log.Printf("warning: %s is only used when %s is '%s'", envIPSourceRecord, envIPSource, ipSourceDNSRecord)
+	}
+	if cfg.ContentCommand != "" && cfg.IPSource != ipSourceHTTP {
+		return Config{}, fmt.Errorf("%s cannot be combined with %s=%s", envContentCommand, envIPSource, cfg.IPSource)
+	}
+
+	if precheckTargetValue != "" {
+		if cfg.PrecheckTarget, err = parsePrecheckTarget(precheckTargetValue); err != nil {
+			return Config{}, err
+		}
+	}
+	cfg.PrecheckTimeout = defaultPrecheckTimeout
+	if precheckTimeoutValue != "" {
+		if cfg.PrecheckTarget == "" {
+			return Config{}, fmt.Errorf("%s requires %s", envPrecheckTimeout, envPrecheckTarget)
+		}
+		if cfg.PrecheckTimeout, err = time.ParseDuration(precheckTimeoutValue); err != nil || cfg.PrecheckTimeout <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envPrecheckTimeout, precheckTimeoutValue)
+		}
+	}
+
+	if cfg.ExpectedCountry != "" && !isCountryCode(cfg.ExpectedCountry) {
+		return Config{}, fmt.Errorf("invalid %s value %q", envExpectedCountry, cfg.ExpectedCountry)
+	}
+	if cfg.GeoURL == "" {
+		cfg.GeoURL = defaultGeoURL
+	} else if !strings.Contains(cfg.GeoURL, geoPlaceholder) {
+		return Config{}, fmt.Errorf("%s must contain the %s placeholder", envGeoURL, geoPlaceholder)
+	}
+
+	cfg.IPServiceRetries = defaultIPServiceRetries
+	if serviceRetriesValue != "" {
+		retries, err := strconv.Atoi(serviceRetriesValue)
+		if err != nil || retries < 0 || retries > maxIPServiceRetries {
+			return Config{}, fmt.Errorf("invalid %s value %q (must be between 0 and %d)", envIPServiceRetries, serviceRetriesValue, maxIPServiceRetries)
+		}
+		cfg.IPServiceRetries = retries
+	}
+
+	if discoveryRetriesValue != "" {
+		retries, err := strconv.Atoi(discoveryRetriesValue)
+		if err != nil || retries < 0 || retries > maxDiscoveryRetries {
+			return Config{}, fmt.Errorf("invalid %s value %q (must be between 0 and %d)", envDiscoveryRetries, discoveryRetriesValue, maxDiscoveryRetries)
+		}
+		cfg.DiscoveryRetries = retries
+	}
+	cfg.DiscoveryRetryDelay = defaultDiscoveryRetryDelay
+	if discoveryDelayValue != "" {
+		if cfg.DiscoveryRetries == 0 {
+			return Config{}, fmt.Errorf("%s requires %s", envDiscoveryDelay, envDiscoveryRetries)
+		}
+		delay, err := time.ParseDuration(discoveryDelayValue)
+		if err != nil || delay <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envDiscoveryDelay, discoveryDelayValue)
+		}
+		cfg.DiscoveryRetryDelay = delay
+	}
+
+	cfg.Concurrency = defaultConcurrency
+	if concurrencyValue != "" {
+		n, err := strconv.Atoi(concurrencyValue)
+		if err != nil || n < 1 || n > maxConcurrency {
+			return Config{}, fmt.Errorf("invalid %s value %q (must be between 1 and %d)", envConcurrency, concurrencyValue, maxConcurrency)
+		}
+		cfg.Concurrency = n
+	}
+
+	cfg.MaxAPICalls = defaultMaxAPICalls
+	if maxAPICallsValue != "" {
+		n, err := strconv.Atoi(maxAPICallsValue)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envMaxAPICalls, maxAPICallsValue)
+		}
+		cfg.MaxAPICalls = n
+	}
+
+	if consensusValue != "" {
+		threshold, err := strconv.Atoi(consensusValue)
+		if err != nil || threshold < 1 || threshold > len(cfg.IPServices) {
+			return Config{}, fmt.Errorf("invalid %s value %q (must be between 1 and the number of IP services, %d)", envIPConsensus, consensusValue, len(cfg.IPServices))
+		}
+		cfg.IPConsensus = threshold
+	}
+
+	if discoveryTimeoutValue != "" {
+		timeout, err := time.ParseDuration(discoveryTimeoutValue)
+		if err != nil || timeout <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envDiscoveryTimeout, discoveryTimeoutValue)
+		}
+		cfg.DiscoveryTimeout = timeout
+		if cfg.IPConsensus > 0 {
+			log.Printf("warning: %s is only used without %s", envDiscoveryTimeout, envIPConsensus)
+		}
+	}
+
+	if bootWaitValue != "" {
+		wait, err := time.ParseDuration(bootWaitValue)
+		if err != nil || wait <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envBootWait, bootWaitValue)
+		}
+		cfg.BootWait = wait
+	}
+
+	switch cfg.ConsensusTiebreak {
+	case "":
+		cfg.ConsensusTiebreak = tiebreakError
+	case tiebreakError, tiebreakPreferFirst, tiebreakPreferMostRecent:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s', '%s' or '%s')", envConsensusTiebreak, cfg.ConsensusTiebreak, tiebreakError, tiebreakPreferFirst, tiebreakPreferMostRecent)
+	}
+
+	switch cfg.Mismatch {
+	case "":
+		cfg.Mismatch = mismatchError
+	case mismatchError, mismatchSkip, mismatchWarn:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s', '%s' or '%s')", envMismatch, cfg.Mismatch, mismatchError, mismatchSkip, mismatchWarn)
+	}
+
+	cfg.Retry.Retries = defaultRetries
+	if retriesValue != "" {
+		retries, err := strconv.Atoi(retriesValue)
+		if err != nil || retries < 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envRetries, retriesValue)
+		}
+		cfg.Retry.Retries = retries
+	}
+
+	cfg.Retry.BaseDelay = defaultRetryBaseDelay
+	if retryBaseDelayValue != "" {
+		delay, err := time.ParseDuration(retryBaseDelayValue)
+		if err != nil || delay <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envRetryBaseDelay, retryBaseDelayValue)
+		}
+		cfg.Retry.BaseDelay = delay
+	}
+
+	cfg.Retry.MaxDelay = defaultRetryMaxDelay
+	if retryMaxDelayValue != "" {
+		delay, err := time.ParseDuration(retryMaxDelayValue)
+		if err != nil || delay <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envRetryMaxDelay, retryMaxDelayValue)
+		}
+		cfg.Retry.MaxDelay = delay
+	}
+	if cfg.Retry.MaxDelay < cfg.Retry.BaseDelay {
+		return Config{}, fmt.Errorf("%s (%s) must not be shorter than %s (%s)", envRetryMaxDelay, cfg.Retry.MaxDelay, envRetryBaseDelay, cfg.Retry.BaseDelay)
+	}
+
+	if retryMaxElapsedValue != "" {
+		elapsed, err := time.ParseDuration(retryMaxElapsedValue)
+		if err != nil || elapsed <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envRetryMaxElapsed, retryMaxElapsedValue)
+		}
+		cfg.Retry.MaxElapsed = elapsed
+	}
+
+	if cfg.Retry.Jitter, err = parseJitter(retryJitterValue); err != nil {
+		return Config{}, err
+	}
+
+	switch cfg.HookFailure {
+	case "":
+		cfg.HookFailure = hookFailureWarn
+	case hookFailureWarn, hookFailureFatal:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s' or '%s')", envHookFailure, cfg.HookFailure, hookFailureWarn, hookFailureFatal)
+	}
+
+	switch cfg.Output {
+	case "":
+		cfg.Output = outputText
+	case outputText, outputJSON, outputReport:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s', '%s' or '%s')", envOutput, cfg.Output, outputText, outputJSON, outputReport)
+	}
+
+	if cfg.ExcludeIPs, err = parseIPNets(envExcludeIPs, excludeValue); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.IPSticky, err = parseBool(envIPSticky, stickyValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.IPSticky && cfg.StateFile == "" {
+		return Config{}, fmt.Errorf("%s requires %s", envIPSticky, envStateFile)
+	}
+	if cfg.IPShuffle, err = parseBool(envIPShuffle, shuffleValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.IPShuffle && cfg.IPSticky {
+		return Config{}, fmt.Errorf("%s cannot be combined with %s", envIPShuffle, envIPSticky)
+	}
+
+	if cfg.NotifyOn, err = parseNotifyOn(notifyOnValue); err != nil {
+		return Config{}, err
+	}
+	if (cfg.NotifyOn[notifyRecovery] || cfg.NotifyOn[notifyStreak] || cfg.NotifyOn[notifyNetwork]) && cfg.StateFile == "" {
+		return Config{}, fmt.Errorf("%s=%s requires %s", envNotifyOn, notifyOnValue, envStateFile)
+	}
+	cfg.NotifyThreshold = defaultNotifyThreshold
+	if notifyThresholdValue != "" {
+		threshold, err := strconv.Atoi(notifyThresholdValue)
+		if err != nil || threshold < 1 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envNotifyThreshold, notifyThresholdValue)
+		}
+		cfg.NotifyThreshold = threshold
+	}
+
+	if cfg.NetworkPrefixV4, err = parseNetworkPrefix(envNetworkPrefixV4, networkPrefixV4Value, defaultNetworkPrefixV4, 32); err != nil {
+		return Config{}, err
+	}
+	if cfg.NetworkPrefixV6, err = parseNetworkPrefix(envNetworkPrefixV6, networkPrefixV6Value, defaultNetworkPrefixV6, 128); err != nil {
+		return Config{}, err
+	}
+	if stuckAfterValue != "" {
+		if cfg.StuckAfter, err = time.ParseDuration(stuckAfterValue); err != nil || cfg.StuckAfter <= 0 {
+			return Config{}, fmt.Errorf("invalid %s value %q", envStuckAfter, stuckAfterValue)
 		}
-		if len(cfg.IPServices) == 0 {
-			cfg.IPServices = append([]string{}, defaultIPServices...)
+		if cfg.StateFile == "" {
+			return Config{}, fmt.Errorf("%s requires %s", envStuckAfter, envStateFile)
 		}
 	}
 
+	if cfg.IPInsecureTLS, err = parseBool(envIPInsecureTLS, insecureValue); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.AuthKey == stdinValue {
+		if cfg.AuthKey, err = readStdinSecret(envAuthKey, os.Stdin); err != nil {
+			return Config{}, err
+		}
+	}
 	if cfg.AuthKey == "" {
 		return Config{}, fmt.Errorf("%s is required", envAuthKey)
 	}
 
+	inferred := cfg.AuthMethod == authMethodAuto
+	if inferred {
+		var reason string
+		cfg.AuthMethod, reason = inferAuthMethod(cfg.AuthKey, cfg.AuthEmail)
+		log.Printf("using %s authentication: %s (set %s to override)", cfg.AuthMethod, reason, envAuthMethod)
+	}
 	switch cfg.AuthMethod {
 	case "token":
-		if cfg.AuthEmail == "" {
+		if cfg.AuthEmail == "" && !inferred {
 			log.Printf("warning: %s is empty; API tokens typically do not require it", envAuthEmail)
 		}
 	case "global":
@@ -171,65 +1221,604 @@ func loadConfig() (Config, error) {
 			return Config{}, fmt.Errorf("%s is required when %s is 'global'", envAuthEmail, envAuthMethod)
 		}
 	default:
-		return Config{}, fmt.Errorf("unsupported %s %q (must be 'token' or 'global')", envAuthMethod, cfg.AuthMethod)
+		return Config{}, fmt.Errorf("unsupported %s %q (must be 'auto', 'token' or 'global')", envAuthMethod, cfg.AuthMethod)
 	}
 
-	if cfg.ZoneID == "" {
-		return Config{}, fmt.Errorf("%s is required", envZoneID)
+	if cfg.ZoneID == "" && cfg.ZoneName == "" {
+		return Config{}, fmt.Errorf("%s or %s is required", envZoneID, envZoneName)
+	}
+
+	if cfg.RecordNames, err = parseRecordNames(cfg.RecordName, recordPattern, subdomainsValue); err != nil {
+		return Config{}, err
+	}
+	cfg.RecordName = cfg.RecordNames[0]
+	if displayNameValue != "" {
+		if cfg.DisplayNames, err = parseDisplayNames(displayNameValue, cfg.RecordNames, recordPattern, subdomainsValue); err != nil {
+			return Config{}, err
+		}
+	}
+	if cfg.IPSource == ipSourceDNSRecord {
+		for _, name := range cfg.RecordNames {
+			if strings.EqualFold(strings.TrimSuffix(name, "."), cfg.IPSourceRecord) {
+				return Config{}, fmt.Errorf("%s %q is a managed record and cannot be its own source", envIPSourceRecord, cfg.IPSourceRecord)
+			}
+		}
+	}
+
+	switch cfg.NameMatch {
+	case "":
+		cfg.NameMatch = nameMatchExact
+	case nameMatchExact, nameMatchRelative:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s' or '%s')", envNameMatch, cfg.NameMatch, nameMatchExact, nameMatchRelative)
+	}
+	if cfg.ZoneName != "" && cfg.ZoneID != "" && cfg.NameMatch != nameMatchRelative {
+		log.Printf("warning: %s is only used when %s is '%s'", envZoneName, envNameMatch, nameMatchRelative)
+	}
+
+	if cfg.CustomHostnames, err = parseBool(envCustomHostnames, customHostnamesValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.CustomHostnames && cfg.NameMatch == nameMatchRelative {
+		return Config{}, fmt.Errorf("%s cannot be combined with %s=%s", envCustomHostnames, envNameMatch, nameMatchRelative)
+	}
+
+	switch cfg.RecordMode {
+	case "":
+		cfg.RecordMode = recordModeUpdate
+	case recordModeUpdate, recordModeSync:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s' or '%s')", envRecordMode, cfg.RecordMode, recordModeUpdate, recordModeSync)
+	}
+
+	switch cfg.MultiMatch {
+	case "":
+		cfg.MultiMatch = multiMatchFirst
+	case multiMatchFirst, multiMatchAll, multiMatchError:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s', '%s' or '%s')", envMultiMatch, cfg.MultiMatch, multiMatchFirst, multiMatchAll, multiMatchError)
+	}
+
+	if cfg.Prune, err = parseBool(envPrune, pruneValue); err != nil {
+		return Config{}, err
+	}
+	if cfg.Prune && cfg.RecordMode != recordModeSync {
+		return Config{}, fmt.Errorf("%s requires %s=%s", envPrune, envRecordMode, recordModeSync)
+	}
+
+	if cfg.APIBaseURL != "" {
+		parsed, err := url.Parse(cfg.APIBaseURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return Config{}, fmt.Errorf("invalid %s value %q", envAPIBaseURL, cfg.APIBaseURL)
+		}
+		// Request paths are resolved relative to the base URL.
+		if !strings.HasSuffix(cfg.APIBaseURL, "/") {
+			cfg.APIBaseURL += "/"
+		}
+	}
+
+	if cfg.APIHostOverride != "" {
+		if cfg.APIBaseURL == "" {
+			return Config{}, fmt.Errorf("%s requires %s", envAPIHostOverride, envAPIBaseURL)
+		}
+		parsed, err := url.Parse("//" + cfg.APIHostOverride)
+		if err != nil || parsed.Host != cfg.APIHostOverride || parsed.Hostname() == "" {
+			return Config{}, fmt.Errorf("invalid %s value %q (must be a host name with an optional port)", envAPIHostOverride, cfg.APIHostOverride)
+		}
+	}
+
+	switch cfg.IPv6Prefer {
+	case "":
+		cfg.IPv6Prefer = ipv6PreferStable
+	case ipv6PreferStable, ipv6PreferTemporary:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s' or '%s')", envIPv6Prefer, cfg.IPv6Prefer, ipv6PreferStable, ipv6PreferTemporary)
+	}
+
+	switch cfg.AutoPrefer {
+	case "":
+		cfg.AutoPrefer = autoPreferIPv4
+	case autoPreferIPv4, autoPreferIPv6:
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (must be '%s' or '%s')", envAutoPrefer, cfg.AutoPrefer, autoPreferIPv4, autoPreferIPv6)
+	}
+
+	if matchPrefixValue != "" {
+		bits, err := strconv.Atoi(strings.TrimPrefix(matchPrefixValue, "/"))
+		if err != nil || bits < 1 || bits > 128 {
+			return Config{}, fmt.Errorf("invalid %s value %q (must be a prefix length between 1 and 128)", envIPv6MatchPrefix, matchPrefixValue)
+		}
+		cfg.IPv6MatchPrefix = bits
+	}
+
+	inferType, err := parseBool(envInferType, inferTypeValue)
+	if err != nil {
+		return Config{}, err
+	}
+	if inferType && explicitType {
+		log.Printf("warning: %s is set; ignoring %s", envRecordType, envInferType)
+	} else if inferType {
+		if typeSuffixesValue == "" {
+			typeSuffixesValue = defaultTypeSuffixes
+		}
+		if cfg.TypeSuffixes, err = parseTypeSuffixes(typeSuffixesValue); err != nil {
+			return Config{}, err
+		}
 	}
 
-	if cfg.RecordName == "" {
-		return Config{}, fmt.Errorf("%s is required", envRecordName)
+	switch cfg.RecordType {
+	case "A":
+	case "AAAA", recordTypeAuto:
+	case "SRV":
+		if cfg.Proxied {
+			return Config{}, fmt.Errorf("%s cannot be true for SRV records", envProxied)
+		}
+		// A content command supplies the SRV data at run time instead.
+		if cfg.ContentCommand == "" {
+			if err := parseSRVData(&cfg.SRV, srvPriorityValue, srvWeightValue, srvPortValue); err != nil {
+				return Config{}, err
+			}
+		}
+	case "CAA":
+		if cfg.Proxied {
+			return Config{}, fmt.Errorf("%s cannot be true for CAA records", envProxied)
+		}
+		if cfg.ContentCommand != "" {
+			return Config{}, fmt.Errorf("%s cannot be combined with %s=CAA", envContentCommand, envRecordType)
+		}
+		if err := parseCAAData(&cfg.CAA, caaFlagsValue); err != nil {
+			return Config{}, err
+		}
+	default:
+		return Config{}, fmt.Errorf("unsupported %s %q (only A, AAAA, AUTO, SRV and CAA records are handled)", envRecordType, cfg.RecordType)
 	}
 
-	if cfg.RecordType != "A" {
-		return Config{}, fmt.Errorf("unsupported %s %q (only A records are handled)", envRecordType, cfg.RecordType)
+	if targetsFile != "" {
+		if cfg.Targets, err = loadTargets(targetsFile); err != nil {
+			return Config{}, err
+		}
 	}
 
 	return cfg, nil
 }
 
-func discoverIP(client *http.Client, services []string) (string, error) {
+// parseRecordNames resolves the set of records to manage, either from a
+// comma-separated CF_RECORD_NAME or by expanding CF_RECORD_PATTERN once per
+// entry in CF_SUBDOMAINS.
+func parseRecordNames(namesValue, pattern, subdomainsValue string) ([]string, error) {
+	if pattern != "" {
+		if namesValue != "" {
+			return nil, fmt.Errorf("%s and %s cannot both be set", envRecordName, envRecordPattern)
+		}
+		if !strings.Contains(pattern, subdomainPlaceholder) {
+			return nil, fmt.Errorf("%s %q must contain %s", envRecordPattern, pattern, subdomainPlaceholder)
+		}
+
+		subdomains := splitList(subdomainsValue)
+		if len(subdomains) == 0 {
+			return nil, fmt.Errorf("%s is required when %s is set", envSubdomains, envRecordPattern)
+		}
+
+		names := make([]string, 0, len(subdomains))
+		for _, sub := range subdomains {
+			names = append(names, strings.ReplaceAll(pattern, subdomainPlaceholder, sub))
+		}
+		return names, nil
+	}
+
+	names := splitList(namesValue)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s is required", envRecordName)
+	}
+	return names, nil
+}
+
+// parseDisplayNames pairs the labels in CF_RECORD_DISPLAY_NAME with names,
+// in order. With CF_RECORD_PATTERN, a single label containing {sub} is
+// expanded once per entry in CF_SUBDOMAINS instead. The result is keyed by
+// normalized record name.
+func parseDisplayNames(value string, names []string, pattern, subdomainsValue string) (map[string]string, error) {
+	var labels []string
+	if pattern != "" && strings.Contains(value, subdomainPlaceholder) {
+		for _, sub := range splitList(subdomainsValue) {
+			labels = append(labels, strings.ReplaceAll(strings.TrimSpace(value), subdomainPlaceholder, sub))
+		}
+	} else {
+		labels = splitList(value)
+	}
+	if len(labels) != len(names) {
+		return nil, fmt.Errorf("%s has %d label(s) but %d record name(s) are configured", envDisplayName, len(labels), len(names))
+	}
+
+	displayNames := make(map[string]string, len(names))
+	for i, name := range names {
+		displayNames[normalizeName(name, "")] = labels[i]
+	}
+	return displayNames, nil
+}
+
+// displayName returns the label CF_RECORD_DISPLAY_NAME gives name in logs
+// and notifications, or name itself. Names qualified under
+// CF_NAME_MATCH=relative are found by their configured short form.
+func (c Config) displayName(name string) string {
+	if label, ok := c.DisplayNames[normalizeName(name, "")]; ok {
+		return label
+	}
+	if label, ok := c.DisplayNames[normalizeName(name, c.ZoneName)]; ok && c.ZoneName != "" {
+		return label
+	}
+	return name
+}
+
+// splitList splits a comma-separated value, trimming entries and dropping
+// empty ones.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
+
+// parseIPServices parses CF_IP_SERVICES. Each entry may carry a priority as
+// url|N; entries are ordered by descending priority, and entries without one
+// have priority 0 and keep their relative order.
+func parseIPServices(value string) ([]string, error) {
+	type service struct {
+		url      string
+		priority int
+	}
+
+	var services []service
+	for _, entry := range splitList(value) {
+		svc := service{url: entry}
+		if rawURL, priority, ok := strings.Cut(entry, "|"); ok {
+			p, err := strconv.Atoi(strings.TrimSpace(priority))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s priority in %q", envIPServices, entry)
+			}
+			svc = service{url: strings.TrimSpace(rawURL), priority: p}
+		}
+		services = append(services, svc)
+	}
+
+	sort.SliceStable(services, func(i, j int) bool {
+		return services[i].priority > services[j].priority
+	})
+
+	urls := make([]string, 0, len(services))
 	for _, svc := range services {
-		req, err := http.NewRequest(http.MethodGet, svc, nil)
-		if err != nil {
-			continue
+		urls = append(urls, svc.url)
+	}
+	return urls, nil
+}
+
+// parseBool interprets an optional boolean variable, treating an empty value as
+// false. Besides true and false it accepts 1/0, yes/no and on/off, in any
+// case.
+func parseBool(name, value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "0", "no", "off":
+		return false, nil
+	case "true", "1", "yes", "on":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s value %q", name, value)
+	}
+}
+
+// envReader reads configuration variables, honoring the NAME_FILE convention
+// used by Docker secrets and Kubernetes volume mounts, and falling back to
+// CF_CONFIG_JSON. It records the first error encountered so callers can read
+// several variables before checking.
+type envReader struct {
+	err error
+
+	// configJSON holds the values from CF_CONFIG_JSON, decoded on first use.
+	configJSON       map[string]string
+	configJSONLoaded bool
+}
+
+// get returns the trimmed value of name. When name is unset or empty and
+// name_FILE points at a file, the trimmed file contents are returned instead,
+// and otherwise the value given for name in CF_CONFIG_JSON.
+func (r *envReader) get(name string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+
+	path := strings.TrimSpace(os.Getenv(name + fileEnvSuffix))
+	if path == "" {
+		return r.fromConfigJSON(name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("failed to read %s%s: %w", name, fileEnvSuffix, err)
 		}
+		return ""
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
+	return strings.TrimSpace(string(data))
+}
+
+// fromConfigJSON returns the value of name in CF_CONFIG_JSON, which may
+// itself be read from CF_CONFIG_JSON_FILE.
+func (r *envReader) fromConfigJSON(name string) string {
+	if name == envConfigJSON {
+		return ""
+	}
+	if !r.configJSONLoaded {
+		r.configJSONLoaded = true
+		if data := r.get(envConfigJSON); data != "" {
+			values, err := parseConfigJSON(data)
+			if err != nil && r.err == nil {
+				r.err = err
+			}
+			r.configJSON = values
+		}
+	}
+	return r.configJSON[name]
+}
+
+// stdinValue, as the value of CF_AUTH_KEY, reads the key from stdin instead.
+const stdinValue = "-"
+
+// readStdinSecret reads the value of name piped to the updater on stdin. It
+// is read once, at startup. A terminal is rejected rather than waiting for
+// input nobody is going to type.
+func readStdinSecret(name string, stdin *os.File) (string, error) {
+	info, err := stdin.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from stdin: %w", name, err)
+	}
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return "", fmt.Errorf("%s=%s requires the value to be piped to stdin, not a terminal", name, stdinValue)
+	}
+
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from stdin: %w", name, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("%s=%s but stdin was empty", name, stdinValue)
+	}
+	return value, nil
+}
+
+// discoverIP queries services in order and returns the first valid address
+// of family along with the service that reported it. An IPv4-mapped IPv6
+// answer such as ::ffff:203.0.113.10 counts as IPv4 and is returned in
+// dotted-quad form; it is never accepted as IPv6. A service answering with
+// an empty or unparsable body is asked again up to retries times before
+// moving on to the next one. When ctx was derived with withDiscoveryTimeout
+// and the deadline passes, the services not yet asked are logged and an
+// error wrapping errDiscoveryTimeout is returned. When every service fails
+// and one of them answered with an address of the other family, the error
+// wraps a *familyMismatchError for CF_MISMATCH.
+func discoverIP(ctx context.Context, client *http.Client, services []string, family string, retries int, observe queryObserver) (string, string, error) {
+	var mismatch *familyMismatchError
+	for i, svc := range services {
+		ip, err := queryIPServiceRetrying(ctx, client, svc, family, retries)
+		if ctx.Err() == nil {
+			observe.record(svc, err)
+		}
+		if err == nil {
+			return ip, svc, nil
+		}
+		if ctx.Err() != nil {
+			if cause := context.Cause(ctx); errors.Is(cause, errDiscoveryTimeout) {
+				logSkippedServices(svc, services[i+1:])
+				return "", "", fmt.Errorf("unable to discover %s address: %w", family, cause)
+			}
+			return "", "", ctx.Err()
+		}
+		errors.As(err, &mismatch)
+	}
+
+	if mismatch != nil {
+		return "", "", fmt.Errorf("unable to discover %s address from configured services: %w", family, mismatch)
+	}
+	return "", "", fmt.Errorf("unable to discover %s address from configured services", family)
+}
+
+// queryIPServiceRetrying queries svc, asking again up to retries times while
+// it answers with an empty or invalid body. Failures are logged.
+func queryIPServiceRetrying(ctx context.Context, client *http.Client, svc, family string, retries int) (string, error) {
+	for attempt := 0; ; attempt++ {
+		ip, err := queryIPService(ctx, client, svc, family)
+		if err == nil || ctx.Err() != nil {
+			return ip, err
+		}
+
+		var bodyErr *ipBodyError
+		if !errors.As(err, &bodyErr) {
 			log.Printf("failed to query %s: %v", svc, err)
-			continue
+			return "", err
+		}
+		log.Printf("%v from %s", bodyErr.Err, svc)
+		if attempt >= retries {
+			return "", err
 		}
+	}
+}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			log.Printf("failed to read response from %s: %v", svc, err)
+// ipBodyError reports a response body that did not contain a usable address,
+// which is worth retrying unlike a failed request.
+type ipBodyError struct {
+	Err error
+}
+
+func (e *ipBodyError) Error() string { return e.Err.Error() }
+
+func (e *ipBodyError) Unwrap() error { return e.Err }
+
+// queryIPService asks one service for the public address of family.
+func queryIPService(ctx context.Context, client *http.Client, svc, family string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, svc, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if strings.TrimSpace(string(body)) == "" {
+		return "", &ipBodyError{Err: errors.New("empty response")}
+	}
+	parse := parseIPv4
+	if family == familyIPv6 {
+		parse = parseIPv6
+	}
+	ip, err := parse(string(body))
+	if err != nil {
+		return "", &ipBodyError{Err: err}
+	}
+	return ip, nil
+}
+
+// parseIPv4 validates a textual address reported by a discovery source and
+// returns it in dotted-quad form. Some services report IPv4 as an
+// IPv4-mapped IPv6 address; it is unmapped.
+func parseIPv4(raw string) (string, error) {
+	ip := strings.TrimSpace(raw)
+	parsed, err := parseDiscoveredAddr(ip)
+	if err != nil {
+		return "", err
+	}
+
+	parsed = parsed.Unmap()
+	if !parsed.Is4() {
+		return "", &familyMismatchError{Addr: ip, Got: familyIPv6, Want: familyIPv4}
+	}
+
+	return parsed.String(), nil
+}
+
+// parseIPv6 validates a textual IPv6 address reported by a discovery service
+// and returns it in canonical form. An IPv4-mapped address is rejected: it
+// stands for an IPv4 address and must not be published as AAAA.
+func parseIPv6(raw string) (string, error) {
+	ip := strings.TrimSpace(raw)
+	parsed, err := parseDiscoveredAddr(ip)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Is4In6() {
+		return "", fmt.Errorf("IPv4-mapped address %q is not an IPv6 address", ip)
+	}
+	if !parsed.Is6() {
+		return "", &familyMismatchError{Addr: ip, Got: familyIPv4, Want: familyIPv6}
+	}
+	return parsed.String(), nil
+}
+
+// parseDiscoveredAddr parses a discovered address. Zoned addresses are
+// link-local and never a public address, so they are invalid.
+func parseDiscoveredAddr(ip string) (netip.Addr, error) {
+	parsed, err := netip.ParseAddr(ip)
+	if err != nil || parsed.Zone() != "" {
+		return netip.Addr{}, fmt.Errorf("invalid IP %q", ip)
+	}
+	return parsed, nil
+}
+
+// parseIPNets parses a comma-separated list of IP addresses and CIDR ranges.
+// Bare addresses are treated as single-host networks.
+func parseIPNets(name, value string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
 			continue
 		}
 
-		ip := strings.TrimSpace(string(body))
-		parsed := net.ParseIP(ip)
-		if parsed == nil {
-			log.Printf("invalid IP %q from %s", ip, svc)
+		if strings.Contains(item, "/") {
+			_, network, err := net.ParseCIDR(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s entry %q", name, item)
+			}
+			nets = append(nets, network)
 			continue
 		}
 
-		parsed4 := parsed.To4()
-		if parsed4 == nil {
-			log.Printf("non-IPv4 address %q from %s", ip, svc)
-			continue
+		ip := net.ParseIP(item)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid %s entry %q", name, item)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
 		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
 
-		return parsed4.String(), nil
+	return nets, nil
+}
+
+// isExcludedIP reports whether ip falls inside any of the excluded networks.
+func isExcludedIP(ip string, excluded []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
 	}
 
-	return "", errors.New("unable to discover IPv4 address from configured services")
+	for _, network := range excluded {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
 }
 
-func newCloudflareClient(httpClient *http.Client, cfg Config) (*cloudflare.Client, error) {
-	options := []option.RequestOption{option.WithHTTPClient(httpClient)}
+// newCloudflareClient builds an SDK client whose transport is httpClient's,
+// wrapped by the default middleware chain and then by any extra middlewares.
+// From outermost to innermost the chain is:
+//
+//  1. extra middlewares, in the order given
+//  2. retryMiddleware, configured from cfg.Retry
+//  3. budget.middleware, only when budget is not nil, so every attempt of
+//     a retried call counts against CF_MAX_API_CALLS
+//  4. userAgentMiddleware
+//  5. hostOverrideMiddleware, only when cfg.APIHostOverride is set
+//  6. httpClient.Transport (http.DefaultTransport when nil), which then
+//     also sends cfg.APIHostOverride as the TLS server name
+//
+// The SDK's built-in retries are disabled so retryMiddleware is the only
+// retry layer.
+func newCloudflareClient(httpClient *http.Client, cfg Config, budget *callBudget, middlewares ...Middleware) (*cloudflare.Client, error) {
+	chain := append(append([]Middleware{}, middlewares...), retryMiddleware(cfg.Retry, systemClock))
+	if budget != nil {
+		chain = append(chain, budget.middleware)
+	}
+	chain = append(chain, userAgentMiddleware)
+	if cfg.APIHostOverride != "" {
+		chain = append(chain, hostOverrideMiddleware(cfg.APIHostOverride))
+		host, _, err := net.SplitHostPort(cfg.APIHostOverride)
+		if err != nil {
+			host = cfg.APIHostOverride
+		}
+		httpClient = serverNameClient(httpClient, host)
+	}
+	options := []option.RequestOption{
+		option.WithHTTPClient(withMiddlewares(httpClient, chain...)),
+		option.WithMaxRetries(0),
+	}
+	if cfg.APIBaseURL != "" {
+		options = append(options, option.WithBaseURL(cfg.APIBaseURL))
+	}
 
 	switch cfg.AuthMethod {
 	case "token":
@@ -243,47 +1832,94 @@ func newCloudflareClient(httpClient *http.Client, cfg Config) (*cloudflare.Clien
 	return cloudflare.NewClient(options...), nil
 }
 
-func fetchDNSRecord(ctx context.Context, client *cloudflare.Client, cfg Config) (dns.Record, error) {
-	params := dns.RecordListParams{
-		ZoneID: cloudflare.String(cfg.ZoneID),
-		Name:   cloudflare.String(cfg.RecordName),
-		Type:   cloudflare.F(dns.RecordListParamsType(cfg.RecordType)),
+// pickRecord chooses the record to manage among records, which all carry
+// cfg.RecordName: the one holding cfg.MatchContent when set, otherwise the
+// first carrying cfg.RecordTag, or else the first. With CF_MULTI_MATCH=error,
+// several records and no CF_MATCH_CONTENT is an error wrapping
+// errMultipleRecords.
+func pickRecord(records []dns.Record, cfg Config) (dns.Record, error) {
+	if cfg.MatchContent != "" {
+		for _, record := range records {
+			if content, err := extractRecordContent(record); err == nil && content == cfg.MatchContent {
+				return record, nil
+			}
+		}
+		return dns.Record{}, fmt.Errorf("%w for %s with content %s", errRecordNotFound, cfg.RecordName, cfg.MatchContent)
 	}
 
-	page, err := client.DNS.Records.List(ctx, params)
-	if err != nil {
-		return dns.Record{}, err
+	if len(records) == 0 {
+		return dns.Record{}, fmt.Errorf("%w for %s", errRecordNotFound, cfg.RecordName)
 	}
-
-	if len(page.Result) == 0 {
-		return dns.Record{}, fmt.Errorf("no matching record for %s", cfg.RecordName)
+	if len(records) > 1 && cfg.MultiMatch == multiMatchError {
+		return dns.Record{}, fmt.Errorf("%w: %d records for %s (%s=%s)", errMultipleRecords, len(records), cfg.RecordName, envMultiMatch, multiMatchError)
 	}
 
-	return page.Result[0], nil
-}
-
-func extractARecordIP(record dns.Record) (string, error) {
-	union := record.AsUnion()
-	aRecord, ok := union.(dns.ARecord)
-	if !ok {
-		return "", fmt.Errorf("record type %q is not supported", record.Type)
+	// A record already carrying CF_RECORD_TAG wins over untagged ones.
+	if cfg.RecordTag != "" {
+		for _, record := range records {
+			if hasTag(recordTags(record), cfg.RecordTag) {
+				return record, nil
+			}
+		}
 	}
-
-	return strings.TrimSpace(aRecord.Content), nil
+	return records[0], nil
 }
 
-func updateDNSRecord(ctx context.Context, client *cloudflare.Client, cfg Config, recordID, newIP string) error {
-	params := dns.RecordUpdateParams{
-		ZoneID: cloudflare.String(cfg.ZoneID),
-		Record: dns.ARecordParam{
+// recordParam builds the full record body for cfg.RecordName. For SRV and CAA
+// records the data is taken from cfg.SRV or cfg.CAA and content is ignored.
+// The comment is set in sync mode and with CF_AUDIT_COMMENT, and
+// CF_RECORD_TAG is added to the record's tags.
+func recordParam(cfg Config, content string) dns.RecordUnionParam {
+	comment := recordComment(cfg)
+
+	switch cfg.RecordType {
+	case "AAAA":
+		record := dns.AAAARecordParam{
+			Name:    cloudflare.String(cfg.RecordName),
+			Content: cloudflare.String(content),
+			Type:    cloudflare.F(dns.AAAARecordTypeAAAA),
+			TTL:     cloudflare.F(dns.TTL(float64(cfg.TTL))),
+			Proxied: cloudflare.F(cfg.Proxied),
+		}
+		if comment != "" {
+			record.Comment = cloudflare.String(comment)
+		}
+		if cfg.RecordTag != "" {
+			record.Tags = cloudflare.F(withTag(cfg.Tags, cfg.RecordTag))
+		}
+		return record
+	case "SRV":
+		record := srvRecordParam(cfg)
+		if comment != "" {
+			record.Comment = cloudflare.String(comment)
+		}
+		if cfg.RecordTag != "" {
+			record.Tags = cloudflare.F(withTag(cfg.Tags, cfg.RecordTag))
+		}
+		return record
+	case "CAA":
+		record := caaRecordParam(cfg)
+		if comment != "" {
+			record.Comment = cloudflare.String(comment)
+		}
+		if cfg.RecordTag != "" {
+			record.Tags = cloudflare.F(withTag(cfg.Tags, cfg.RecordTag))
+		}
+		return record
+	default:
+		record := dns.ARecordParam{
 			Name:    cloudflare.String(cfg.RecordName),
-			Content: cloudflare.String(newIP),
+			Content: cloudflare.String(content),
 			Type:    cloudflare.F(dns.ARecordTypeA),
 			TTL:     cloudflare.F(dns.TTL(float64(cfg.TTL))),
 			Proxied: cloudflare.F(cfg.Proxied),
-		},
+		}
+		if comment != "" {
+			record.Comment = cloudflare.String(comment)
+		}
+		if cfg.RecordTag != "" {
+			record.Tags = cloudflare.F(withTag(cfg.Tags, cfg.RecordTag))
+		}
+		return record
 	}
-
-	_, err := client.DNS.Records.Update(ctx, recordID, params)
-	return err
 }
@@ -4,10 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -62,6 +68,31 @@ func TestLoadConfigDefaults(t *testing.T) {
 	}
 }
 
+func TestLoadConfigProxiedForms(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+
+	cases := map[string]bool{
+		"true": true, "TRUE": true, "1": true, "yes": true, "Yes": true, "on": true, "ON": true,
+		"false": false, "0": false, "no": false, "NO": false, "off": false, "Off": false, "": false,
+	}
+	for value, want := range cases {
+		t.Setenv(envProxied, value)
+		cfg, err := loadConfig()
+		if err != nil || cfg.Proxied != want {
+			t.Errorf("%s=%q: got %v, %v; want %v", envProxied, value, cfg.Proxied, err, want)
+		}
+	}
+
+	for _, value := range []string{"2", "y", "enabled"} {
+		t.Setenv(envProxied, value)
+		if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envProxied) {
+			t.Errorf("%s=%q: expected an error, got %v", envProxied, value, err)
+		}
+	}
+}
+
 func TestLoadConfigMissingAuthKey(t *testing.T) {
 	t.Setenv(envAuthKey, "")
 	t.Setenv(envZoneID, "zone-id")
@@ -72,6 +103,178 @@ func TestLoadConfigMissingAuthKey(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	zoneFile := filepath.Join(dir, "zone-id")
+	if err := os.WriteFile(zoneFile, []byte("file-zone\n"), 0o600); err != nil {
+		t.Fatalf("write zone file: %v", err)
+	}
+	nameFile := filepath.Join(dir, "record-name")
+	if err := os.WriteFile(nameFile, []byte("  file.example.com  "), 0o600); err != nil {
+		t.Fatalf("write name file: %v", err)
+	}
+
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "")
+	t.Setenv(envZoneID+fileEnvSuffix, zoneFile)
+	t.Setenv(envRecordName, "inline.example.com")
+	t.Setenv(envRecordName+fileEnvSuffix, nameFile)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.ZoneID != "file-zone" {
+		t.Fatalf("expected zone from file, got %q", cfg.ZoneID)
+	}
+	if cfg.RecordName != "inline.example.com" {
+		t.Fatalf("expected inline record name to take precedence, got %q", cfg.RecordName)
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "")
+	t.Setenv(envZoneID+fileEnvSuffix, filepath.Join(t.TempDir(), "missing"))
+	t.Setenv(envRecordName, "example.com")
+
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected error when referenced file is missing")
+	}
+}
+
+func TestReadStdinSecret(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	w.Write([]byte("piped-token\n"))
+	w.Close()
+
+	value, err := readStdinSecret(envAuthKey, r)
+	if err != nil || value != "piped-token" {
+		t.Fatalf("expected piped token, got %q, %v", value, err)
+	}
+
+	empty, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	t.Cleanup(func() { empty.Close() })
+	w.Close()
+	if _, err := readStdinSecret(envAuthKey, empty); err == nil {
+		t.Fatalf("expected error for empty stdin")
+	}
+}
+
+func TestLoadConfigExcludeIPs(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+	t.Setenv(envExcludeIPs, "198.51.100.7, 10.8.0.0/16,2001:db8::/32")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cases := map[string]bool{
+		"198.51.100.7": true,
+		"198.51.100.8": false,
+		"10.8.4.1":     true,
+		"10.9.0.1":     false,
+		"2001:db8::1":  true,
+	}
+	for ip, want := range cases {
+		if got := isExcludedIP(ip, cfg.ExcludeIPs); got != want {
+			t.Fatalf("isExcludedIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	t.Setenv(envExcludeIPs, "10.0.0.0/33")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected error for invalid CIDR")
+	}
+}
+
+func TestLoadConfigRecordPattern(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "")
+	t.Setenv(envRecordPattern, "{sub}.example.com")
+	t.Setenv(envSubdomains, "api, www,cdn")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []string{"api.example.com", "www.example.com", "cdn.example.com"}
+	if !reflect.DeepEqual(cfg.RecordNames, expected) {
+		t.Fatalf("unexpected record names %v", cfg.RecordNames)
+	}
+	if cfg.RecordName != "api.example.com" {
+		t.Fatalf("expected first record name, got %q", cfg.RecordName)
+	}
+
+	t.Setenv(envRecordName, "home.example.com")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected error when both name and pattern are set")
+	}
+}
+
+func TestLoadConfigDisplayNames(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "a.example.com,B.example.com")
+	t.Setenv(envDisplayName, "Router, NAS")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := cfg.displayName("b.example.com."); got != "NAS" {
+		t.Fatalf("expected the label for b.example.com, got %q", got)
+	}
+	if got := cfg.displayName("c.example.com"); got != "c.example.com" {
+		t.Fatalf("expected an unlabelled name to be shown as is, got %q", got)
+	}
+
+	t.Setenv(envDisplayName, "Router")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envDisplayName) {
+		t.Fatalf("expected a label count mismatch to be rejected, got %v", err)
+	}
+
+	t.Setenv(envRecordName, "")
+	t.Setenv(envRecordPattern, "{sub}.example.com")
+	t.Setenv(envSubdomains, "api,www")
+	t.Setenv(envDisplayName, "{sub} frontend")
+	if cfg, err = loadConfig(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := cfg.displayName("www.example.com"); got != "www frontend" {
+		t.Fatalf("expected the expanded label, got %q", got)
+	}
+}
+
+func TestLoadConfigMultipleRecordNames(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "a.example.com,b.example.com")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := []string{"a.example.com", "b.example.com"}
+	if !reflect.DeepEqual(cfg.RecordNames, expected) {
+		t.Fatalf("unexpected record names %v", cfg.RecordNames)
+	}
+}
+
 func TestDiscoverIP(t *testing.T) {
 	invalidServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -90,7 +293,7 @@ func TestDiscoverIP(t *testing.T) {
 
 	client := &http.Client{}
 
-	ip, err := discoverIP(client, []string{invalidServer.URL, badIPServer.URL, validServer.URL})
+	ip, service, err := discoverIP(context.Background(), client, []string{invalidServer.URL, badIPServer.URL, validServer.URL}, familyIPv4, 0, nil)
 	if err != nil {
 		t.Fatalf("expected success, got %v", err)
 	}
@@ -98,6 +301,9 @@ func TestDiscoverIP(t *testing.T) {
 	if ip != "203.0.113.10" {
 		t.Fatalf("unexpected IP %s", ip)
 	}
+	if service != validServer.URL {
+		t.Fatalf("unexpected service %s", service)
+	}
 }
 
 func TestDiscoverIPAllFail(t *testing.T) {
@@ -108,11 +314,36 @@ func TestDiscoverIPAllFail(t *testing.T) {
 
 	client := &http.Client{}
 
-	if _, err := discoverIP(client, []string{server.URL}); err == nil {
+	if _, _, err := discoverIP(context.Background(), client, []string{server.URL}, familyIPv4, 0, nil); err == nil {
 		t.Fatalf("expected error when all services fail")
 	}
 }
 
+func TestDiscoverIPRetriesGarbledBody(t *testing.T) {
+	var calls int
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			return
+		}
+		w.Write([]byte("203.0.113.10\n"))
+	}))
+	t.Cleanup(flaky.Close)
+
+	ip, service, err := discoverIP(context.Background(), &http.Client{}, []string{flaky.URL}, familyIPv4, 1, nil)
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if ip != "203.0.113.10" || service != flaky.URL || calls != 2 {
+		t.Fatalf("unexpected result %s from %s after %d calls", ip, service, calls)
+	}
+
+	calls = 0
+	if _, _, err := discoverIP(context.Background(), &http.Client{}, []string{flaky.URL}, familyIPv4, 0, nil); err == nil {
+		t.Fatalf("expected failure without retries")
+	}
+}
+
 func TestFetchDNSRecord(t *testing.T) {
 	responsePayload := map[string]any{
 		"success":  true,
@@ -148,7 +379,7 @@ func TestFetchDNSRecord(t *testing.T) {
 	var capturedAuth string
 
 	httpClient := &http.Client{
-		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
 			capturedAuth = req.Header.Get("Authorization")
 			expectedPath := "/client/v4/zones/zone-id/dns_records"
 			if req.URL.Path != expectedPath {
@@ -176,12 +407,12 @@ func TestFetchDNSRecord(t *testing.T) {
 		RecordType: "A",
 	}
 
-	client, err := newCloudflareClient(httpClient, cfg)
+	client, err := newCloudflareClient(httpClient, cfg, nil)
 	if err != nil {
 		t.Fatalf("unexpected client error: %v", err)
 	}
 
-	record, err := fetchDNSRecord(context.Background(), client, cfg)
+	record, err := cloudflareProvider{client: client}.Fetch(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -197,11 +428,112 @@ func TestFetchDNSRecord(t *testing.T) {
 	}
 }
 
+func TestFetchDNSRecordMatchContent(t *testing.T) {
+	payload, err := json.Marshal(map[string]any{
+		"success": true, "errors": []any{}, "messages": []any{},
+		"result": []map[string]any{
+			aRecordFixture("record-1", "example.com", "10.0.0.1"),
+			aRecordFixture("record-2", "example.com", "198.51.100.2"),
+		},
+		"result_info": map[string]any{"page": 1, "per_page": 100},
+	})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	cfg := Config{
+		AuthMethod: "token",
+		AuthKey:    "token-value",
+		ZoneID:     "zone-id",
+		RecordName: "example.com",
+		RecordType: "A",
+	}
+	client, err := newCloudflareClient(staticJSONClient(http.StatusOK, string(payload)), cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	records, err := cloudflareProvider{client: client}.FetchRecords(context.Background(), cfg)
+	if err != nil || len(records) != 2 || records[0].ID != "record-1" || records[1].ID != "record-2" {
+		t.Fatalf("expected every match in listing order, got %+v %v", records, err)
+	}
+
+	record, err := cloudflareProvider{client: client}.Fetch(context.Background(), cfg)
+	if err != nil || record.ID != "record-1" {
+		t.Fatalf("expected first record without a filter, got %s %v", record.ID, err)
+	}
+
+	cfg.MatchContent = "198.51.100.2"
+	record, err = cloudflareProvider{client: client}.Fetch(context.Background(), cfg)
+	if err != nil || record.ID != "record-2" {
+		t.Fatalf("expected matching record, got %s %v", record.ID, err)
+	}
+
+	cfg.MatchContent = "203.0.113.10"
+	if _, err := (cloudflareProvider{client: client}).Fetch(context.Background(), cfg); !errors.Is(err, errRecordNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestFetchRecordsPaginates(t *testing.T) {
+	var pages []string
+	httpClient := &http.Client{
+		Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			page := req.URL.Query().Get("page")
+			pages = append(pages, page)
+			count := listPerPage
+			if page != "1" {
+				count = 1
+			}
+			result := make([]map[string]any, count)
+			for i := range result {
+				result[i] = aRecordFixture(fmt.Sprintf("record-%s-%d", page, i), "example.com", "198.51.100.1")
+			}
+			payload, _ := json.Marshal(map[string]any{
+				"success": true, "errors": []any{}, "messages": []any{}, "result": result,
+			})
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"application/json"}}, Body: io.NopCloser(bytes.NewReader(payload))}, nil
+		}),
+	}
+	cfg := Config{AuthMethod: "token", AuthKey: "token-value", ZoneID: "zone-id", RecordName: "example.com", RecordType: "A"}
+	client, err := newCloudflareClient(httpClient, cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	records, err := cloudflareProvider{client: client}.FetchRecords(context.Background(), cfg)
+	if err != nil || len(records) != listPerPage+1 || records[listPerPage].ID != "record-2-0" {
+		t.Fatalf("expected both pages, got %d records, %v", len(records), err)
+	}
+	if !slices.Equal(pages, []string{"1", "2"}) {
+		t.Fatalf("expected two page requests, got %v", pages)
+	}
+}
+
+func TestLoadConfigMultiMatch(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+
+	cfg, err := loadConfig()
+	if err != nil || cfg.MultiMatch != multiMatchFirst {
+		t.Fatalf("expected %s to default to %s, got %q, %v", envMultiMatch, multiMatchFirst, cfg.MultiMatch, err)
+	}
+	t.Setenv(envMultiMatch, "ALL")
+	if cfg, err := loadConfig(); err != nil || cfg.MultiMatch != multiMatchAll {
+		t.Fatalf("expected %s=all, got %q, %v", envMultiMatch, cfg.MultiMatch, err)
+	}
+	t.Setenv(envMultiMatch, "newest")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected an unsupported %s to be rejected", envMultiMatch)
+	}
+}
+
 func TestUpdateDNSRecord(t *testing.T) {
 	var receivedBody []byte
 
 	httpClient := &http.Client{
-		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
 			if req.Method != http.MethodPut {
 				t.Fatalf("expected PUT, got %s", req.Method)
 			}
@@ -249,12 +581,12 @@ func TestUpdateDNSRecord(t *testing.T) {
 		Proxied:    true,
 	}
 
-	client, err := newCloudflareClient(httpClient, cfg)
+	client, err := newCloudflareClient(httpClient, cfg, nil)
 	if err != nil {
 		t.Fatalf("unexpected client error: %v", err)
 	}
 
-	if err := updateDNSRecord(context.Background(), client, cfg, "record-id", "198.51.100.3"); err != nil {
+	if err := (cloudflareProvider{client: client}).Update(context.Background(), cfg, "record-id", "198.51.100.3"); err != nil {
 		t.Fatalf("expected success, got %v", err)
 	}
 
@@ -273,8 +605,90 @@ func TestUpdateDNSRecord(t *testing.T) {
 	}
 }
 
-type roundTripperFunc func(*http.Request) (*http.Response, error)
+func TestLoadConfigPruneRequiresSync(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+	t.Setenv(envPrune, "true")
+
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected %s without %s=sync to fail", envPrune, envRecordMode)
+	}
+
+	t.Setenv(envRecordMode, "SYNC")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RecordMode != recordModeSync || !cfg.Prune {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
+
+func TestLoadConfigShuffleRejectsSticky(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+	t.Setenv(envStateFile, filepath.Join(t.TempDir(), "state.json"))
+	t.Setenv(envIPShuffle, "true")
+	t.Setenv(envIPSticky, "true")
+
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected %s with %s to fail", envIPShuffle, envIPSticky)
+	}
+
+	t.Setenv(envIPSticky, "false")
+	cfg, err := loadConfig()
+	if err != nil || !cfg.IPShuffle {
+		t.Fatalf("unexpected config %+v, %v", cfg, err)
+	}
+}
+
+func TestParseIPServicesPriority(t *testing.T) {
+	got, err := parseIPServices("https://a.example, https://b.example|5, https://c.example, https://self.example|10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"https://self.example", "https://b.example", "https://a.example", "https://c.example"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if _, err := parseIPServices("https://a.example|high"); err == nil {
+		t.Fatalf("expected error for invalid priority")
+	}
+}
 
-func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
-	return f(req)
+func TestParseDiscoveredIPv4Mapped(t *testing.T) {
+	if ip, err := parseIPv4(" ::ffff:203.0.113.10\n"); err != nil || ip != "203.0.113.10" {
+		t.Fatalf("expected the mapped address unmapped, got %q, %v", ip, err)
+	}
+	if _, err := parseIPv4("2001:db8::1"); err == nil {
+		t.Fatalf("expected an IPv6 address to be rejected as IPv4")
+	}
+
+	if _, err := parseIPv6("::ffff:203.0.113.10"); err == nil || !strings.Contains(err.Error(), "IPv4-mapped") {
+		t.Fatalf("expected a mapped address to be rejected as IPv6, got %v", err)
+	}
+	if _, err := parseIPv6("fe80::1%eth0"); err == nil {
+		t.Fatalf("expected a zoned address to be rejected")
+	}
+	if ip, err := parseIPv6("2001:DB8:0::1"); err != nil || ip != "2001:db8::1" {
+		t.Fatalf("expected the canonical IPv6 form, got %q, %v", ip, err)
+	}
+}
+
+func TestDiscoverIPMappedAddress(t *testing.T) {
+	mapped := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("::ffff:203.0.113.10"))
+	}))
+	t.Cleanup(mapped.Close)
+
+	ip, _, err := discoverIP(context.Background(), &http.Client{}, []string{mapped.URL}, familyIPv4, 0, nil)
+	if err != nil || ip != "203.0.113.10" {
+		t.Fatalf("expected the dotted-quad form, got %q, %v", ip, err)
+	}
+	if _, _, err := discoverIP(context.Background(), &http.Client{}, []string{mapped.URL}, familyIPv6, 0, nil); err == nil {
+		t.Fatalf("expected a mapped address not to be published as AAAA")
+	}
 }
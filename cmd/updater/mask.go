@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ipPattern finds candidates for IPv6 and IPv4 addresses in free text.
+// Matches that do not parse as an address are left alone, which keeps
+// times such as 12:00:00 intact.
+var ipPattern = regexp.MustCompile(`(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f.]*|\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+// maskIP hides the host part of addr for CF_MASK_IP: the last octet of an
+// IPv4 address, as in 203.0.113.x, and everything after the /64 prefix of
+// an IPv6 address, as in 2001:db8:1:2::x.
+func maskIP(addr netip.Addr) string {
+	addr = addr.WithZone("")
+	if addr.Is4In6() {
+		addr = addr.Unmap()
+	}
+	if addr.Is4() {
+		b := addr.As4()
+		b[3] = 0
+		return strings.TrimSuffix(netip.AddrFrom4(b).String(), "0") + "x"
+	}
+	prefix := netip.PrefixFrom(addr, 64).Masked().Addr().String()
+	if !strings.HasSuffix(prefix, "::") {
+		prefix += ":"
+	}
+	return prefix + "x"
+}
+
+// maskIPs returns text with every address in it masked by maskIP.
+func maskIPs(text string) string {
+	return ipPattern.ReplaceAllStringFunc(text, func(match string) string {
+		// A colon may end the sentence rather than the address.
+		candidate := strings.TrimRight(match, ":.")
+		addr, err := netip.ParseAddr(candidate)
+		if err != nil {
+			return match
+		}
+		return maskIP(addr) + match[len(candidate):]
+	})
+}
+
+// ipMasker passes log output through to out with addresses masked, so logs
+// can be shared without revealing the public IP.
+type ipMasker struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (w *ipMasker) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := io.WriteString(w.out, maskIPs(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// setOutput redirects the masked output, for when the log destination is
+// only known after the first lines may have been logged.
+func (w *ipMasker) setOutput(out io.Writer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.out = out
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/derek/cloudflare-ddns-cron/notify"
+)
+
+func TestMaskIPs(t *testing.T) {
+	cases := map[string]string{
+		"updated home from 198.51.100.1 to 203.0.113.10":     "updated home from 198.51.100.x to 203.0.113.x",
+		"discovered 2001:db8:1:2:3:4:5:6 via https://a":      "discovered 2001:db8:1:2::x via https://a",
+		"address 2001:db8::1: not allowed":                   "address 2001:db8::x: not allowed",
+		"mapped ::ffff:203.0.113.10 answer":                  "mapped 203.0.113.x answer",
+		"connect 203.0.113.10:443 failed.":                   "connect 203.0.113.x:443 failed.",
+		"ended with 203.0.113.10.":                           "ended with 203.0.113.x.",
+		"2026/10/15 12:00:00 record 023e105f4ecef8ad9ca31a8": "2026/10/15 12:00:00 record 023e105f4ecef8ad9ca31a8",
+		"version 1.22.3 is fine":                             "version 1.22.3 is fine",
+	}
+	for in, want := range cases {
+		if got := maskIPs(in); got != want {
+			t.Errorf("maskIPs(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIPMaskerMasksLogOutput(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(&ipMasker{out: &out}, "", 0)
+	logger.Printf("public IP is 203.0.113.10")
+	if out.String() != "public IP is 203.0.113.x\n" {
+		t.Fatalf("unexpected log output %q", out.String())
+	}
+}
+
+func TestUpdaterMaskIPInNotifications(t *testing.T) {
+	working := &recordingNotifier{name: "working"}
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := saveState(statePath, State{LastIPs: map[string]string{familyIPv4: "198.51.100.1"}}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	cfg := Config{
+		RecordNames: []string{"example.com"}, StateFile: statePath, NotifyOn: map[string]bool{notifyNetwork: true},
+		NetworkPrefixV4: 24, NetworkPrefixV6: 48, MaskIP: true,
+	}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.notifiers = &notify.Registry{}
+	u.notifiers.Register(working.name, working)
+
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	if len(working.got) != 1 || working.got[0].Message != "IPv4 address moved to a new network: 198.51.100.x -> 203.0.113.x (outside the previous /24)" {
+		t.Fatalf("unexpected notifications %+v", working.got)
+	}
+	if got := api.records["example.com"]["content"]; got != "203.0.113.10" {
+		t.Fatalf("expected the real address to be written, got %v", got)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+// minimalRecheckInterval is the CF_RECHECK_INTERVAL applied by CF_MINIMAL
+// when none is set.
+const minimalRecheckInterval = 24 * time.Hour
+
+// applyMinimal applies the CF_MINIMAL preset to cfg, rejecting settings that
+// would spend the calls it saves.
+func applyMinimal(cfg *Config) error {
+	if cfg.StateFile == "" {
+		return fmt.Errorf("%s requires %s", envMinimal, envStateFile)
+	}
+	for _, conflict := range []struct {
+		set  bool
+		name string
+	}{
+		{cfg.VerifyDNS, envVerifyDNS},
+		{cfg.AlwaysFetch, envAlwaysFetch},
+		{cfg.Force, envForce},
+	} {
+		if conflict.set {
+			return fmt.Errorf("%s cannot be combined with %s", envMinimal, conflict.name)
+		}
+	}
+
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = minimalRecheckInterval
+	}
+	return nil
+}
+
+// recordFetcher looks up the records named cfg.RecordName, standing in for
+// cloudflareProvider's own lookup.
+type recordFetcher func(ctx context.Context, client *cloudflare.Client, cfg Config) ([]dns.Record, error)
+
+// listingFetcher returns a recordFetcher that lists every record of
+// cfg.RecordType once, on first use, and finds each name in that list, so a
+// group of records costs one list call instead of one per record.
+func listingFetcher(cfg Config) recordFetcher {
+	var once sync.Once
+	var records []dns.Record
+	var listErr error
+	return func(ctx context.Context, client *cloudflare.Client, recordCfg Config) ([]dns.Record, error) {
+		once.Do(func() {
+			// Untagged records are found too, as with single lookups.
+			untagged := cfg
+			untagged.RecordTag = ""
+			records, listErr = listRecords(ctx, client, untagged, "")
+		})
+		if listErr != nil {
+			return nil, listErr
+		}
+
+		var named []dns.Record
+		for _, record := range records {
+			if strings.EqualFold(strings.TrimSuffix(record.Name, "."), strings.TrimSuffix(recordCfg.RecordName, ".")) {
+				named = append(named, record)
+			}
+		}
+		return named, nil
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApplyMinimal(t *testing.T) {
+	cfg := Config{StateFile: "state.json"}
+	if err := applyMinimal(&cfg); err != nil || cfg.RecheckInterval != minimalRecheckInterval {
+		t.Fatalf("expected the default recheck interval, got %+v, %v", cfg, err)
+	}
+
+	cfg = Config{StateFile: "state.json", RecheckInterval: time.Hour}
+	if err := applyMinimal(&cfg); err != nil || cfg.RecheckInterval != time.Hour {
+		t.Fatalf("expected an explicit recheck interval to be kept, got %+v, %v", cfg, err)
+	}
+
+	for _, cfg := range []Config{{}, {StateFile: "state.json", VerifyDNS: true}, {StateFile: "state.json", Force: true}} {
+		if err := applyMinimal(&cfg); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestUpdaterMinimalListsOnce(t *testing.T) {
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "203.0.113.10"),
+		aRecordFixture("id-3", "c.example.com", "198.51.100.1"),
+	)
+	cfg := Config{
+		RecordNames:     []string{"a.example.com", "B.example.com.", "c.example.com"},
+		StateFile:       filepath.Join(t.TempDir(), "state.json"),
+		RecheckInterval: minimalRecheckInterval,
+		Minimal:         true,
+	}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.budget = newCallBudget(0)
+	client, err := newCloudflareClient(api.client(), u.cfg, u.budget)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	u.cfClient = client
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if results[0].Action != actionChanged || results[1].Action != actionUnchanged || results[2].Action != actionChanged {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	// One list call and two updates.
+	if calls := u.budget.calls.Load(); calls != 3 {
+		t.Fatalf("expected 3 API calls, got %d", calls)
+	}
+
+	// Unchanged records are then skipped entirely.
+	u.budget.reset()
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if calls := u.budget.calls.Load(); calls != 0 {
+		t.Fatalf("expected no API calls, got %d", calls)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Policies for CF_MISMATCH, applied when discovery only finds an address of
+// the other family than the record type needs.
+const (
+	mismatchError = "error"
+	mismatchSkip  = "skip"
+	mismatchWarn  = "warn"
+)
+
+// familyMismatchError reports a discovered address of family Got where an
+// address of family Want was needed, such as IPv6 only for an A record.
+type familyMismatchError struct {
+	Addr string
+	Got  string
+	Want string
+}
+
+func (e *familyMismatchError) Error() string {
+	recordType := "A"
+	if e.Want == familyIPv6 {
+		recordType = "AAAA"
+	}
+	return fmt.Sprintf("discovered %s address %s, but %s records need an %s address", e.Got, e.Addr, recordType, e.Want)
+}
+
+// logMismatch reports records left alone under CF_MISMATCH=skip or warn.
+func logMismatch(cfg Config, mismatch *familyMismatchError) {
+	if cfg.Mismatch == mismatchWarn {
+		log.Printf("warning: %v; leaving %d record(s) unchanged (%s=%s)", mismatch, len(cfg.RecordNames), envMismatch, cfg.Mismatch)
+		return
+	}
+	log.Printf("%v; leaving %d record(s) unchanged (%s=%s)", mismatch, len(cfg.RecordNames), envMismatch, cfg.Mismatch)
+}
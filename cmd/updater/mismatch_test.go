@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpdaterMismatchError(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"example.com"}, Mismatch: mismatchError}
+	u := newTestUpdater(t, cfg, api, "2001:db8::10")
+
+	_, err := u.run(context.Background())
+	var mismatch *familyMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Got != familyIPv6 || mismatch.Want != familyIPv4 {
+		t.Fatalf("expected a family mismatch error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "discovered IPv6 address 2001:db8::10, but A records need an IPv4 address") {
+		t.Fatalf("unexpected error message %q", err)
+	}
+	if api.updates != 0 {
+		t.Fatalf("expected no update, got %d", api.updates)
+	}
+}
+
+func TestUpdaterMismatchSkipAndWarn(t *testing.T) {
+	for _, policy := range []string{mismatchSkip, mismatchWarn} {
+		t.Run(policy, func(t *testing.T) {
+			var logs bytes.Buffer
+			prev := log.Writer()
+			log.SetOutput(&logs)
+			t.Cleanup(func() { log.SetOutput(prev) })
+
+			record := aRecordFixture("id-1", "example.com", "2001:db8::1")
+			record["type"] = "AAAA"
+			api := newMockCloudflare(record)
+			cfg := Config{RecordNames: []string{"example.com"}, RecordType: "AAAA", Mismatch: policy}
+			u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+			results, err := u.run(context.Background())
+			if err != nil {
+				t.Fatalf("expected the mismatch to be tolerated, got %v", err)
+			}
+			if len(results) != 1 || results[0].Action != actionSkipped || api.updates != 0 {
+				t.Fatalf("unexpected results %+v with %d update(s)", results, api.updates)
+			}
+			warned := strings.Contains(logs.String(), "warning: discovered IPv4 address 203.0.113.10")
+			if warned != (policy == mismatchWarn) {
+				t.Fatalf("unexpected log for %s: %q", policy, logs.String())
+			}
+		})
+	}
+}
+
+func TestDiscoverConsensusMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("2001:db8::10"))
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := discoverConsensus(context.Background(), &http.Client{}, []string{server.URL}, familyIPv4, 0, 1, tiebreakError, nil)
+	var mismatch *familyMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected a family mismatch error, got %v", err)
+	}
+}
+
+func TestLoadConfigMismatch(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+
+	cfg, err := loadConfig()
+	if err != nil || cfg.Mismatch != mismatchError {
+		t.Fatalf("expected the error policy by default, got %q, %v", cfg.Mismatch, err)
+	}
+
+	t.Setenv(envMismatch, "Skip")
+	if cfg, err = loadConfig(); err != nil || cfg.Mismatch != mismatchSkip {
+		t.Fatalf("unexpected policy %q, %v", cfg.Mismatch, err)
+	}
+
+	t.Setenv(envMismatch, "ignore")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), envMismatch) {
+		t.Fatalf("expected an unsupported value error, got %v", err)
+	}
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+// mockCloudflare is a minimal in-memory implementation of the zone and DNS
+// record endpoints used by the updater. It backs both the -mock-server
+// mode and the tests, and is also a DNSProvider over the same records.
+type mockCloudflare struct {
+	mu      sync.Mutex
+	records map[string]map[string]any // keyed by record name
+	// zones maps the zone IDs that may be read to their names. When nil,
+	// every zone ID is readable and named example.com.
+	zones map[string]string
+	// readOnly rejects every write with 403, like a token that may read
+	// but not edit DNS.
+	readOnly bool
+	// disabledTokens lists API tokens that verify as disabled.
+	disabledTokens map[string]bool
+	// unavailable answers every request with 503, like Cloudflare during
+	// an outage.
+	unavailable bool
+	updates     int
+	creates     int
+	deletes     int
+
+	// customHostnames maps Cloudflare for SaaS custom hostnames to their
+	// custom origin server, "" for those using fallbackOrigin.
+	customHostnames map[string]string
+	fallbackOrigin  string
+
+	// seed creates a placeholder record the first time an unknown name is
+	// listed, so any configuration can be exercised against an empty server.
+	seed   bool
+	nextID int
+	logger *log.Logger
+}
+
+func newMockCloudflare(records ...map[string]any) *mockCloudflare {
+	m := &mockCloudflare{records: make(map[string]map[string]any)}
+	for _, record := range records {
+		m.records[record["name"].(string)] = record
+	}
+	return m
+}
+
+func (m *mockCloudflare) logf(format string, args ...any) {
+	if m.logger != nil {
+		m.logger.Printf(format, args...)
+	}
+}
+
+func (m *mockCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if m.unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false, "errors": []any{map[string]any{"code": 10000, "message": "Service unavailable"}}, "messages": []any{}, "result": nil,
+		})
+		return
+	}
+
+	if zoneID, ok := zonePath(r.URL.Path); ok && r.Method == http.MethodGet {
+		m.serveZone(w, zoneID)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/zones") && r.Method == http.MethodGet {
+		m.listZones(w, r.URL.Query().Get("name"))
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/user/tokens/verify") {
+		status := "active"
+		if m.disabledTokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+			status = "disabled"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": true, "errors": []any{}, "messages": []any{},
+			"result": map[string]any{"id": "mock-token", "status": status},
+		})
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/custom_hostnames/fallback_origin") && r.Method == http.MethodGet {
+		m.serveFallbackOrigin(w)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/custom_hostnames") && r.Method == http.MethodGet {
+		m.listCustomHostnames(w, r.URL.Query().Get("hostname"))
+		return
+	}
+
+	if !strings.Contains(r.URL.Path, "/dns_records") {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false, "messages": []any{},
+			"errors": []any{map[string]any{"code": 7000, "message": "No route for that URI"}},
+		})
+		return
+	}
+
+	if m.readOnly && r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false, "messages": []any{},
+			"errors": []any{map[string]any{"code": 10000, "message": "Authentication error"}},
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		name := query.Get("name")
+
+		var result []map[string]any
+		if name != "" {
+			if record, ok := m.namedRecord(name, query.Get("type")); ok && matchesTagQuery(record, query) {
+				result = append(result, record)
+			}
+		} else {
+			for _, record := range m.records {
+				if recordType := query.Get("type"); recordType != "" && record["type"] != recordType {
+					continue
+				}
+				if comment := query.Get("comment.exact"); comment != "" && record["comment"] != comment {
+					continue
+				}
+				if prefix := query.Get("comment.startswith"); prefix != "" && !strings.HasPrefix(fmt.Sprint(record["comment"]), prefix) {
+					continue
+				}
+				if !matchesTagQuery(record, query) {
+					continue
+				}
+				result = append(result, record)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": true, "errors": []any{}, "messages": []any{},
+			"result": result, "result_info": map[string]any{"page": 1, "per_page": 100},
+		})
+	case http.MethodPut:
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		m.updateRecord(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], body)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": true, "errors": []any{}, "messages": []any{}, "result": body,
+		})
+	case http.MethodPost:
+		var record map[string]any
+		json.NewDecoder(r.Body).Decode(&record)
+		m.createRecord(record)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": true, "errors": []any{}, "messages": []any{}, "result": record,
+		})
+	case http.MethodDelete:
+		for name, record := range m.records {
+			if strings.HasSuffix(r.URL.Path, "/"+record["id"].(string)) {
+				m.logf("mock: deleted %s", name)
+				delete(m.records, name)
+				json.NewEncoder(w).Encode(map[string]any{
+					"success": true, "errors": []any{}, "messages": []any{},
+					"result": map[string]any{"id": record["id"]},
+				})
+				m.deletes++
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false, "messages": []any{},
+			"errors": []any{map[string]any{"code": 81044, "message": "Record does not exist."}},
+		})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// namedRecord returns the record named name, seeding one of recordType when
+// m.seed is set.
+func (m *mockCloudflare) namedRecord(name, recordType string) (map[string]any, bool) {
+	record, ok := m.records[name]
+	if !ok && m.seed {
+		record, ok = m.seedRecord(name, recordType), true
+	}
+	return record, ok
+}
+
+// updateRecord merges body into the record with the given ID.
+func (m *mockCloudflare) updateRecord(id string, body map[string]any) {
+	for name, record := range m.records {
+		if record["id"] == id {
+			m.logf("mock: update %s %v -> %v", name, record["content"], body["content"])
+			for k, v := range body {
+				record[k] = v
+			}
+		}
+	}
+	m.updates++
+}
+
+// createRecord stores record under a new ID.
+func (m *mockCloudflare) createRecord(record map[string]any) {
+	m.nextID++
+	record["id"] = fmt.Sprintf("mock-%d", m.nextID)
+	name, _ := record["name"].(string)
+	m.records[name] = record
+	m.creates++
+	m.logf("mock: created %v record %s (%v)", record["type"], name, record["content"])
+}
+
+// errMockUnavailable is returned by the mock's DNSProvider methods while it
+// is unavailable.
+var errMockUnavailable = errors.New("mock: service unavailable")
+
+// mockAuthFailure is the failure the mock answers writes with when
+// readOnly is set.
+var mockAuthFailure = &APIFailureError{Errors: []apiMessage{{Code: 10000, Message: "Authentication error"}}}
+
+// Fetch returns the record named cfg.RecordName, making the mock a
+// DNSProvider that syncRecord can use without going through HTTP.
+func (m *mockCloudflare) Fetch(ctx context.Context, cfg Config) (dns.Record, error) {
+	records, err := m.FetchRecords(ctx, cfg)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	return pickRecord(records, cfg)
+}
+
+// FetchRecords returns the record named cfg.RecordName, if any; the mock
+// holds one record per name.
+func (m *mockCloudflare) FetchRecords(ctx context.Context, cfg Config) ([]dns.Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.unavailable {
+		return nil, errMockUnavailable
+	}
+
+	stored, ok := m.namedRecord(cfg.RecordName, cfg.RecordType)
+	if !ok {
+		return nil, nil
+	}
+	payload, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	var record dns.Record
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return nil, err
+	}
+	return []dns.Record{record}, nil
+}
+
+// Update writes content to the record with the given ID.
+func (m *mockCloudflare) Update(ctx context.Context, cfg Config, recordID, content string) error {
+	body, err := mockRecordBody(cfg, content)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.writeErr(); err != nil {
+		return err
+	}
+	m.updateRecord(recordID, body)
+	return nil
+}
+
+// Create creates the record named cfg.RecordName with content.
+func (m *mockCloudflare) Create(ctx context.Context, cfg Config, content string) error {
+	body, err := mockRecordBody(cfg, content)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.writeErr(); err != nil {
+		return err
+	}
+	m.createRecord(body)
+	return nil
+}
+
+// writeErr returns the error a write fails with, as its HTTP counterpart
+// would. The caller holds m.mu.
+func (m *mockCloudflare) writeErr() error {
+	switch {
+	case m.unavailable:
+		return errMockUnavailable
+	case m.readOnly:
+		return asPermissionError(mockAuthFailure)
+	}
+	return nil
+}
+
+// mockRecordBody returns the body cloudflareProvider would send for cfg and
+// content, with CF_EXTRA_FIELDS applied, as the mock stores it.
+func mockRecordBody(cfg Config, content string) (map[string]any, error) {
+	payload, err := json.Marshal(recordParam(cfg, content))
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, err
+	}
+	for key, raw := range cfg.ExtraFields {
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		body[key] = value
+	}
+	return body, nil
+}
+
+// matchesTagQuery applies the tag.exact and tag.present filters of a record
+// listing to record.
+func matchesTagQuery(record map[string]any, query url.Values) bool {
+	var tags []string
+	if raw, ok := record["tags"].([]any); ok {
+		for _, t := range raw {
+			if tag, ok := t.(string); ok {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if tag := query.Get("tag.exact"); tag != "" && !hasTag(tags, tag) {
+		return false
+	}
+	if tag := query.Get("tag.present"); tag != "" && !hasTag(tags, tag) {
+		return false
+	}
+	return true
+}
+
+// zonePath extracts the zone ID from a /zones/{id} path.
+func zonePath(path string) (string, bool) {
+	_, rest, ok := strings.Cut(path, "/zones/")
+	if !ok || rest == "" || strings.Contains(rest, "/") {
+		return "", false
+	}
+	return rest, true
+}
+
+func (m *mockCloudflare) serveZone(w http.ResponseWriter, zoneID string) {
+	name, ok := "example.com", true
+	if m.zones != nil {
+		name, ok = m.zones[zoneID]
+	}
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false, "messages": []any{},
+			"errors": []any{map[string]any{"code": 9109, "message": "Unauthorized to access requested resource"}},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true, "errors": []any{}, "messages": []any{},
+		"result": map[string]any{"id": zoneID, "name": name},
+	})
+}
+
+// listZones answers a zone search by name with the readable zones of that
+// name.
+func (m *mockCloudflare) listZones(w http.ResponseWriter, name string) {
+	zones := m.zones
+	if zones == nil {
+		zones = map[string]string{"zone-id": "example.com"}
+	}
+
+	result := []map[string]any{}
+	for id, zoneName := range zones {
+		if name == "" || zoneName == name {
+			result = append(result, map[string]any{"id": id, "name": zoneName})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true, "errors": []any{}, "messages": []any{},
+		"result": result, "result_info": map[string]any{"page": 1, "per_page": 20, "count": len(result), "total_count": len(result)},
+	})
+}
+
+// listCustomHostnames answers a custom hostname search by hostname.
+func (m *mockCloudflare) listCustomHostnames(w http.ResponseWriter, hostname string) {
+	result := []map[string]any{}
+	for name, origin := range m.customHostnames {
+		if hostname == "" || name == hostname {
+			result = append(result, map[string]any{
+				"id": "ch-" + name, "hostname": name, "custom_origin_server": origin,
+				"ssl": map[string]any{}, "status": "active",
+			})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true, "errors": []any{}, "messages": []any{},
+		"result": result, "result_info": map[string]any{"page": 1, "per_page": 50, "count": len(result), "total_count": len(result)},
+	})
+}
+
+func (m *mockCloudflare) serveFallbackOrigin(w http.ResponseWriter) {
+	if m.fallbackOrigin == "" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false, "messages": []any{},
+			"errors": []any{map[string]any{"code": 1551, "message": "No fallback origin found"}},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true, "errors": []any{}, "messages": []any{},
+		"result": map[string]any{"origin": m.fallbackOrigin, "status": "active"},
+	})
+}
+
+// seedRecord stores a placeholder record of the given type using
+// documentation addresses, so the first run against it reports a change.
+func (m *mockCloudflare) seedRecord(name, recordType string) map[string]any {
+	m.nextID++
+	record := map[string]any{
+		"id": fmt.Sprintf("mock-%d", m.nextID), "type": recordType, "name": name,
+		"proxied": false, "ttl": 1, "tags": []any{},
+	}
+
+	switch recordType {
+	case "AAAA":
+		record["content"] = "2001:db8::1"
+	case "SRV":
+		record["data"] = map[string]any{"priority": 0, "weight": 0, "port": 1, "target": "mock.invalid"}
+		record["content"] = "0 1 mock.invalid"
+	case "CAA":
+		record["data"] = map[string]any{"flags": 0, "tag": "issue", "value": "mock.invalid"}
+		record["content"] = `0 issue "mock.invalid"`
+	default:
+		record["type"] = "A"
+		record["content"] = "192.0.2.1"
+	}
+
+	m.records[name] = record
+	m.logf("mock: created %s record %s (%v)", record["type"], name, record["content"])
+	return record
+}
+
+// runMockServer serves an in-memory Cloudflare API on addr until ctx is done.
+// Point CF_API_BASE_URL at it to exercise a configuration without touching
+// real DNS.
+func runMockServer(ctx context.Context, addr string) error {
+	mock := newMockCloudflare()
+	mock.seed = true
+	mock.logger = log.Default()
+
+	server := &http.Server{Addr: addr, Handler: mock}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	log.Printf("mock Cloudflare API listening on http://%s/client/v4/", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
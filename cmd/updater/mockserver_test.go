@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMockServerEndToEnd(t *testing.T) {
+	mock := newMockCloudflare()
+	mock.seed = true
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	cfg := Config{RecordNames: []string{"home.example.com"}, APIBaseURL: server.URL + "/client/v4/"}
+	u := newTestUpdater(t, cfg, mock, "203.0.113.10")
+
+	cfClient, err := newCloudflareClient(&http.Client{}, u.cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	u.cfClient = cfClient
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if r := results[0]; r.Action != actionChanged || r.OldIP != "192.0.2.1" || r.NewIP != "203.0.113.10" {
+		t.Fatalf("unexpected first result %+v", r)
+	}
+
+	results, err = u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if results[0].Action != actionUnchanged {
+		t.Fatalf("expected second run to be unchanged, got %+v", results[0])
+	}
+}
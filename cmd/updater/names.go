@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+)
+
+const (
+	nameMatchExact    = "exact"
+	nameMatchRelative = "relative"
+)
+
+// normalizeName returns the form of name used for comparisons: lower case,
+// without a trailing dot and, when zone is known, relative to it. The zone
+// apex becomes "@". Short and fully qualified forms of a name therefore
+// normalize to the same value.
+func normalizeName(name, zone string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	if zone == "" {
+		return name
+	}
+	if name == zone || name == "@" {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
+// qualifyName expands a name relative to zone into the fully qualified form
+// Cloudflare expects. Names already inside the zone are left unchanged.
+func qualifyName(name, zone string) string {
+	zone = strings.TrimSuffix(zone, ".")
+	if zone == "" {
+		return name
+	}
+	if name == "@" {
+		return zone
+	}
+	name = strings.TrimSuffix(name, ".")
+	lower, lowerZone := strings.ToLower(name), strings.ToLower(zone)
+	if lower == lowerZone || strings.HasSuffix(lower, "."+lowerZone) {
+		return name
+	}
+	return name + "." + zone
+}
+
+// zoneName returns the name of cfg's zone for relative name matching, from
+// CF_ZONE_NAME or, failing that, by reading the zone once per run.
+func (u *updater) zoneName(ctx context.Context, client *cloudflare.Client, cfg Config) (string, error) {
+	if cfg.ZoneName != "" {
+		return cfg.ZoneName, nil
+	}
+	if name, ok := u.zoneNames[cfg.ZoneID]; ok {
+		return name, nil
+	}
+
+	name, err := readZone(ctx, client, cfg.ZoneID)
+	if err != nil {
+		return "", err
+	}
+	if u.zoneNames == nil {
+		u.zoneNames = make(map[string]string)
+	}
+	u.zoneNames[cfg.ZoneID] = name
+	return name, nil
+}
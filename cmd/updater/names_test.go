@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeNameIgnoresZoneSuffix(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"home", "home.example.com", true},
+		{"home.example.com", "home", true},
+		{"Home.Example.com.", "home", true},
+		{"@", "example.com", true},
+		{"example.com", "@", true},
+		{"home", "home.example.net", false},
+		{"home", "office.example.com", false},
+	}
+	for _, c := range cases {
+		if got := normalizeName(c.a, "example.com") == normalizeName(c.b, "example.com"); got != c.want {
+			t.Errorf("%q and %q equal = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+
+	if normalizeName("home", "") == normalizeName("home.example.com", "") {
+		t.Errorf("expected names to differ without a zone")
+	}
+}
+
+func TestQualifyName(t *testing.T) {
+	cases := map[string]string{
+		"home":              "home.example.com",
+		"home.example.com":  "home.example.com",
+		"home.example.com.": "home.example.com",
+		"@":                 "example.com",
+		"example.com":       "example.com",
+		"a.b":               "a.b.example.com",
+	}
+	for name, want := range cases {
+		if got := qualifyName(name, "example.com"); got != want {
+			t.Errorf("qualifyName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestUpdaterRelativeNames(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	api.zones = map[string]string{"zone-id": "example.com"}
+
+	cfg := Config{RecordNames: []string{"home"}, NameMatch: nameMatchRelative}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if r := results[0]; r.Record != "home.example.com" || r.Action != actionChanged {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// natpmpPort is the port NAT-PMP gateways listen on (RFC 6886).
+const natpmpPort = 5351
+
+// routeTablePath lists the kernel routes; the default route names the gateway.
+var routeTablePath = "/proc/net/route"
+
+// discoverNATPMP asks the default gateway for its external IPv4 address with
+// a NAT-PMP public address request.
+func discoverNATPMP(ctx context.Context, clock Clock) (string, error) {
+	f, err := os.Open(routeTablePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to find the default gateway: %w", err)
+	}
+	defer f.Close()
+
+	gateway, err := defaultGateway(f)
+	if err != nil {
+		return "", err
+	}
+
+	return natpmpExternalIP(ctx, netip.AddrPortFrom(gateway, natpmpPort).String(), clock.Now().Add(upnpWaitTimeout))
+}
+
+// defaultGateway returns the gateway of the IPv4 default route from a table in
+// the /proc/net/route format, where addresses are little-endian hex.
+func defaultGateway(r io.Reader) (netip.Addr, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&0x2 == 0 {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		return netip.AddrFrom4([4]byte{raw[3], raw[2], raw[1], raw[0]}), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.Addr{}, errors.New("no default gateway found")
+}
+
+// natpmpExternalIP sends a public address request to gateway and waits for the
+// answer until deadline.
+func natpmpExternalIP(ctx context.Context, gateway string, deadline time.Time) (string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp4", gateway)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+
+	// Version 0, opcode 0: public address request.
+	if _, err := conn.Write([]byte{0, 0}); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 16)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return "", fmt.Errorf("no NAT-PMP gateway answered: %w", err)
+		}
+		// The answer is version 0, opcode 128, a result code, the seconds
+		// since the mapping table was reset and the address.
+		if n < 12 || buf[0] != 0 || buf[1] != 128 {
+			continue
+		}
+		if code := binary.BigEndian.Uint16(buf[2:4]); code != 0 {
+			return "", fmt.Errorf("NAT-PMP gateway returned result code %d", code)
+		}
+		return netip.AddrFrom4([4]byte(buf[8:12])).String(), nil
+	}
+}
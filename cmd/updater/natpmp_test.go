@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultGateway(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0001A8C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+`
+	gateway, err := defaultGateway(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gateway.String() != "192.168.1.1" {
+		t.Fatalf("expected 192.168.1.1, got %s", gateway)
+	}
+
+	if _, err := defaultGateway(strings.NewReader("Iface\tDestination\tGateway\tFlags\neth0\t0001A8C0\t00000000\t0001\n")); err == nil {
+		t.Fatalf("expected an error without a default route")
+	}
+}
+
+func TestNATPMPExternalIP(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 16)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil || n != 2 || buf[0] != 0 || buf[1] != 0 {
+			return
+		}
+		conn.WriteTo([]byte{0, 128, 0, 0, 0, 0, 0x10, 0, 203, 0, 113, 10}, addr)
+	}()
+
+	ip, err := natpmpExternalIP(context.Background(), conn.LocalAddr().String(), time.Now().Add(2*time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "203.0.113.10" {
+		t.Fatalf("expected 203.0.113.10, got %s", ip)
+	}
+}
+
+func TestNATPMPExternalIPResultCode(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 16)
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		// Result code 3: network failure, the gateway has no address yet.
+		conn.WriteTo([]byte{0, 128, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0}, addr)
+	}()
+
+	if _, err := natpmpExternalIP(context.Background(), conn.LocalAddr().String(), time.Now().Add(2*time.Second)); err == nil {
+		t.Fatalf("expected an error for a non-zero result code")
+	}
+}
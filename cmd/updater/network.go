@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultNetworkPrefixV4 = 24
+	defaultNetworkPrefixV6 = 48
+)
+
+// parseNetworkPrefix parses a prefix length such as "24" or "/24" for one
+// address family, returning def when value is empty.
+func parseNetworkPrefix(env, value string, def, max int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	bits, err := strconv.Atoi(strings.TrimPrefix(value, "/"))
+	if err != nil || bits < 1 || bits > max {
+		return 0, fmt.Errorf("invalid %s value %q (must be a prefix length between 1 and %d)", env, value, max)
+	}
+	return bits, nil
+}
+
+// newNetwork reports whether next lies outside the network of prev, that is
+// whether the two addresses differ within the first bits4 (IPv4) or bits6
+// (IPv6) bits. Addresses of different families always count as a new
+// network.
+func newNetwork(prev, next string, bits4, bits6 int) bool {
+	a, b := net.ParseIP(prev), net.ParseIP(next)
+	if a == nil || b == nil {
+		return true
+	}
+	if a4, b4 := a.To4(), b.To4(); a4 != nil || b4 != nil {
+		if a4 == nil || b4 == nil {
+			return true
+		}
+		mask := net.CIDRMask(bits4, 8*net.IPv4len)
+		return !a4.Mask(mask).Equal(b4.Mask(mask))
+	}
+	mask := net.CIDRMask(bits6, 8*net.IPv6len)
+	return !a.Mask(mask).Equal(b.Mask(mask))
+}
+
+// trackNetwork compares a discovered address with the one recorded for its
+// family in the state file and classifies the change: a move within the
+// same prefix is logged as a minor change, one leaving it as a new network,
+// which is kept for the network notification. The address is then recorded
+// for the next run, along with when it was first seen.
+func (u *updater) trackNetwork(cfg Config, state *State, ip string) {
+	if cfg.StateFile == "" {
+		return
+	}
+
+	family := familyIPv4
+	bits := cfg.NetworkPrefixV4
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		family = familyIPv6
+		bits = cfg.NetworkPrefixV6
+	}
+
+	prev := state.LastIPs[family]
+	dirty := u.checkStuck(cfg, state, family, prev, ip)
+	if prev == ip {
+		if dirty {
+			if err := saveState(cfg.StateFile, *state); err != nil {
+				log.Printf("warning: failed to save state file: %v", err)
+			}
+		}
+		return
+	}
+	switch {
+	case prev == "":
+	case newNetwork(prev, ip, cfg.NetworkPrefixV4, cfg.NetworkPrefixV6):
+		change := fmt.Sprintf("%s address moved to a new network: %s -> %s (outside the previous /%d)", family, prev, ip, bits)
+		log.Printf("%s", change)
+		u.networkChanges = append(u.networkChanges, change)
+	default:
+		log.Printf("%s address changed within the same /%d: %s -> %s", family, bits, prev, ip)
+	}
+
+	if state.LastIPs == nil {
+		state.LastIPs = make(map[string]string)
+	}
+	state.LastIPs[family] = ip
+	if err := saveState(cfg.StateFile, *state); err != nil {
+		log.Printf("warning: failed to save state file: %v", err)
+	}
+}
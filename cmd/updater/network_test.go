@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/derek/cloudflare-ddns-cron/notify"
+)
+
+func TestNewNetwork(t *testing.T) {
+	cases := []struct {
+		prev, next string
+		want       bool
+	}{
+		{"203.0.113.10", "203.0.113.99", false},
+		{"203.0.113.10", "203.0.114.10", true},
+		{"2001:db8:1:1::1", "2001:db8:1:ff::1", false},
+		{"2001:db8:1::1", "2001:db8:2::1", true},
+		{"203.0.113.10", "2001:db8::1", true},
+	}
+	for _, c := range cases {
+		if got := newNetwork(c.prev, c.next, 24, 48); got != c.want {
+			t.Errorf("newNetwork(%q, %q) = %v; want %v", c.prev, c.next, got, c.want)
+		}
+	}
+}
+
+func TestParseNetworkPrefix(t *testing.T) {
+	if bits, err := parseNetworkPrefix(envNetworkPrefixV4, "", 24, 32); err != nil || bits != 24 {
+		t.Fatalf("expected default, got %d, %v", bits, err)
+	}
+	if bits, err := parseNetworkPrefix(envNetworkPrefixV4, "/16", 24, 32); err != nil || bits != 16 {
+		t.Fatalf("expected 16, got %d, %v", bits, err)
+	}
+	if _, err := parseNetworkPrefix(envNetworkPrefixV4, "33", 24, 32); err == nil {
+		t.Fatalf("expected error for prefix longer than the address")
+	}
+}
+
+func TestUpdaterNotifiesNetworkChange(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := saveState(statePath, State{LastIPs: map[string]string{familyIPv4: "198.51.100.1"}}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	cfg := Config{
+		RecordNames:     []string{"example.com"},
+		StateFile:       statePath,
+		NotifyOn:        map[string]bool{notifyNetwork: true},
+		NetworkPrefixV4: 24,
+		NetworkPrefixV6: 48,
+	}
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	rec := &recordingNotifier{name: "recording"}
+	u.notifiers = &notify.Registry{}
+	u.notifiers.Register(rec.name, rec)
+
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if len(rec.got) != 1 || rec.got[0].Kind != notifyNetwork {
+		t.Fatalf("expected one network notification, got %+v", rec.got)
+	}
+
+	state, err := loadState(statePath)
+	if err != nil || state.LastIPs[familyIPv4] != "203.0.113.10" {
+		t.Fatalf("expected new address in state, got %+v, %v", state, err)
+	}
+
+	// A later move within the same /24 is only a minor change.
+	rec.got = nil
+	u = newTestUpdater(t, cfg, api, "203.0.113.20")
+	u.notifiers = &notify.Registry{}
+	u.notifiers.Register(rec.name, rec)
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if len(rec.got) != 0 {
+		t.Fatalf("expected no network notification, got %+v", rec.got)
+	}
+}
+
+func TestUpdaterIgnoresRejectedNetworkChange(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	if err := saveState(statePath, State{LastIPs: map[string]string{familyIPv4: "198.51.100.1"}}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	_, excluded, _ := net.ParseCIDR("203.0.113.0/24")
+	cfg := Config{
+		RecordNames:     []string{"example.com"},
+		StateFile:       statePath,
+		NotifyOn:        map[string]bool{notifyNetwork: true},
+		NetworkPrefixV4: 24,
+		NetworkPrefixV6: 48,
+		ExcludeIPs:      []*net.IPNet{excluded},
+	}
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	rec := &recordingNotifier{name: "recording"}
+	u.notifiers = &notify.Registry{}
+	u.notifiers.Register(rec.name, rec)
+
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if len(rec.got) != 0 {
+		t.Fatalf("expected no notification for an excluded address, got %+v", rec.got)
+	}
+	state, err := loadState(statePath)
+	if err != nil || state.LastIPs[familyIPv4] != "198.51.100.1" {
+		t.Fatalf("expected the excluded address to stay out of state, got %+v, %v", state, err)
+	}
+}
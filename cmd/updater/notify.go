@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/derek/cloudflare-ddns-cron/notify"
+)
+
+// Events that can trigger a notification, selected with CF_NOTIFY_ON.
+const (
+	notifyFailure  = notify.KindFailure
+	notifyRecovery = notify.KindRecovery
+	notifyStreak   = notify.KindStreak
+	notifyNetwork  = notify.KindNetwork
+	notifyStuck    = notify.KindStuck
+
+	defaultNotifyThreshold = 3
+)
+
+// newNotifiers registers a notifier for every channel configured in cfg,
+// plus any added with notify.Register.
+func newNotifiers(cfg Config, client *http.Client) *notify.Registry {
+	return notify.New(client, notify.Channels{WebhookURL: cfg.NotifyURL, DiscordURL: cfg.NotifyDiscordURL})
+}
+
+// parseNotifyOn parses the comma-separated CF_NOTIFY_ON value.
+func parseNotifyOn(value string) (map[string]bool, error) {
+	events := make(map[string]bool)
+	for _, event := range splitList(strings.ToLower(value)) {
+		switch event {
+		case notifyFailure, notifyRecovery, notifyStreak, notifyNetwork:
+			events[event] = true
+		default:
+			return nil, fmt.Errorf("unsupported %s event %q (must be '%s', '%s', '%s' or '%s')", envNotifyOn, event, notifyFailure, notifyRecovery, notifyStreak, notifyNetwork)
+		}
+	}
+	if len(events) == 0 {
+		events[notifyFailure] = true
+	}
+	return events, nil
+}
+
+// notifyEvent decides which notification, if any, a run outcome warrants.
+// streak is the number of consecutive failed runs before this one.
+func notifyEvent(events map[string]bool, threshold, streak int, runErr error) (string, int) {
+	if runErr != nil {
+		streak++
+		switch {
+		case events[notifyFailure]:
+			return notifyFailure, streak
+		case events[notifyStreak] && streak == threshold:
+			return notifyStreak, streak
+		}
+		return "", streak
+	}
+
+	if events[notifyRecovery] && streak >= threshold {
+		return notifyRecovery, 0
+	}
+	return "", 0
+}
+
+// notify tracks the failure streak in the state file and sends the
+// notifications the outcome of a run calls for to every channel.
+func (u *updater) notify(ctx context.Context, runErr error) {
+	if u.notifiers == nil || u.notifiers.Len() == 0 {
+		return
+	}
+
+	var state State
+	if u.cfg.StateFile != "" {
+		var err error
+		if state, err = loadState(u.cfg.StateFile); err != nil {
+			log.Printf("warning: failed to load state file for notifications: %v", err)
+			return
+		}
+	}
+
+	prev := state.FailureStreak
+	event, streak := notifyEvent(u.cfg.NotifyOn, u.cfg.NotifyThreshold, prev, runErr)
+	if u.cfg.StateFile != "" && streak != prev {
+		state.FailureStreak = streak
+		if err := saveState(u.cfg.StateFile, state); err != nil {
+			log.Printf("warning: failed to save state file: %v", err)
+		}
+	}
+
+	// A run cut short by CF_RUN_TIMEOUT must still be reported.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultHTTPTimeout)
+	defer cancel()
+	now := u.clock.Now()
+
+	if len(u.networkChanges) > 0 && u.cfg.NotifyOn[notifyNetwork] {
+		u.broadcast(ctx, notify.Event{Kind: notifyNetwork, Message: strings.Join(u.networkChanges, "; "), Failures: streak, Time: now, RunID: u.runID})
+	}
+	// Stuck alerts are opted into with CF_STUCK_AFTER alone.
+	if len(u.stuckAlerts) > 0 {
+		u.broadcast(ctx, notify.Event{Kind: notifyStuck, Message: strings.Join(u.stuckAlerts, "; "), Failures: streak, Time: now, RunID: u.runID})
+	}
+	if event == "" {
+		return
+	}
+
+	e := notify.Event{Kind: event, Failures: streak, Time: now, RunID: u.runID}
+	switch event {
+	case notifyRecovery:
+		e.Failures = prev
+		e.Message = fmt.Sprintf("recovered after %d consecutive failed run(s)", prev)
+	case notifyStreak:
+		e.Message = fmt.Sprintf("%d consecutive failed runs: %v", streak, runErr)
+	default:
+		e.Message = fmt.Sprintf("run failed: %v", runErr)
+	}
+	u.broadcast(ctx, e)
+}
+
+// broadcast sends e to every notifier and logs the outcome per channel, so
+// one failing channel never hides the others. With CF_MASK_IP, addresses in
+// the message are masked first.
+func (u *updater) broadcast(ctx context.Context, e notify.Event) {
+	if u.cfg.MaskIP {
+		e.Message = maskIPs(e.Message)
+	}
+	var delivered int
+	deliveries := u.notifiers.Send(ctx, e)
+	for _, d := range deliveries {
+		if d.Err != nil {
+			log.Printf("warning: failed to send %s notification via %s: %v", e.Kind, d.Name, d.Err)
+			continue
+		}
+		delivered++
+	}
+	log.Printf("sent %s notification to %d of %d channel(s)", e.Kind, delivered, len(deliveries))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/derek/cloudflare-ddns-cron/notify"
+)
+
+func TestNotifyEvent(t *testing.T) {
+	failed := errors.New("boom")
+	recovery := map[string]bool{notifyRecovery: true, notifyStreak: true}
+
+	cases := []struct {
+		events     map[string]bool
+		streak     int
+		err        error
+		wantEvent  string
+		wantStreak int
+	}{
+		{map[string]bool{notifyFailure: true}, 0, failed, notifyFailure, 1},
+		{map[string]bool{notifyFailure: true}, 4, nil, "", 0},
+		{recovery, 0, failed, "", 1},
+		{recovery, 2, failed, notifyStreak, 3},
+		{recovery, 3, failed, "", 4},
+		{recovery, 2, nil, "", 0},
+		{recovery, 4, nil, notifyRecovery, 0},
+	}
+	for _, c := range cases {
+		event, streak := notifyEvent(c.events, 3, c.streak, c.err)
+		if event != c.wantEvent || streak != c.wantStreak {
+			t.Errorf("notifyEvent(%v, %d, %v) = %q, %d; want %q, %d", c.events, c.streak, c.err, event, streak, c.wantEvent, c.wantStreak)
+		}
+	}
+}
+
+func TestUpdaterNotifiesOnRecovery(t *testing.T) {
+	type webhookBody struct {
+		Event    string `json:"event"`
+		Failures int    `json:"consecutive_failures"`
+	}
+	var received []webhookBody
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n webhookBody
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received = append(received, n)
+	}))
+	t.Cleanup(hook.Close)
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{
+		RecordNames:     []string{"example.com"},
+		StateFile:       statePath,
+		NotifyURL:       hook.URL,
+		NotifyOn:        map[string]bool{notifyRecovery: true},
+		NotifyThreshold: 2,
+	}
+
+	for i := 0; i < 2; i++ {
+		u := newTestUpdater(t, cfg, newMockCloudflare(), "203.0.113.10")
+		u.notifiers = newNotifiers(cfg, hook.Client())
+		if err := u.cycle(context.Background()); err == nil {
+			t.Fatalf("expected missing record to fail")
+		}
+	}
+	if len(received) != 0 {
+		t.Fatalf("expected failures to stay quiet, got %+v", received)
+	}
+
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "203.0.113.10"))
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.notifiers = newNotifiers(cfg, hook.Client())
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	if len(received) != 1 || received[0].Event != notifyRecovery || received[0].Failures != 2 {
+		t.Fatalf("expected one recovery notification, got %+v", received)
+	}
+	state, err := loadState(statePath)
+	if err != nil || state.FailureStreak != 0 {
+		t.Fatalf("expected streak to be reset, got %+v, %v", state, err)
+	}
+}
+
+// recordingNotifier collects notifications and fails with err when set.
+type recordingNotifier struct {
+	mu   sync.Mutex
+	got  []notify.Event
+	err  error
+	name string
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n notify.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, n)
+	return r.err
+}
+
+func TestUpdaterNotifiesEveryChannel(t *testing.T) {
+	broken := &recordingNotifier{name: "broken", err: errors.New("unreachable")}
+	working := &recordingNotifier{name: "working"}
+
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	api.readOnly = true
+	cfg := Config{RecordNames: []string{"example.com"}, NotifyOn: map[string]bool{notifyFailure: true}}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.notifiers = &notify.Registry{}
+	u.notifiers.Register(broken.name, broken)
+	u.notifiers.Register(working.name, working)
+
+	if err := u.cycle(context.Background()); err == nil {
+		t.Fatalf("expected the write to fail")
+	}
+
+	for _, r := range []*recordingNotifier{broken, working} {
+		if len(r.got) != 1 || r.got[0].Kind != notifyFailure || !strings.HasPrefix(r.got[0].Message, "run failed: ") {
+			t.Fatalf("%s: unexpected notifications %+v", r.name, r.got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const defaultOutageThreshold = 3
+
+// errCloudflareOutage is returned for Cloudflare calls made after a run
+// reached CF_OUTAGE_THRESHOLD server errors in a row.
+var errCloudflareOutage = errors.New("Cloudflare API outage suspected")
+
+// outageDetector counts Cloudflare API calls that end in a 5xx status in a
+// row, across runs through the state file, so CF_OUTAGE_COOLDOWN can pause
+// runs during a provider outage instead of retrying into it. A zero
+// threshold disables it.
+type outageDetector struct {
+	threshold int64
+	streak    atomic.Int64
+}
+
+func newOutageDetector(threshold int) *outageDetector {
+	return &outageDetector{threshold: int64(threshold)}
+}
+
+// tripped reports whether the streak reached the threshold.
+func (d *outageDetector) tripped() bool {
+	return d.threshold > 0 && d.streak.Load() >= d.threshold
+}
+
+// middleware records the outcome of every call and fails those made once
+// the detector has tripped without sending them. It sits outside the retry
+// middleware, so a call counts once its retries are used up.
+func (d *outageDetector) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if d.tripped() {
+			return nil, fmt.Errorf("%w (%d server errors in a row)", errCloudflareOutage, d.streak.Load())
+		}
+		resp, err := next.RoundTrip(req)
+		if err == nil {
+			if resp.StatusCode >= http.StatusInternalServerError {
+				d.streak.Add(1)
+			} else {
+				d.streak.Store(0)
+			}
+		}
+		return resp, err
+	})
+}
+
+// coolingDown reports whether a previous run started a CF_OUTAGE_COOLDOWN
+// that has not passed yet, in which case the run is skipped. Otherwise the
+// detector picks up the streak left by earlier runs.
+func (u *updater) coolingDown() bool {
+	if u.cfg.OutageCooldown <= 0 {
+		return false
+	}
+	state, err := loadState(u.cfg.StateFile)
+	if err != nil {
+		log.Printf("warning: failed to load state file for %s: %v", envOutageCooldown, err)
+		return false
+	}
+	if now := u.clock.Now(); now.Before(state.CooldownUntil) {
+		log.Printf("Cloudflare outage cooldown until %s; skipping run", state.CooldownUntil.UTC().Format(time.RFC3339))
+		return true
+	}
+	u.outage.streak.Store(int64(state.APIErrorStreak))
+	return false
+}
+
+// trackOutage stores the server error streak of the run in the state file
+// and, once it reaches the threshold, starts a cooldown of
+// CF_OUTAGE_COOLDOWN.
+func (u *updater) trackOutage() {
+	if u.cfg.OutageCooldown <= 0 {
+		return
+	}
+	state, err := loadState(u.cfg.StateFile)
+	if err != nil {
+		log.Printf("warning: failed to load state file for %s: %v", envOutageCooldown, err)
+		return
+	}
+
+	streak := int(u.outage.streak.Load())
+	switch {
+	case u.outage.tripped():
+		state.CooldownUntil = u.clock.Now().Add(u.cfg.OutageCooldown)
+		state.APIErrorStreak = 0
+		log.Printf("warning: Cloudflare answered %d calls in a row with a server error; pausing runs for %s until %s", streak, u.cfg.OutageCooldown, state.CooldownUntil.UTC().Format(time.RFC3339))
+	case streak != state.APIErrorStreak:
+		state.APIErrorStreak = streak
+	default:
+		return
+	}
+	if err := saveState(u.cfg.StateFile, state); err != nil {
+		log.Printf("warning: failed to save state file: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUpdaterOutageCooldown(t *testing.T) {
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "198.51.100.1"),
+	)
+	api.unavailable = true
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{
+		RecordNames: []string{"a.example.com", "b.example.com"}, Concurrency: 1, StateFile: statePath,
+		OutageCooldown: 30 * time.Minute, OutageThreshold: 3,
+	}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.outage = newOutageDetector(cfg.OutageThreshold)
+	var calls atomic.Int32
+	count := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return next.RoundTrip(req)
+		})
+	}
+	client, err := newCloudflareClient(withMiddlewares(api.client(), count), u.cfg, nil, u.outage.middleware)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	u.cfClient = client
+	clock := u.clock.(*fakeClock)
+
+	// Two server errors leave the streak short of the threshold.
+	if _, err := u.run(context.Background()); err == nil {
+		t.Fatalf("expected the run to fail while Cloudflare is down")
+	}
+	state, _ := loadState(statePath)
+	if state.APIErrorStreak != 2 || !state.CooldownUntil.IsZero() {
+		t.Fatalf("unexpected state after the first run: %+v", state)
+	}
+
+	// The streak carries over; the third error starts the cooldown and the
+	// remaining call is not sent.
+	calls.Store(0)
+	_, err = u.run(context.Background())
+	if !errors.Is(err, errCloudflareOutage) {
+		t.Fatalf("expected later calls to fail fast, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected one call before the detector tripped, got %d", n)
+	}
+	state, _ = loadState(statePath)
+	if want := clock.Now().Add(30 * time.Minute); !state.CooldownUntil.Equal(want) {
+		t.Fatalf("expected a cooldown until %s, got %+v", want, state)
+	}
+
+	// Runs during the cooldown are skipped without calling Cloudflare.
+	calls.Store(0)
+	api.unavailable = false
+	results, err := u.run(context.Background())
+	if err != nil || len(results) != 2 || results[0].Action != actionSkipped || calls.Load() != 0 {
+		t.Fatalf("expected a skipped run, got %+v, %v and %d call(s)", results, err, calls.Load())
+	}
+
+	// Once the cooldown has passed, runs go ahead and the detector resets.
+	clock.Advance(31 * time.Minute)
+	u.outage = newOutageDetector(cfg.OutageThreshold)
+	client, _ = newCloudflareClient(api.client(), u.cfg, nil, u.outage.middleware)
+	u.cfClient = client
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected the run after the cooldown to succeed, got %v", err)
+	}
+	if api.updates != 2 {
+		t.Fatalf("expected both records to be updated, got %d update(s)", api.updates)
+	}
+}
+
+func TestLoadConfigOutageCooldown(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+
+	t.Setenv(envOutageCooldown, "30m")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected %s to require %s", envOutageCooldown, envStateFile)
+	}
+
+	t.Setenv(envStateFile, filepath.Join(t.TempDir(), "state.json"))
+	cfg, err := loadConfig()
+	if err != nil || cfg.OutageCooldown != 30*time.Minute || cfg.OutageThreshold != defaultOutageThreshold {
+		t.Fatalf("unexpected config %s, %d, %v", cfg.OutageCooldown, cfg.OutageThreshold, err)
+	}
+
+	t.Setenv(envOutageThreshold, "0")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected an invalid threshold to be rejected")
+	}
+
+	t.Setenv(envOutageCooldown, "")
+	t.Setenv(envOutageThreshold, "5")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected %s to require %s", envOutageThreshold, envOutageCooldown)
+	}
+}
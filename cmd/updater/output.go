@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputReport = "report"
+)
+
+// jsonResult is the machine-readable form of a Result written to stdout
+// when CF_OUTPUT=json.
+type jsonResult struct {
+	Result     string `json:"result"`
+	Record     string `json:"record"`
+	Type       string `json:"type"`
+	Old        string `json:"old,omitempty"`
+	New        string `json:"new,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Resolved   string `json:"resolved,omitempty"`
+	VerifyErr  string `json:"verify_error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Timestamp  string `json:"timestamp"`
+	RunID      string `json:"run_id,omitempty"`
+}
+
+// writeJSONResult writes r, produced by run runID, to w as a single line of
+// JSON.
+func writeJSONResult(w io.Writer, r Result, runID string, now time.Time) error {
+	result := newJSONResult(r, now)
+	result.RunID = runID
+	return json.NewEncoder(w).Encode(result)
+}
+
+// newJSONResult converts r to its machine-readable form.
+func newJSONResult(r Result, now time.Time) jsonResult {
+	result := jsonResult{
+		Result:     r.Action,
+		Record:     r.Record,
+		Type:       r.Type,
+		Old:        r.OldIP,
+		New:        r.NewIP,
+		Resolved:   r.Resolved,
+		DurationMS: r.Duration.Milliseconds(),
+		Timestamp:  now.UTC().Format(time.RFC3339),
+	}
+	if r.Err != nil {
+		result.Error = r.Err.Error()
+	}
+	if r.VerifyErr != nil {
+		result.VerifyErr = r.VerifyErr.Error()
+	}
+	return result
+}
+
+// writeReport writes results to w as a table for CF_OUTPUT=report and the
+// summary of multi-record runs: the detected and previous content, what the
+// run did and how long it took, and what DNS resolved to.
+func writeReport(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RECORD\tTYPE\tDETECTED\tPREVIOUS\tRESULT\tDURATION\tRESOLVED\tVERIFIED")
+	for _, r := range results {
+		verified := "-"
+		switch {
+		case r.VerifyErr != nil:
+			verified = "no"
+		case r.Resolved != "":
+			verified = "yes"
+		}
+		duration := "-"
+		if r.Duration > 0 {
+			duration = r.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			orDash(r.Record), orDash(r.Type), orDash(r.NewIP), orDash(r.OldIP), r.Action, duration, orDash(r.Resolved), verified)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(w, "%s: %v\n", orDash(r.Record), r.Err)
+		case r.VerifyErr != nil:
+			fmt.Fprintf(w, "%s: %v\n", r.Record, r.VerifyErr)
+		}
+	}
+	return nil
+}
+
+// logSummary logs the report table for runs that touched several records,
+// so text output ends with the outcome of every record at a glance.
+func logSummary(results []Result) {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, results); err != nil {
+		log.Printf("warning: failed to write summary: %v", err)
+		return
+	}
+	log.Printf("summary of %d record(s):", len(results))
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		log.Printf("  %s", line)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPrecheckTimeout = 3 * time.Second
+	precheckPlaceholder    = "{ip}"
+)
+
+// parsePrecheckTarget validates a CF_PRECHECK_TARGET value and returns it as
+// host:port. A bare port, or one written as ":port", is dialed on the
+// detected address itself; a host may also embed it as {ip}.
+func parsePrecheckTarget(value string) (string, error) {
+	target := strings.TrimSpace(value)
+	if !strings.Contains(target, ":") {
+		target = ":" + target
+	}
+	host, port, err := net.SplitHostPort(target)
+	if n, perr := strconv.Atoi(port); err != nil || perr != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid %s value %q (expected a port or host:port)", envPrecheckTarget, value)
+	}
+	if host == "" {
+		host = precheckPlaceholder
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// precheck opens a TCP connection to target, with {ip} replaced by ip, to
+// confirm the address is serving before it is published.
+func precheck(ctx context.Context, target, ip string, timeout time.Duration) error {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(strings.ReplaceAll(host, precheckPlaceholder, ip), port)
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParsePrecheckTarget(t *testing.T) {
+	cases := map[string]string{
+		"443":               "{ip}:443",
+		":8443":             "{ip}:8443",
+		"{ip}:443":          "{ip}:443",
+		"probe.example:443": "probe.example:443",
+	}
+	for value, want := range cases {
+		if got, err := parsePrecheckTarget(value); err != nil || got != want {
+			t.Errorf("parsePrecheckTarget(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"https", "0", "host:70000", "example.com"} {
+		if _, err := parsePrecheckTarget(value); err == nil {
+			t.Errorf("parsePrecheckTarget(%q): expected an error", value)
+		}
+	}
+}
+
+// closedPort returns a local port nothing is listening on.
+func closedPort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+	return port
+}
+
+func TestUpdaterPrecheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	_, openPort, _ := net.SplitHostPort(ln.Addr().String())
+
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"home.example.com"}, PrecheckTarget: "{ip}:" + closedPort(t), PrecheckTimeout: time.Second}
+	u := newTestUpdater(t, cfg, api, "127.0.0.1")
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected a failed check to skip the update, got %v", err)
+	}
+	if results[0].Action != actionSkipped || api.updates != 0 {
+		t.Fatalf("expected the update to be skipped, got %+v after %d update(s)", results, api.updates)
+	}
+
+	u.cfg.PrecheckTarget = "{ip}:" + openPort
+	results, err = u.run(context.Background())
+	if err != nil || results[0].Action != actionChanged {
+		t.Fatalf("expected the update once the address is serving, got %+v, %v", results, err)
+	}
+}
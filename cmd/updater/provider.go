@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+	"github.com/cloudflare/cloudflare-go/v2/option"
+)
+
+// CF_MULTI_MATCH policies for a name with several records of its type.
+const (
+	multiMatchFirst = "first"
+	multiMatchAll   = "all"
+	multiMatchError = "error"
+)
+
+// DNSProvider reads and writes the records synchronized by syncRecord.
+// Cloudflare is the only provider; the interface keeps the sync logic apart
+// from the API client so it can be exercised without one.
+type DNSProvider interface {
+	// Fetch returns the record to manage for cfg.RecordName, or an error
+	// wrapping errRecordNotFound when there is none.
+	Fetch(ctx context.Context, cfg Config) (dns.Record, error)
+	// FetchRecords returns every record named cfg.RecordName of
+	// cfg.RecordType, leaving the choice among them to the caller. No match
+	// is not an error; the slice is then empty.
+	FetchRecords(ctx context.Context, cfg Config) ([]dns.Record, error)
+	// Update writes content to the record with the given ID.
+	Update(ctx context.Context, cfg Config, recordID, content string) error
+	// Create creates the record named cfg.RecordName with content.
+	Create(ctx context.Context, cfg Config, content string) error
+}
+
+// providerFunc returns the DNSProvider for the records of cfg, written
+// through client.
+type providerFunc func(client *cloudflare.Client, cfg Config) DNSProvider
+
+// newCloudflareProvider is the providerFunc used outside of tests. With
+// CF_MINIMAL the records of a group are found in a single listing.
+func newCloudflareProvider(client *cloudflare.Client, cfg Config) DNSProvider {
+	provider := cloudflareProvider{client: client}
+	if cfg.Minimal && len(cfg.RecordNames) > 1 {
+		provider.listing = listingFetcher(cfg)
+	}
+	return provider
+}
+
+// cloudflareProvider is the DNSProvider backed by the Cloudflare API.
+type cloudflareProvider struct {
+	client *cloudflare.Client
+	// listing, when set, finds records in a shared listing instead of
+	// looking each one up (CF_MINIMAL).
+	listing recordFetcher
+}
+
+// Fetch returns the record to manage for cfg.RecordName, chosen among the
+// matches by pickRecord.
+func (p cloudflareProvider) Fetch(ctx context.Context, cfg Config) (dns.Record, error) {
+	records, err := p.FetchRecords(ctx, cfg)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	return pickRecord(records, cfg)
+}
+
+// FetchRecords returns every record named cfg.RecordName of cfg.RecordType,
+// in the order Cloudflare lists them, following every page. CF_RECORD_TAG
+// does not narrow the lookup, so an untagged record is found and tagged
+// instead of duplicated.
+func (p cloudflareProvider) FetchRecords(ctx context.Context, cfg Config) ([]dns.Record, error) {
+	if p.listing != nil {
+		return p.listing(ctx, p.client, cfg)
+	}
+	return listRecordPages(ctx, p.client, dns.RecordListParams{
+		ZoneID: cloudflare.String(cfg.ZoneID),
+		Name:   cloudflare.String(cfg.RecordName),
+		Type:   cloudflare.F(dns.RecordListParamsType(cfg.RecordType)),
+	})
+}
+
+// Update writes content to the record.
+func (p cloudflareProvider) Update(ctx context.Context, cfg Config, recordID, content string) error {
+	params := dns.RecordUpdateParams{
+		ZoneID: cloudflare.String(cfg.ZoneID),
+		Record: recordParam(cfg, content),
+	}
+
+	var resp *http.Response
+	var envelope dns.RecordUpdateResponseEnvelope
+	opts := append(extraFieldOptions(cfg.ExtraFields), option.WithResponseBodyInto(&envelope), option.WithResponseInto(&resp))
+	if _, err := p.client.DNS.Records.Update(ctx, recordID, params, opts...); err != nil {
+		return asPermissionError(withRayID(err, resp))
+	}
+	return asPermissionError(withRayID(checkSuccess(envelope.JSON.RawJSON()), resp))
+}
+
+// Create creates the record named cfg.RecordName with content.
+func (p cloudflareProvider) Create(ctx context.Context, cfg Config, content string) error {
+	params := dns.RecordNewParams{
+		ZoneID: cloudflare.String(cfg.ZoneID),
+		Record: recordParam(cfg, content),
+	}
+
+	var resp *http.Response
+	var envelope dns.RecordNewResponseEnvelope
+	opts := append(extraFieldOptions(cfg.ExtraFields), option.WithResponseBodyInto(&envelope), option.WithResponseInto(&resp))
+	if _, err := p.client.DNS.Records.New(ctx, params, opts...); err != nil {
+		return asPermissionError(withRayID(err, resp))
+	}
+	return asPermissionError(withRayID(checkSuccess(envelope.JSON.RawJSON()), resp))
+}
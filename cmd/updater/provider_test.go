@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+// fakeProvider is an in-memory DNSProvider holding A records by name.
+type fakeProvider struct {
+	records map[string][]dns.Record
+	updates []string
+	creates []string
+}
+
+func (p *fakeProvider) Fetch(ctx context.Context, cfg Config) (dns.Record, error) {
+	records, err := p.FetchRecords(ctx, cfg)
+	if err != nil {
+		return dns.Record{}, err
+	}
+	return pickRecord(records, cfg)
+}
+
+func (p *fakeProvider) FetchRecords(ctx context.Context, cfg Config) ([]dns.Record, error) {
+	return p.records[cfg.RecordName], nil
+}
+
+func (p *fakeProvider) Update(ctx context.Context, cfg Config, recordID, content string) error {
+	p.updates = append(p.updates, recordID+"="+content)
+	return nil
+}
+
+func (p *fakeProvider) Create(ctx context.Context, cfg Config, content string) error {
+	p.creates = append(p.creates, cfg.RecordName+"="+content)
+	return nil
+}
+
+// decodeRecord builds a dns.Record from a fixture such as aRecordFixture.
+func decodeRecord(t *testing.T, fixture map[string]any) dns.Record {
+	t.Helper()
+	payload, err := json.Marshal(fixture)
+	if err != nil {
+		t.Fatalf("marshal record: %v", err)
+	}
+	var record dns.Record
+	if err := json.Unmarshal(payload, &record); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+	return record
+}
+
+func TestSyncRecordThroughProvider(t *testing.T) {
+	record := decodeRecord(t, aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	provider := &fakeProvider{records: map[string][]dns.Record{"home.example.com": {record}}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cfg := Config{RecordName: "home.example.com", RecordType: "A", TTL: 1, RecordMode: recordModeSync}
+	result, err := syncRecord(context.Background(), provider, cfg, "203.0.113.10", now)
+	if err != nil || result.Action != actionChanged || result.OldIP != "198.51.100.1" {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+	if len(provider.updates) != 1 || provider.updates[0] != "id-1=203.0.113.10" {
+		t.Fatalf("unexpected updates %v", provider.updates)
+	}
+
+	cfg.RecordName = "new.example.com"
+	result, err = syncRecord(context.Background(), provider, cfg, "203.0.113.10", now)
+	if err != nil || result.Action != actionCreated {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+	if len(provider.creates) != 1 || provider.creates[0] != "new.example.com=203.0.113.10" {
+		t.Fatalf("unexpected creates %v", provider.creates)
+	}
+
+	cfg.RecordMode = recordModeUpdate
+	cfg.RecordName = "missing.example.com"
+	if _, err := syncRecord(context.Background(), provider, cfg, "203.0.113.10", now); err == nil {
+		t.Fatalf("expected a missing record to fail in update mode")
+	}
+}
+
+func TestSyncRecordMultiMatch(t *testing.T) {
+	provider := &fakeProvider{records: map[string][]dns.Record{"home.example.com": {
+		decodeRecord(t, aRecordFixture("id-1", "home.example.com", "203.0.113.10")),
+		decodeRecord(t, aRecordFixture("id-2", "home.example.com", "198.51.100.2")),
+		decodeRecord(t, aRecordFixture("id-3", "home.example.com", "198.51.100.3")),
+	}}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := Config{RecordName: "home.example.com", RecordType: "A", TTL: 300, RecordMode: recordModeUpdate, MultiMatch: multiMatchFirst}
+
+	result, err := syncRecord(context.Background(), provider, cfg, "203.0.113.10", now)
+	if err != nil || result.Action != actionUnchanged || len(provider.updates) != 0 {
+		t.Fatalf("expected only the first record to be managed, got %+v, %v, %v", result, err, provider.updates)
+	}
+
+	cfg.MultiMatch = multiMatchError
+	if _, err := syncRecord(context.Background(), provider, cfg, "203.0.113.10", now); !errors.Is(err, errMultipleRecords) {
+		t.Fatalf("expected a multiple records error, got %v", err)
+	}
+	cfg.MatchContent = "198.51.100.2"
+	if _, err := syncRecord(context.Background(), provider, cfg, "203.0.113.10", now); err != nil {
+		t.Fatalf("expected %s to settle the ambiguity, got %v", envMatchContent, err)
+	}
+	provider.updates = nil
+
+	cfg.MultiMatch = multiMatchAll
+	cfg.MatchContent = ""
+	result, err = syncRecord(context.Background(), provider, cfg, "203.0.113.10", now)
+	if err != nil || result.Action != actionChanged || result.OldIP != "198.51.100.2" {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+	if want := []string{"id-2=203.0.113.10", "id-3=203.0.113.10"}; !slices.Equal(provider.updates, want) {
+		t.Fatalf("expected every stale record to be updated, got %v", provider.updates)
+	}
+}
+
+func TestUpdaterUsesInjectedProvider(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com", "new.example.com"}, RecordMode: recordModeSync}, api, "203.0.113.10")
+	var calls int
+	u.cfClient, _ = newCloudflareClient(&http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return nil, fmt.Errorf("unexpected request to %s", req.URL)
+	})}, u.cfg, nil)
+	u.provider = func(client *cloudflare.Client, cfg Config) DNSProvider { return api }
+
+	results, err := u.run(context.Background())
+	if err != nil || len(results) != 2 || results[0].Action != actionChanged || results[1].Action != actionCreated {
+		t.Fatalf("unexpected results %+v, %v", results, err)
+	}
+	if calls != 0 || api.updates != 1 || api.creates != 1 {
+		t.Fatalf("expected the writes to go through the provider, got %d request(s), %d update(s), %d create(s)", calls, api.updates, api.creates)
+	}
+	if api.records["home.example.com"]["content"] != "203.0.113.10" || api.records["new.example.com"]["content"] != "203.0.113.10" {
+		t.Fatalf("unexpected records %v", api.records)
+	}
+
+	api.readOnly = true
+	result, err := syncRecord(context.Background(), api, Config{RecordName: "home.example.com", RecordType: "A", TTL: 300}, "203.0.113.11", time.Now())
+	var permErr *PermissionError
+	if !errors.As(err, &permErr) || result.Action != actionError {
+		t.Fatalf("expected a permission error from the read-only mock, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// pushSecretHeader carries CF_WEBHOOK_SECRET on push requests.
+	pushSecretHeader = "X-DDNS-Secret"
+
+	maxPushBody = 1 << 10
+)
+
+// pushServer receives POSTs from a router announcing a WAN address change
+// and triggers an immediate update cycle in watch mode. A request may carry
+// the new address in its body, which is then used instead of discovery.
+type pushServer struct {
+	secret  string
+	trigger chan struct{}
+
+	mu  sync.Mutex
+	ips map[string]string // pushed addresses by family
+}
+
+func newPushServer(secret string) *pushServer {
+	return &pushServer{secret: secret, trigger: make(chan struct{}, 1)}
+}
+
+func (p *pushServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if p.secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(pushSecretHeader)), []byte(p.secret)) != 1 {
+		http.Error(w, "invalid secret", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPushBody))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if value := strings.TrimSpace(string(body)); value != "" {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			http.Error(w, fmt.Sprintf("invalid IP address %q", value), http.StatusBadRequest)
+			return
+		}
+		family := familyIPv4
+		if ip.To4() == nil {
+			family = familyIPv6
+		}
+		p.mu.Lock()
+		if p.ips == nil {
+			p.ips = make(map[string]string)
+		}
+		p.ips[family] = ip.String()
+		p.mu.Unlock()
+		outsideRunLogger().Printf("push from %s announced %s address %s", r.RemoteAddr, family, ip)
+	} else {
+		outsideRunLogger().Printf("push from %s requested an update", r.RemoteAddr)
+	}
+
+	// A cycle already pending will pick up the pushed address too.
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "update triggered")
+}
+
+// take returns the pushed addresses by family and forgets them, so each
+// push applies to one cycle only. It is safe to call on a nil server.
+func (p *pushServer) take() map[string]string {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ips := p.ips
+	p.ips = nil
+	return ips
+}
+
+// servePush exposes p on addr until ctx is done.
+func servePush(ctx context.Context, addr string, p *pushServer) error {
+	logger := outsideRunLogger()
+	server := &http.Server{Addr: addr, Handler: p, ReadHeaderTimeout: defaultHTTPTimeout, ErrorLog: logger}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	logger.Printf("push webhook listening on %s", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPushServer(t *testing.T) {
+	p := newPushServer("s3cret")
+
+	cases := []struct {
+		method, secret, body string
+		want                 int
+	}{
+		{http.MethodGet, "s3cret", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "wrong", "", http.StatusUnauthorized},
+		{http.MethodPost, "s3cret", "not-an-ip", http.StatusBadRequest},
+		{http.MethodPost, "s3cret", "203.0.113.7\n", http.StatusAccepted},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/", strings.NewReader(c.body))
+		req.Header.Set(pushSecretHeader, c.secret)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s with secret %q and body %q: got status %d, want %d", c.method, c.secret, c.body, rec.Code, c.want)
+		}
+	}
+
+	select {
+	case <-p.trigger:
+	default:
+		t.Fatalf("expected accepted push to trigger a cycle")
+	}
+	if ips := p.take(); ips[familyIPv4] != "203.0.113.7" {
+		t.Fatalf("expected pushed address, got %v", ips)
+	}
+	if ips := p.take(); ips != nil {
+		t.Fatalf("expected pushed address to apply once, got %v", ips)
+	}
+}
+
+func TestUpdaterUsesPushedAddress(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"example.com"}}
+	u := newTestUpdater(t, cfg, api, "192.0.2.1")
+	u.push = newPushServer("")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("203.0.113.7"))
+	u.push.ServeHTTP(httptest.NewRecorder(), req)
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if results[0].NewIP != "203.0.113.7" {
+		t.Fatalf("expected pushed address to be published, got %+v", results[0])
+	}
+
+	// The next cycle discovers the address again.
+	if results, _ = u.run(context.Background()); results[0].NewIP != "192.0.2.1" {
+		t.Fatalf("expected discovered address on the next cycle, got %+v", results[0])
+	}
+}
+
+func TestUpdaterAutoUsesPushedAddress(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"example.com"}, RecordType: recordTypeAuto}
+	u := newTestUpdater(t, cfg, api, "192.0.2.1")
+	u.push = newPushServer("")
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("203.0.113.7"))
+	u.push.ServeHTTP(httptest.NewRecorder(), req)
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if results[0].Type != "A" || results[0].NewIP != "203.0.113.7" {
+		t.Fatalf("expected the pushed address to resolve AUTO, got %+v", results[0])
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+	"github.com/cloudflare/cloudflare-go/v2/option"
+)
+
+const (
+	recordModeUpdate = "update"
+	recordModeSync   = "sync"
+
+	// managedComment marks records written in sync mode. Pruning only ever
+	// deletes records carrying it, possibly followed by an audit comment.
+	managedComment = "managed by cloudflare-ddns-cron"
+
+	listPerPage = 100
+)
+
+// deleteDNSRecord removes the record with the given ID.
+func deleteDNSRecord(ctx context.Context, client *cloudflare.Client, cfg Config, recordID string) error {
+	params := dns.RecordDeleteParams{ZoneID: cloudflare.String(cfg.ZoneID)}
+
+	var resp *http.Response
+	var envelope dns.RecordDeleteResponseEnvelope
+	if _, err := client.DNS.Records.Delete(ctx, recordID, params, option.WithResponseBodyInto(&envelope), option.WithResponseInto(&resp)); err != nil {
+		return asPermissionError(withRayID(err, resp))
+	}
+	return asPermissionError(withRayID(checkSuccess(envelope.JSON.RawJSON()), resp))
+}
+
+// listManagedRecords returns every record of cfg.RecordType in the zone that
+// carries the managed marker.
+func listManagedRecords(ctx context.Context, client *cloudflare.Client, cfg Config) ([]dns.Record, error) {
+	records, err := listRecords(ctx, client, cfg, managedComment)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(records, func(r dns.Record) bool { return !isManaged(r.Comment) }), nil
+}
+
+// listRecords returns every record of cfg.RecordType in the zone, or only
+// those whose comment starts with commentPrefix when it is set.
+// CF_RECORD_TAG narrows the listing further.
+func listRecords(ctx context.Context, client *cloudflare.Client, cfg Config, commentPrefix string) ([]dns.Record, error) {
+	params := dns.RecordListParams{
+		ZoneID: cloudflare.String(cfg.ZoneID),
+		Type:   cloudflare.F(dns.RecordListParamsType(cfg.RecordType)),
+	}
+	if commentPrefix != "" {
+		params.Comment = cloudflare.F(dns.RecordListParamsComment{Startswith: cloudflare.String(commentPrefix)})
+	}
+	if cfg.RecordTag != "" {
+		params.Tag = cloudflare.F(tagFilter(cfg.RecordTag))
+	}
+	return listRecordPages(ctx, client, params)
+}
+
+// listRecordPages returns every record matching params, fetching
+// listPerPage records at a time until a short page.
+func listRecordPages(ctx context.Context, client *cloudflare.Client, params dns.RecordListParams) ([]dns.Record, error) {
+	var records []dns.Record
+	for pageNumber := 1; ; pageNumber++ {
+		params.Page = cloudflare.F(float64(pageNumber))
+		params.PerPage = cloudflare.F(float64(listPerPage))
+
+		var resp *http.Response
+		page, err := client.DNS.Records.List(ctx, params, option.WithResponseInto(&resp))
+		if err != nil {
+			return nil, withRayID(err, resp)
+		}
+		if err := checkSuccess(page.JSON.RawJSON()); err != nil {
+			return nil, withRayID(err, resp)
+		}
+
+		records = append(records, page.Result...)
+		if len(page.Result) < listPerPage {
+			return records, nil
+		}
+	}
+}
+
+// pruneRecords deletes managed records whose names are no longer configured.
+// In dry-run mode the deletions are only logged.
+func pruneRecords(ctx context.Context, client *cloudflare.Client, cfg Config) ([]Result, error) {
+	records, err := listManagedRecords(ctx, client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list managed records: %w", err)
+	}
+
+	desired := make(map[string]bool, len(cfg.RecordNames))
+	for _, name := range slices.Concat(cfg.RecordNames, cfg.DisabledNames) {
+		desired[normalizeName(name, cfg.ZoneName)] = true
+	}
+
+	var results []Result
+	for _, record := range records {
+		if desired[normalizeName(record.Name, cfg.ZoneName)] || !isManaged(record.Comment) {
+			continue
+		}
+
+		current, _ := extractRecordContent(record)
+		result := Result{Action: actionDeleted, Record: record.Name, Type: string(record.Type), OldIP: current}
+		if cfg.DryRun {
+			log.Printf("dry run: would delete stale record %s", record.Name)
+			result.Action = actionDryRun
+		} else if cfg.ReadOnly {
+			log.Printf("read-only: stale record %s needs deleting", record.Name)
+			result.Action = actionNeeded
+		} else if err := deleteDNSRecord(ctx, client, cfg, record.ID); err != nil {
+			result.Action = actionError
+			result.Err = fmt.Errorf("failed to delete DNS record: %w", err)
+			log.Printf("%s: %v", record.Name, result.Err)
+		} else {
+			log.Printf("deleted stale record %s", record.Name)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// RecordState is what the last run learned about one record.
+type RecordState struct {
+	// Content is the record's content as last seen or written.
+	Content     string    `json:"content"`
+	LastChecked time.Time `json:"last_checked"`
+	// LastChanged is when the updater last wrote the record; it stays zero
+	// until the first update or creation.
+	LastChanged time.Time `json:"last_changed"`
+	// Writes holds when the record was written within the last
+	// writeWindow, for CF_MAX_WRITES_PER_HOUR.
+	Writes []time.Time `json:"writes,omitempty"`
+}
+
+// writeWindow is the sliding window CF_MAX_WRITES_PER_HOUR applies to.
+const writeWindow = time.Hour
+
+// recentWrites returns the writes in rs within writeWindow before now.
+func (rs RecordState) recentWrites(now time.Time) []time.Time {
+	var recent []time.Time
+	for _, at := range rs.Writes {
+		if now.Sub(at) < writeWindow {
+			recent = append(recent, at)
+		}
+	}
+	return recent
+}
+
+// recordKey identifies a record in State.Records.
+func recordKey(name, recordType string) string {
+	return name + "/" + recordType
+}
+
+// recordResult stores the outcome of r at now and reports whether the state
+// changed. Dry runs, failures and cached results tell nothing new about the
+// live record and are ignored.
+func (st *State) recordResult(r Result, now time.Time) bool {
+	if r.Cached {
+		return false
+	}
+	key := recordKey(r.Record, r.Type)
+	switch r.Action {
+	case actionUnchanged:
+		rs := st.Records[key]
+		rs.Content = r.OldIP
+		rs.LastChecked = now
+		st.setRecord(key, rs)
+	case actionChanged, actionCreated:
+		writes := append(st.Records[key].recentWrites(now), now)
+		st.setRecord(key, RecordState{Content: r.NewIP, LastChecked: now, LastChanged: now, Writes: writes})
+	case actionDeleted:
+		if _, ok := st.Records[key]; !ok {
+			return false
+		}
+		delete(st.Records, key)
+	default:
+		return false
+	}
+	return true
+}
+
+func (st *State) setRecord(key string, rs RecordState) {
+	if st.Records == nil {
+		st.Records = make(map[string]RecordState)
+	}
+	st.Records[key] = rs
+}
+
+// recentlyChecked reports whether the record named name already held content
+// when it was checked less than maxAge before now, so the API lookup can be
+// skipped. A zero maxAge always checks.
+func (st State) recentlyChecked(name, recordType, content string, now time.Time, maxAge time.Duration) bool {
+	if maxAge <= 0 {
+		return false
+	}
+	rs, ok := st.Records[recordKey(name, recordType)]
+	return ok && rs.Content == content && now.Sub(rs.LastChecked) < maxAge
+}
+
+// writesInWindow returns how often the record named name was written within
+// writeWindow before now.
+func (st State) writesInWindow(name, recordType string, now time.Time) int {
+	return len(st.Records[recordKey(name, recordType)].recentWrites(now))
+}
+
+// recordResults stores every result in the state file, when one is
+// configured.
+func (u *updater) recordResults(cfg Config, state *State, results []Result) {
+	if cfg.StateFile == "" {
+		return
+	}
+
+	dirty := false
+	now := u.clock.Now()
+	for _, r := range results {
+		if line := state.sinceLastRun(r, now); line != "" {
+			log.Printf("since last run: %s", line)
+		}
+		if state.recordResult(r, now) {
+			dirty = true
+		}
+	}
+	if dirty {
+		if err := saveState(cfg.StateFile, *state); err != nil {
+			log.Printf("warning: failed to save state file: %v", err)
+		}
+	}
+}
+
+// sinceLastRun compares r with what the state file recorded for the same
+// record and describes the difference, or returns "" when there is no prior
+// state or r tells nothing new.
+func (st State) sinceLastRun(r Result, now time.Time) string {
+	if r.Cached {
+		return ""
+	}
+	rs, ok := st.Records[recordKey(r.Record, r.Type)]
+	if !ok || rs.LastChecked.IsZero() {
+		return ""
+	}
+
+	last := formatAge(now.Sub(rs.LastChecked))
+	switch r.Action {
+	case actionChanged, actionCreated:
+		if rs.Content == r.NewIP {
+			return fmt.Sprintf("%s rewritten with %s (%s ago)", r.Record, r.NewIP, last)
+		}
+		return fmt.Sprintf("%s changed %s -> %s (%s ago)", r.Record, rs.Content, r.NewIP, last)
+	case actionUnchanged:
+		line := fmt.Sprintf("%s unchanged at %s (%s ago", r.Record, r.OldIP, last)
+		if !rs.LastChanged.IsZero() {
+			line += fmt.Sprintf("; last changed %s ago", formatAge(now.Sub(rs.LastChanged)))
+		}
+		return line + ")"
+	}
+	return ""
+}
+
+// formatAge renders d coarsely, in the largest unit that fits: 45s, 14m, 5h
+// or 3d.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdaterRecordsPerRecordState(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "203.0.113.10"),
+	)
+	cfg := Config{RecordNames: []string{"a.example.com", "b.example.com"}, StateFile: statePath, RecheckInterval: 10 * time.Minute}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	clk := u.clock.(*fakeClock)
+	start := clk.Now()
+
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	state, err := loadState(statePath)
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+	if rs := state.Records["a.example.com/A"]; rs.Content != "203.0.113.10" || !rs.LastChanged.Equal(start) {
+		t.Fatalf("unexpected state for changed record %+v", rs)
+	}
+	if rs := state.Records["b.example.com/A"]; rs.Content != "203.0.113.10" || !rs.LastChecked.Equal(start) || !rs.LastChanged.IsZero() {
+		t.Fatalf("unexpected state for unchanged record %+v", rs)
+	}
+
+	clk.Advance(5 * time.Minute)
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	for _, r := range results {
+		if !r.Cached || r.Action != actionUnchanged {
+			t.Fatalf("expected recently checked records to be skipped, got %+v", r)
+		}
+	}
+
+	clk.Advance(10 * time.Minute)
+	results, _ = u.run(context.Background())
+	for _, r := range results {
+		if r.Cached {
+			t.Fatalf("expected records to be looked up again after the interval, got %+v", r)
+		}
+	}
+}
+
+func TestUpdaterAlwaysFetchIgnoresRecheckInterval(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "203.0.113.10"))
+	cfg := Config{RecordNames: []string{"example.com"}, StateFile: statePath, RecheckInterval: time.Hour, AlwaysFetch: true}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	for i := 0; i < 2; i++ {
+		results, err := u.run(context.Background())
+		if err != nil {
+			t.Fatalf("expected success, got %v", err)
+		}
+		if results[0].Cached || results[0].Action != actionUnchanged {
+			t.Fatalf("run %d: expected the record to be fetched, got %+v", i+1, results[0])
+		}
+	}
+}
+
+func TestUpdaterForceAndMaxRecordAgeBypassRecheck(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+	}{
+		{"force", Config{Force: true}},
+		{"max record age", Config{MaxRecordAge: 7 * 24 * time.Hour}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			record := aRecordFixture("id-1", "example.com", "203.0.113.10")
+			record["modified_on"] = "2023-12-25T00:00:00Z"
+			api := newMockCloudflare(record)
+			cfg := tc.cfg
+			cfg.RecordNames = []string{"example.com"}
+			cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+			cfg.RecheckInterval = time.Hour
+			u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+			// A warm state file: the record held the address a minute ago.
+			now := u.clock.Now()
+			warm := State{Records: map[string]RecordState{
+				"example.com/A": {Content: "203.0.113.10", LastChecked: now.Add(-time.Minute)},
+			}}
+			if err := saveState(cfg.StateFile, warm); err != nil {
+				t.Fatalf("save state: %v", err)
+			}
+
+			results, err := u.run(context.Background())
+			if err != nil {
+				t.Fatalf("expected success, got %v", err)
+			}
+			if results[0].Cached || results[0].Action != actionChanged || api.updates != 1 {
+				t.Fatalf("expected the record to be rewritten despite the cache, got %+v after %d update(s)", results[0], api.updates)
+			}
+		})
+	}
+}
+
+func TestSinceLastRun(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	state := State{Records: map[string]RecordState{
+		"home.example.com/A": {Content: "1.2.3.4", LastChecked: now.Add(-14 * time.Minute), LastChanged: now.Add(-3 * 24 * time.Hour)},
+	}}
+
+	cases := []struct {
+		result Result
+		want   string
+	}{
+		{Result{Action: actionChanged, Record: "home.example.com", Type: "A", OldIP: "1.2.3.4", NewIP: "5.6.7.8"}, "home.example.com changed 1.2.3.4 -> 5.6.7.8 (14m ago)"},
+		{Result{Action: actionUnchanged, Record: "home.example.com", Type: "A", OldIP: "1.2.3.4", NewIP: "1.2.3.4"}, "home.example.com unchanged at 1.2.3.4 (14m ago; last changed 3d ago)"},
+		{Result{Action: actionUnchanged, Record: "home.example.com", Type: "A", OldIP: "1.2.3.4", NewIP: "1.2.3.4", Cached: true}, ""},
+		{Result{Action: actionError, Record: "home.example.com", Type: "A"}, ""},
+		{Result{Action: actionChanged, Record: "new.example.com", Type: "A", NewIP: "5.6.7.8"}, ""},
+	}
+	for _, c := range cases {
+		if got := state.sinceLastRun(c.result, now); got != c.want {
+			t.Errorf("sinceLastRun(%+v) = %q, want %q", c.result, got, c.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	cases := map[time.Duration]string{
+		45 * time.Second:    "45s",
+		14 * time.Minute:    "14m",
+		5 * time.Hour:       "5h",
+		47 * time.Hour:      "47h",
+		3 * 24 * time.Hour:  "3d",
+		10*24*time.Hour + 1: "10d",
+	}
+	for d, want := range cases {
+		if got := formatAge(d); got != want {
+			t.Errorf("formatAge(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestUpdaterMaxWritesPerHour(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"home.example.com"}, StateFile: statePath, MaxWritesPerHour: 2}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	clock := u.clock.(*fakeClock)
+
+	// The record flaps back every time, so every run needs a write.
+	flap := func() Result {
+		t.Helper()
+		api.records["home.example.com"]["content"] = "198.51.100.1"
+		results, err := u.run(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		clock.Advance(20 * time.Minute)
+		return results[0]
+	}
+
+	for i := range 2 {
+		if r := flap(); r.Action != actionChanged {
+			t.Fatalf("run %d: expected a write, got %s", i+1, r.Action)
+		}
+	}
+	if r := flap(); r.Action != actionSkipped || api.updates != 2 {
+		t.Fatalf("expected the third write within the hour skipped, got %s after %d update(s)", r.Action, api.updates)
+	}
+	// The first write is now more than an hour old.
+	if r := flap(); r.Action != actionChanged || api.updates != 3 {
+		t.Fatalf("expected a write once the window slid, got %s after %d update(s)", r.Action, api.updates)
+	}
+
+	state, err := loadState(statePath)
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+	if writes := state.Records[recordKey("home.example.com", "A")].Writes; len(writes) != 2 {
+		t.Fatalf("expected only the writes of the last hour kept, got %v", writes)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// runReport is the snapshot of a run written to CF_REPORT_FILE.
+type runReport struct {
+	RunID      string `json:"run_id,omitempty"`
+	Timestamp  string `json:"timestamp"`
+	DurationMS int64  `json:"duration_ms"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	// DetectedIPs holds the published address per record type.
+	DetectedIPs map[string]string `json:"detected_ips,omitempty"`
+	Records     []jsonResult      `json:"records"`
+}
+
+// writeReportFile replaces path with the report of run runID, which started
+// at start and ended at end.
+func writeReportFile(path, runID string, results []Result, runErr error, start, end time.Time) error {
+	report := runReport{
+		RunID:      runID,
+		Timestamp:  end.UTC().Format(time.RFC3339),
+		DurationMS: end.Sub(start).Milliseconds(),
+		Success:    runErr == nil,
+		Records:    make([]jsonResult, 0, len(results)),
+	}
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+	for _, r := range results {
+		if isAddressType(r.Type) && r.NewIP != "" {
+			if report.DetectedIPs == nil {
+				report.DetectedIPs = make(map[string]string)
+			}
+			report.DetectedIPs[r.Type] = r.NewIP
+		}
+		report.Records = append(report.Records, newJSONResult(r, end))
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
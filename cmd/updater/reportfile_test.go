@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdaterWritesReportFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "203.0.113.10"),
+	)
+	cfg := Config{RecordNames: []string{"a.example.com", "b.example.com", "missing.example.com"}, ReportFile: path}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	if err := u.cycle(context.Background()); err == nil {
+		t.Fatalf("expected the missing record to fail the run")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Success || report.Error == "" || report.RunID != u.runID || report.DetectedIPs["A"] != "203.0.113.10" || len(report.Records) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.Records[0].Result != actionChanged || report.Records[1].Result != actionUnchanged || report.Records[2].Result != actionError {
+		t.Fatalf("unexpected record results %+v", report.Records)
+	}
+
+	cfg.RecordNames = cfg.RecordNames[:2]
+	u = newTestUpdater(t, cfg, api, "203.0.113.10")
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	report = runReport{}
+	if err := json.Unmarshal(data, &report); err != nil || !report.Success || report.RunID != u.runID || len(report.Records) != 2 {
+		t.Fatalf("expected the report to be replaced, got %+v, %v", report, err)
+	}
+}
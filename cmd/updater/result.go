@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	actionChanged   = "changed"
+	actionCreated   = "created"
+	actionDeleted   = "deleted"
+	actionUnchanged = "unchanged"
+	actionDryRun    = "dry-run"
+	actionNeeded    = "change-needed"
+	actionSkipped   = "skipped"
+	actionError     = "error"
+)
+
+// Result describes what a run did to a single record. It is the single source
+// for logs, JSON output and the process exit status.
+type Result struct {
+	Action string
+	Record string
+	// Label is how Record is shown in logs and notifications; see
+	// Config.displayName.
+	Label    string
+	Type     string
+	OldIP    string
+	NewIP    string
+	Duration time.Duration
+	Err      error
+	// Resolved holds the addresses seen by DNS verification, and VerifyErr
+	// why they did not match NewIP.
+	Resolved  string
+	VerifyErr error
+	// Changes compares the live record with the desired state. It is only
+	// filled in for records that were up to date or left alone in a dry run.
+	Changes []fieldChange
+	// Cached is set when the record was taken to be up to date from the
+	// state file, without looking it up.
+	Cached bool
+}
+
+// label returns r.Label, falling back to r.Record.
+func (r Result) label() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return r.Record
+}
+
+// countUnverified returns the number of results that failed DNS
+// verification.
+func countUnverified(results []Result) int {
+	var n int
+	for _, r := range results {
+		if r.VerifyErr != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// countNeeded returns the number of results a read-only run left out of
+// date.
+func countNeeded(results []Result) int {
+	var n int
+	for _, r := range results {
+		if r.Action == actionNeeded {
+			n++
+		}
+	}
+	return n
+}
+
+// failedRecordsError reports records that failed to update. It unwraps to
+// their errors so the cause can be classified.
+type failedRecordsError struct {
+	failed, total int
+	errs          []error
+}
+
+func (e *failedRecordsError) Error() string {
+	return fmt.Sprintf("%d of %d record(s) failed to update", e.failed, e.total)
+}
+
+func (e *failedRecordsError) Unwrap() []error {
+	return e.errs
+}
+
+// countFailed returns the number of results that ended in an error.
+func countFailed(results []Result) int {
+	var n int
+	for _, r := range results {
+		if r.Action == actionError {
+			n++
+		}
+	}
+	return n
+}
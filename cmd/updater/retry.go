@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	jitterFull         = "full"
+	jitterEqual        = "equal"
+	jitterNone         = "none"
+	jitterDecorrelated = "decorrelated"
+
+	defaultRetries        = 2
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+// RetryPolicy controls how failed Cloudflare API requests are retried.
+type RetryPolicy struct {
+	// Retries is the number of additional attempts after the first; zero
+	// disables retrying.
+	Retries   int
+	BaseDelay time.Duration
+	// MaxDelay caps each wait between attempts, including one requested
+	// by Retry-After.
+	MaxDelay time.Duration
+	// MaxElapsed, when set, stops retrying once another wait would take the
+	// request past this much time since its first attempt.
+	MaxElapsed time.Duration
+	// Jitter is one of full, equal, none, or decorrelated.
+	Jitter string
+}
+
+// maxDelay returns MaxDelay, or the default cap when it is unset.
+func (p RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return defaultRetryMaxDelay
+	}
+	return p.MaxDelay
+}
+
+// parseJitter validates a CF_RETRY_JITTER value, defaulting to full jitter.
+func parseJitter(value string) (string, error) {
+	switch value {
+	case "":
+		return jitterFull, nil
+	case jitterFull, jitterEqual, jitterNone, jitterDecorrelated:
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported %s %q (must be '%s', '%s', '%s' or '%s')",
+			envRetryJitter, value, jitterFull, jitterEqual, jitterNone, jitterDecorrelated)
+	}
+}
+
+// delay returns how long to wait before retry number attempt (starting at 0).
+// prev is the previous delay, used by decorrelated jitter. randN returns a
+// uniform value in [0, n).
+func (p RetryPolicy) delay(attempt int, prev time.Duration, randN func(int64) int64) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+
+	limit := p.maxDelay()
+	exp := base << attempt
+	if exp <= 0 || exp > limit {
+		exp = limit
+	}
+
+	var d time.Duration
+	switch p.Jitter {
+	case jitterNone:
+		d = exp
+	case jitterEqual:
+		d = exp/2 + time.Duration(randN(int64(exp/2)+1))
+	case jitterDecorrelated:
+		// AWS "decorrelated jitter": sleep = min(cap, random(base, prev*3)).
+		if prev < base {
+			prev = base
+		}
+		d = base + time.Duration(randN(int64(prev*3-base)+1))
+	default:
+		d = time.Duration(randN(int64(exp) + 1))
+	}
+
+	if d > limit {
+		d = limit
+	}
+	return d
+}
+
+// retryMiddleware retries requests that fail with a transport error or a
+// retryable status (408, 409, 429, 5xx). A Retry-After header from Cloudflare
+// takes precedence over the computed backoff, within policy.MaxDelay. With
+// policy.MaxElapsed set, the last response is returned as soon as the next
+// wait would overrun it, whatever attempts remain.
+func retryMiddleware(policy RetryPolicy, clock Clock) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if policy.Retries <= 0 {
+			return next
+		}
+
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var prev time.Duration
+			start := clock.Now()
+			for attempt := 0; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				if attempt >= policy.Retries || !shouldRetryResponse(resp, err) {
+					return resp, err
+				}
+				if req.Body != nil && req.GetBody == nil {
+					return resp, err
+				}
+
+				wait := policy.delay(attempt, prev, rand.Int64N)
+				if after, ok := retryAfter(resp); ok {
+					wait = min(after, policy.maxDelay())
+				}
+				prev = wait
+
+				if elapsed := clock.Now().Sub(start); policy.MaxElapsed > 0 && elapsed+wait > policy.MaxElapsed {
+					log.Printf("Cloudflare API request still failing after %s; giving up because %s is %s", elapsed.Round(time.Millisecond), envRetryMaxElapsed, policy.MaxElapsed)
+					return resp, err
+				}
+
+				if resp != nil {
+					resp.Body.Close()
+					log.Printf("Cloudflare API returned %d; retrying in %s (attempt %d/%d)", resp.StatusCode, wait, attempt+1, policy.Retries)
+				} else {
+					log.Printf("Cloudflare API request failed: %v; retrying in %s (attempt %d/%d)", err, wait, attempt+1, policy.Retries)
+				}
+
+				if err := sleepContext(req.Context(), clock, wait); err != nil {
+					return nil, err
+				}
+
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+			}
+		})
+	}
+}
+
+func shouldRetryResponse(resp *http.Response, err error) bool {
+	if errors.Is(err, errAPICallLimit) {
+		// The budget refuses every later attempt as well.
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusConflict,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= http.StatusInternalServerError:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header expressed in seconds.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// sleepContext waits for d on clock or until ctx is cancelled.
+func sleepContext(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	ticker := clock.NewTicker(d)
+	defer ticker.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ticker.C():
+		return nil
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	maxRand := func(n int64) int64 { return n - 1 }
+	zeroRand := func(int64) int64 { return 0 }
+
+	cases := []struct {
+		jitter  string
+		attempt int
+		prev    time.Duration
+		randN   func(int64) int64
+		want    time.Duration
+	}{
+		{jitter: jitterNone, attempt: 2, randN: zeroRand, want: 400 * time.Millisecond},
+		{jitter: jitterFull, attempt: 2, randN: zeroRand, want: 0},
+		{jitter: jitterFull, attempt: 2, randN: maxRand, want: 400 * time.Millisecond},
+		{jitter: jitterEqual, attempt: 2, randN: zeroRand, want: 200 * time.Millisecond},
+		{jitter: jitterEqual, attempt: 2, randN: maxRand, want: 400 * time.Millisecond},
+		{jitter: jitterDecorrelated, attempt: 0, prev: 0, randN: zeroRand, want: 100 * time.Millisecond},
+		{jitter: jitterDecorrelated, attempt: 3, prev: time.Second, randN: maxRand, want: 3 * time.Second},
+		{jitter: jitterNone, attempt: 20, randN: zeroRand, want: defaultRetryMaxDelay},
+	}
+
+	for _, tc := range cases {
+		policy := RetryPolicy{Retries: 3, BaseDelay: 100 * time.Millisecond, Jitter: tc.jitter}
+		if got := policy.delay(tc.attempt, tc.prev, tc.randN); got != tc.want {
+			t.Fatalf("%s attempt %d: expected %s, got %s", tc.jitter, tc.attempt, tc.want, got)
+		}
+	}
+}
+
+func TestRetryPolicyMaxDelay(t *testing.T) {
+	zeroRand := func(int64) int64 { return 0 }
+	policy := RetryPolicy{Retries: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond, Jitter: jitterNone}
+	if got := policy.delay(1, 0, zeroRand); got != 200*time.Millisecond {
+		t.Fatalf("expected 200ms below the cap, got %s", got)
+	}
+	if got := policy.delay(4, 0, zeroRand); got != 250*time.Millisecond {
+		t.Fatalf("expected the delay to be capped at 250ms, got %s", got)
+	}
+
+	policy.Jitter = jitterDecorrelated
+	if got := policy.delay(2, 5*time.Second, func(n int64) int64 { return n - 1 }); got != 250*time.Millisecond {
+		t.Fatalf("expected decorrelated jitter to be capped at 250ms, got %s", got)
+	}
+}
+
+func TestParseJitter(t *testing.T) {
+	if got, err := parseJitter(""); err != nil || got != jitterFull {
+		t.Fatalf("expected default full jitter, got %q %v", got, err)
+	}
+	if _, err := parseJitter("random"); err == nil {
+		t.Fatalf("expected error for unknown strategy")
+	}
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	var attempts int
+	var bodies []string
+
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		status := http.StatusServiceUnavailable
+		if attempts == 3 {
+			status = http.StatusOK
+		}
+		return &http.Response{StatusCode: status, Body: http.NoBody, Header: make(http.Header)}, nil
+	})
+
+	policy := RetryPolicy{Retries: 2, BaseDelay: time.Millisecond, Jitter: jitterNone}
+	rt := Chain(base, retryMiddleware(policy, realClock{}))
+
+	req, err := http.NewRequest(http.MethodPut, "https://api.cloudflare.com/", bytes.NewReader([]byte("payload")))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || attempts != 3 {
+		t.Fatalf("expected success on third attempt, got %d after %d attempts", resp.StatusCode, attempts)
+	}
+	for i, body := range bodies {
+		if body != "payload" {
+			t.Fatalf("attempt %d sent body %q", i+1, body)
+		}
+	}
+}
+
+func TestRetryMiddlewareSkipsClientErrors(t *testing.T) {
+	var attempts int
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return &http.Response{StatusCode: http.StatusForbidden, Body: http.NoBody, Header: make(http.Header)}, nil
+	})
+
+	rt := Chain(base, retryMiddleware(RetryPolicy{Retries: 3, BaseDelay: time.Millisecond}, realClock{}))
+	req, err := http.NewRequest(http.MethodGet, "https://api.cloudflare.com/", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected no retries for 403, got %d attempts", attempts)
+	}
+}
+
+func TestRetryMiddlewareCapsRetryAfter(t *testing.T) {
+	var attempts int
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		header := make(http.Header)
+		header.Set("Retry-After", "60")
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Body: http.NoBody, Header: header}, nil
+	})
+
+	policy := RetryPolicy{Retries: 1, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Jitter: jitterNone}
+	rt := Chain(base, retryMiddleware(policy, realClock{}))
+	req, err := http.NewRequest(http.MethodGet, "https://api.cloudflare.com/", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 2 || time.Since(start) > 5*time.Second {
+		t.Fatalf("expected one retry after a capped wait, got %d attempts in %s", attempts, time.Since(start))
+	}
+}
+
+func TestRetryMiddlewareMaxElapsed(t *testing.T) {
+	var attempts int
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody, Header: make(http.Header)}, nil
+	})
+
+	// Waits of 20ms then 40ms: the second would end past the 50ms budget.
+	policy := RetryPolicy{Retries: 5, BaseDelay: 20 * time.Millisecond, MaxElapsed: 50 * time.Millisecond, Jitter: jitterNone}
+	rt := Chain(base, retryMiddleware(policy, realClock{}))
+	req, err := http.NewRequest(http.MethodGet, "https://api.cloudflare.com/", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last response to be returned, got %v, %v", resp, err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected the elapsed budget to stop retrying after 2 attempts, got %d", attempts)
+	}
+}
+
+func TestLoadConfigRetryBounds(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "home.example.com")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Retry.MaxDelay != defaultRetryMaxDelay || cfg.Retry.MaxElapsed != 0 {
+		t.Fatalf("expected default bounds, got %+v", cfg.Retry)
+	}
+
+	t.Setenv(envRetryMaxDelay, "5s")
+	t.Setenv(envRetryMaxElapsed, "12s")
+	if cfg, err = loadConfig(); err != nil || cfg.Retry.MaxDelay != 5*time.Second || cfg.Retry.MaxElapsed != 12*time.Second {
+		t.Fatalf("unexpected retry policy %+v, %v", cfg.Retry, err)
+	}
+
+	for name, value := range map[string]string{envRetryMaxDelay: "100ms", envRetryMaxElapsed: "-1s"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), name) {
+				t.Fatalf("expected %s=%s to be rejected, got %v", name, value, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+)
+
+// newRunID returns a short random ID telling one update cycle's log lines,
+// notifications and reports apart from another's.
+func newRunID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// runIDPrefix is the log prefix marking lines written during run id.
+func runIDPrefix(id string) string {
+	return "[" + id + "] "
+}
+
+// startRun sets the log prefix to id until the returned function restores
+// the previous one. With log.Lmsgprefix, set by main, the prefix follows the
+// timestamp so CF_HISTORY_RETENTION still finds it at the start of each line.
+func startRun(id string) func() {
+	prev := log.Prefix()
+	log.SetPrefix(runIDPrefix(id))
+	return func() { log.SetPrefix(prev) }
+}
+
+// outsideRunLogger returns a logger writing where the standard logger does,
+// but without the run ID prefix, for goroutines such as the health and push
+// servers whose lines are not part of the cycle running meanwhile.
+func outsideRunLogger() *log.Logger {
+	return log.New(log.Writer(), "", log.Flags())
+}
+
+// runError is the error a cycle failed with, carrying the cycle's ID so it
+// can be reported with it after the log prefix is restored.
+type runError struct {
+	runID string
+	err   error
+}
+
+func (e *runError) Error() string {
+	return e.err.Error()
+}
+
+func (e *runError) Unwrap() error {
+	return e.err
+}
+
+// errorRunID returns the ID of the cycle err came from, or "" when it did
+// not come from one.
+func errorRunID(err error) string {
+	var runErr *runError
+	if errors.As(err, &runErr) {
+		return runErr.runID
+	}
+	return ""
+}
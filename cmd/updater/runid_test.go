@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCycleLogsRunID(t *testing.T) {
+	var buf bytes.Buffer
+	prevOut, prevFlags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+	t.Cleanup(func() {
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+	})
+
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}}, api, "203.0.113.10")
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}$`).MatchString(u.runID) {
+		t.Fatalf("unexpected run ID %q", u.runID)
+	}
+
+	line := regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} \[` + u.runID + `\] `)
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !line.MatchString(l) {
+			t.Fatalf("expected the run ID after the timestamp, got %q", l)
+		}
+	}
+	if log.Prefix() != "" {
+		t.Fatalf("expected the prefix restored after the cycle, got %q", log.Prefix())
+	}
+
+	first := u.runID
+	u.cycle(context.Background())
+	if u.runID == first {
+		t.Fatalf("expected a new run ID per cycle")
+	}
+}
+
+func TestCycleErrorCarriesRunID(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	api.readOnly = true
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}}, api, "203.0.113.10")
+
+	err := u.cycle(context.Background())
+	if errorRunID(err) != u.runID || exitStatus(err) != 1 {
+		t.Fatalf("expected the error of run %s, got %v", u.runID, err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeJSONError(&buf, err); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var got jsonError
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || got.RunID != u.runID || got.Type != errorTypeAuth {
+		t.Fatalf("expected the run ID in the JSON error, got %+v, %v", got, err)
+	}
+}
+
+func TestOutsideRunLoggerHasNoPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	prevOut, prevFlags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+	})
+
+	restore := startRun("0123abcd")
+	log.Print("in the cycle")
+	outsideRunLogger().Print("from the health server")
+	restore()
+
+	if want := "[0123abcd] in the cycle\nfrom the health server\n"; buf.String() != want {
+		t.Fatalf("expected only the cycle's line to be prefixed, got %q", buf.String())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	modeOnce  = "once"
+	modeWatch = "watch"
+)
+
+// resolveMode validates the -mode flag. When the flag is omitted the updater
+// runs once unless an interval is configured, preserving the behavior of
+// cron-driven deployments.
+func resolveMode(flagValue string, interval time.Duration) (string, error) {
+	switch flagValue {
+	case "":
+		if interval > 0 {
+			return modeWatch, nil
+		}
+		return modeOnce, nil
+	case modeOnce:
+		return modeOnce, nil
+	case modeWatch:
+		if interval <= 0 {
+			return "", fmt.Errorf("-mode watch requires %s", envInterval)
+		}
+		return modeWatch, nil
+	default:
+		return "", fmt.Errorf("unsupported -mode %q (must be '%s' or '%s')", flagValue, modeOnce, modeWatch)
+	}
+}
+
+// watch calls run once per interval until ctx is cancelled, starting
+// immediately when runOnStart is set and after the first interval otherwise.
+// A value on trigger, which may be nil, runs it right away. Failed runs are
+// logged and retried on the next tick.
+func watch(ctx context.Context, clock Clock, interval time.Duration, runOnStart bool, trigger <-chan struct{}, run func(context.Context) error) {
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	if !runOnStart {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		case <-trigger:
+		}
+	}
+
+	for {
+		if err := run(ctx); err != nil {
+			log.Printf("run failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		case <-trigger:
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolveMode(t *testing.T) {
+	cases := []struct {
+		flag     string
+		interval time.Duration
+		want     string
+		wantErr  bool
+	}{
+		{flag: "", interval: 0, want: modeOnce},
+		{flag: "", interval: time.Minute, want: modeWatch},
+		{flag: "once", interval: time.Minute, want: modeOnce},
+		{flag: "watch", interval: time.Minute, want: modeWatch},
+		{flag: "watch", interval: 0, wantErr: true},
+		{flag: "forever", interval: time.Minute, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := resolveMode(tc.flag, tc.interval)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("resolveMode(%q, %s): expected error", tc.flag, tc.interval)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("resolveMode(%q, %s) = %q, %v; want %q", tc.flag, tc.interval, got, err, tc.want)
+		}
+	}
+}
+
+func TestWatchRunsOnEachTick(t *testing.T) {
+	clk := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watch(ctx, clk, time.Minute, true, nil, func(context.Context) error {
+			runs <- struct{}{}
+			return errors.New("failures do not stop the loop")
+		})
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("run %d did not happen", i+1)
+		}
+		clk.Advance(time.Minute)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-runs:
+		// A tick may have been delivered before cancellation was observed.
+		<-done
+	case <-time.After(time.Second):
+		t.Fatalf("watch did not stop after cancellation")
+	}
+}
+
+func TestWatchWaitsForFirstIntervalWithoutRunOnStart(t *testing.T) {
+	clk := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := make(chan struct{}, 1)
+	go watch(ctx, clk, time.Minute, false, nil, func(context.Context) error {
+		runs <- struct{}{}
+		return nil
+	})
+
+	select {
+	case <-runs:
+		t.Fatalf("expected no run before the first interval")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clk.Advance(time.Minute)
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatalf("expected a run after the first interval")
+	}
+}
+
+func TestWatchRunsOnTrigger(t *testing.T) {
+	clk := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := make(chan struct{}, 1)
+	trigger := make(chan struct{})
+	go watch(ctx, clk, time.Hour, false, trigger, func(context.Context) error {
+		runs <- struct{}{}
+		return nil
+	})
+
+	trigger <- struct{}{}
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatalf("expected a run after the trigger")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// ServiceStats counts the outcomes of queries to one IP service across runs.
+type ServiceStats struct {
+	Successes   int       `json:"successes"`
+	Failures    int       `json:"failures"`
+	LastSuccess time.Time `json:"last_success"`
+	LastFailure time.Time `json:"last_failure"`
+}
+
+// SuccessRate returns the fraction of successful queries, and false when the
+// service has never been queried.
+func (s ServiceStats) SuccessRate() (float64, bool) {
+	total := s.Successes + s.Failures
+	if total == 0 {
+		return 0, false
+	}
+	return float64(s.Successes) / float64(total), true
+}
+
+// queryObserver is told the final outcome of every IP service query. A nil
+// observer ignores them.
+type queryObserver func(svc string, err error)
+
+func (o queryObserver) record(svc string, err error) {
+	if o != nil {
+		o(svc, err)
+	}
+}
+
+// recordServiceResult adds the outcome of one query to svc at now.
+func (st *State) recordServiceResult(svc string, err error, now time.Time) {
+	if st.Services == nil {
+		st.Services = make(map[string]ServiceStats)
+	}
+	stats := st.Services[svc]
+	if err != nil {
+		stats.Failures++
+		stats.LastFailure = now
+	} else {
+		stats.Successes++
+		stats.LastSuccess = now
+	}
+	st.Services[svc] = stats
+}
+
+// printServiceStats implements the service-stats subcommand: it reports the
+// recorded reliability of every configured IP service.
+func printServiceStats(w io.Writer, env *envReader) error {
+	path := env.get(envStateFile)
+	servicesValue := env.get(envIPServices)
+	if env.err != nil {
+		return env.err
+	}
+	if path == "" {
+		return fmt.Errorf("%s is required", envStateFile)
+	}
+
+	services, err := parseIPServices(servicesValue)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		services = defaultIPServices
+	}
+
+	state, err := loadState(path)
+	if err != nil {
+		return fmt.Errorf("failed to load state file: %w", err)
+	}
+	return writeServiceStats(w, services, state.Services)
+}
+
+// writeServiceStats prints one row per service in services, in order.
+func writeServiceStats(w io.Writer, services []string, stats map[string]ServiceStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tSUCCESSES\tFAILURES\tSUCCESS RATE\tLAST SEEN")
+	for _, svc := range services {
+		s := stats[svc]
+
+		rate := "-"
+		if r, ok := s.SuccessRate(); ok {
+			rate = fmt.Sprintf("%.1f%%", r*100)
+		}
+		lastSeen := "never"
+		if !s.LastSuccess.IsZero() {
+			lastSeen = s.LastSuccess.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", svc, s.Successes, s.Failures, rate, lastSeen)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoverIPRecordsServiceStats(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.10"))
+	}))
+	t.Cleanup(working.Close)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var st State
+	observe := func(svc string, err error) { st.recordServiceResult(svc, err, now) }
+
+	services := []string{failing.URL, working.URL}
+	for i := 0; i < 2; i++ {
+		if _, _, err := discoverIP(context.Background(), &http.Client{}, services, familyIPv4, 0, observe); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := st.Services[failing.URL]; got.Successes != 0 || got.Failures != 2 || !got.LastFailure.Equal(now) {
+		t.Fatalf("unexpected stats for failing service %+v", got)
+	}
+	if got := st.Services[working.URL]; got.Successes != 2 || got.Failures != 0 || !got.LastSuccess.Equal(now) {
+		t.Fatalf("unexpected stats for working service %+v", got)
+	}
+}
+
+func TestPrintServiceStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	last := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	st := State{Services: map[string]ServiceStats{
+		"https://one": {Successes: 3, Failures: 1, LastSuccess: last},
+	}}
+	if err := saveState(path, st); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	t.Setenv(envStateFile, path)
+	t.Setenv(envIPServices, "https://one,https://two")
+
+	var out bytes.Buffer
+	if err := printServiceStats(&out, &envReader{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and two rows, got %q", out.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "https://one 3 1 75.0% 2024-05-01T12:00:00Z" {
+		t.Fatalf("unexpected row %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "https://two 0 0 - never" {
+		t.Fatalf("unexpected row %q", lines[2])
+	}
+}
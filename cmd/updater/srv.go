@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+// SRVData holds the components of an SRV record managed by the updater.
+type SRVData struct {
+	Priority int
+	Weight   int
+	Port     int
+	Target   string
+}
+
+// String formats the data in zone-file order so it can be logged and compared.
+func (d SRVData) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.Priority, d.Weight, d.Port, d.Target)
+}
+
+// parseSRVData fills in the numeric SRV fields and validates the target.
+// Priority and weight default to 0; port and target are required.
+func parseSRVData(data *SRVData, priorityValue, weightValue, portValue string) error {
+	var err error
+	if data.Priority, err = parseSRVField(envSRVPriority, priorityValue, 0); err != nil {
+		return err
+	}
+	if data.Weight, err = parseSRVField(envSRVWeight, weightValue, 0); err != nil {
+		return err
+	}
+
+	if portValue == "" {
+		return fmt.Errorf("%s is required for SRV records", envSRVPort)
+	}
+	if data.Port, err = parseSRVField(envSRVPort, portValue, 1); err != nil {
+		return err
+	}
+
+	data.Target = normalizeSRVTarget(data.Target)
+	if data.Target == "" {
+		return fmt.Errorf("%s is required for SRV records", envSRVTarget)
+	}
+
+	return nil
+}
+
+func parseSRVField(name, value string, min int) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > 65535 {
+		return 0, fmt.Errorf("invalid %s value %q", name, value)
+	}
+
+	return n, nil
+}
+
+func normalizeSRVTarget(target string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(target), "."))
+}
+
+func extractSRVData(record dns.Record) (SRVData, error) {
+	srvRecord, ok := record.AsUnion().(dns.SRVRecord)
+	if !ok {
+		return SRVData{}, fmt.Errorf("record type %q is not supported", record.Type)
+	}
+
+	return SRVData{
+		Priority: int(srvRecord.Data.Priority),
+		Weight:   int(srvRecord.Data.Weight),
+		Port:     int(srvRecord.Data.Port),
+		Target:   normalizeSRVTarget(srvRecord.Data.Target),
+	}, nil
+}
+
+func srvRecordParam(cfg Config) dns.SRVRecordParam {
+	return dns.SRVRecordParam{
+		Name: cloudflare.String(cfg.RecordName),
+		Type: cloudflare.F(dns.SRVRecordTypeSRV),
+		TTL:  cloudflare.F(dns.TTL(float64(cfg.TTL))),
+		Data: cloudflare.F(dns.SRVRecordDataParam{
+			Priority: cloudflare.F(float64(cfg.SRV.Priority)),
+			Weight:   cloudflare.F(float64(cfg.SRV.Weight)),
+			Port:     cloudflare.F(float64(cfg.SRV.Port)),
+			Target:   cloudflare.String(cfg.SRV.Target),
+		}),
+	}
+}
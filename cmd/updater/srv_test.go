@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+func TestLoadConfigSRV(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "_minecraft._tcp.example.com")
+	t.Setenv(envRecordType, "srv")
+	t.Setenv(envSRVPriority, "10")
+	t.Setenv(envSRVWeight, "5")
+	t.Setenv(envSRVPort, "25565")
+	t.Setenv(envSRVTarget, "Game.Example.com.")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := SRVData{Priority: 10, Weight: 5, Port: 25565, Target: "game.example.com"}
+	if cfg.SRV != expected {
+		t.Fatalf("unexpected SRV data %+v", cfg.SRV)
+	}
+}
+
+func TestLoadConfigSRVRequiresPortAndTarget(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "_minecraft._tcp.example.com")
+	t.Setenv(envRecordType, "SRV")
+	t.Setenv(envSRVTarget, "game.example.com")
+
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected error when port missing")
+	}
+
+	t.Setenv(envSRVPort, "70000")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected error when port out of range")
+	}
+
+	t.Setenv(envSRVPort, "25565")
+	t.Setenv(envSRVTarget, "")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected error when target missing")
+	}
+}
+
+func TestExtractSRVData(t *testing.T) {
+	var record dns.Record
+	payload := []byte(`{"id":"record-id","type":"SRV","name":"_minecraft._tcp.example.com","content":"5 25565 game.example.com","data":{"priority":10,"weight":5,"port":25565,"target":"game.example.com"},"tags":[],"ttl":300}`)
+	if err := json.Unmarshal(payload, &record); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+
+	content, err := extractRecordContent(record)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := SRVData{Priority: 10, Weight: 5, Port: 25565, Target: "game.example.com"}
+	if content != expected.String() {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
+
+func TestUpdateDNSRecordSRV(t *testing.T) {
+	var receivedBody []byte
+
+	httpClient := &http.Client{
+		Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var err error
+			receivedBody, err = io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("read body err: %v", err)
+			}
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader([]byte(`{"success":true,"errors":[],"messages":[],"result":{"id":"record-id"}}`))),
+				Header:     make(http.Header),
+			}
+			resp.Header.Set("Content-Type", "application/json")
+			return resp, nil
+		}),
+	}
+
+	cfg := Config{
+		AuthMethod: "token",
+		AuthKey:    "token-value",
+		ZoneID:     "zone-id",
+		RecordName: "_minecraft._tcp.example.com",
+		RecordType: "SRV",
+		TTL:        300,
+		SRV:        SRVData{Priority: 10, Weight: 5, Port: 25565, Target: "game.example.com"},
+	}
+
+	client, err := newCloudflareClient(httpClient, cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	if err := (cloudflareProvider{client: client}).Update(context.Background(), cfg, "record-id", cfg.SRV.String()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	var payload struct {
+		Type string         `json:"type"`
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(receivedBody, &payload); err != nil {
+		t.Fatalf("json unmarshal err: %v", err)
+	}
+	if payload.Type != "SRV" {
+		t.Fatalf("unexpected type %q", payload.Type)
+	}
+	if payload.Data["port"] != float64(25565) || payload.Data["target"] != "game.example.com" {
+		t.Fatalf("unexpected data %v", payload.Data)
+	}
+	if payload.Data["priority"] != float64(10) || payload.Data["weight"] != float64(5) {
+		t.Fatalf("unexpected data %v", payload.Data)
+	}
+}
+
+func TestUpdaterSRVSkipsIPDiscovery(t *testing.T) {
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected IP service request for SRV-only configuration")
+	}))
+	t.Cleanup(ipServer.Close)
+
+	api := newMockCloudflare()
+	api.seed = true
+	cfg := Config{
+		RecordNames: []string{"_minecraft._tcp.example.com"},
+		RecordType:  "SRV",
+		SRV:         SRVData{Priority: 10, Weight: 5, Port: 25565, Target: "game.example.com"},
+	}
+	u := newTestUpdater(t, cfg, api, "")
+	u.cfg.IPServices = []string{ipServer.URL}
+
+	if needsAddress(cfg) {
+		t.Fatalf("expected SRV records not to need an address")
+	}
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State is the information persisted between runs in CF_STATE_FILE.
+type State struct {
+	// LastService is the IP service that answered most recently.
+	LastService string `json:"last_service,omitempty"`
+	// FailureStreak counts consecutive failed runs for notifications.
+	FailureStreak int `json:"failure_streak,omitempty"`
+	// LastIPs holds the most recently discovered address per family, used
+	// to recognise moves to a new network.
+	LastIPs map[string]string `json:"last_ips,omitempty"`
+	// IPSince holds when each address in LastIPs was first seen, and
+	// StuckAlerted the addresses already reported as possibly stuck, per
+	// family.
+	IPSince      map[string]time.Time `json:"ip_since,omitempty"`
+	StuckAlerted map[string]string    `json:"stuck_alerted,omitempty"`
+	// Services holds the reliability of each IP service, keyed by URL.
+	Services map[string]ServiceStats `json:"services,omitempty"`
+	// Records holds what was last seen of each managed record, keyed by
+	// name and type.
+	Records map[string]RecordState `json:"records,omitempty"`
+	// APIErrorStreak counts Cloudflare API calls in a row that failed with
+	// a server error, and CooldownUntil is when runs resume after
+	// CF_OUTAGE_COOLDOWN.
+	APIErrorStreak int       `json:"api_error_streak,omitempty"`
+	CooldownUntil  time.Time `json:"cooldown_until,omitzero"`
+}
+
+// loadState reads the state file at path. A missing file yields an empty
+// State so the first run behaves like a stateless one, and so does a corrupt
+// one, after a warning: the state only holds hints that are rebuilt over time.
+func loadState(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		log.Printf("warning: ignoring corrupt state file %s: %v", path, err)
+		return State{}, nil
+	}
+	return st, nil
+}
+
+// saveState writes st to path as JSON.
+func saveState(path string, st State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory that
+// is renamed over path, so a crash mid-write leaves either the old or the new
+// content behind, never a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// localFileVars lists the variables naming files the updater keeps between
+// runs. The reset subcommand removes every one that is configured, along with
+// the rotated copies of CF_LOG_FILE.
+var localFileVars = []string{envStateFile, envReportFile, envLogFile}
+
+// resetLocalFiles deletes the configured local files, ignoring ones that do
+// not exist, and returns the paths actually removed.
+func resetLocalFiles(env *envReader) ([]string, error) {
+	var removed []string
+	for _, name := range localFileVars {
+		path := env.get(name)
+		if env.err != nil {
+			return removed, env.err
+		}
+		if path == "" {
+			continue
+		}
+
+		paths := []string{path}
+		if name == envLogFile {
+			rotated, err := rotatedLogFiles(path)
+			if err != nil {
+				return removed, err
+			}
+			paths = append(paths, rotated...)
+		}
+		for _, path := range paths {
+			err := os.Remove(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
+
+// preferService moves last to the front of services when it is present,
+// keeping the relative order of the remaining entries.
+func preferService(services []string, last string) []string {
+	ordered := make([]string, 0, len(services))
+	for _, svc := range services {
+		if svc == last {
+			ordered = append(ordered, svc)
+		}
+	}
+	if len(ordered) == 0 {
+		return services
+	}
+	for _, svc := range services {
+		if svc != last {
+			ordered = append(ordered, svc)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	st, err := loadState(path)
+	if err != nil {
+		t.Fatalf("expected missing state file to be ignored, got %v", err)
+	}
+	if !reflect.DeepEqual(st, State{}) {
+		t.Fatalf("expected empty state, got %+v", st)
+	}
+
+	if err := saveState(path, State{LastService: "https://service.two"}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	st, err = loadState(path)
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+	if st.LastService != "https://service.two" {
+		t.Fatalf("unexpected last service %q", st.LastService)
+	}
+}
+
+func TestLoadStateIgnoresCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	// A write cut short by a crash leaves truncated JSON behind.
+	if err := os.WriteFile(path, []byte(`{"last_service": "https://serv`), 0o600); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	st, err := loadState(path)
+	if err != nil {
+		t.Fatalf("expected corrupt state file to be ignored, got %v", err)
+	}
+	if !reflect.DeepEqual(st, State{}) {
+		t.Fatalf("expected empty state, got %+v", st)
+	}
+
+	if err := saveState(path, State{LastService: "https://service.one"}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	if st, err = loadState(path); err != nil || st.LastService != "https://service.one" {
+		t.Fatalf("expected state to be rewritten, got %+v, %v", st, err)
+	}
+}
+
+func TestSaveStateLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	for i := 0; i < 2; i++ {
+		if err := saveState(path, State{FailureStreak: i}); err != nil {
+			t.Fatalf("save state: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "state.json" {
+		t.Fatalf("expected only the state file, got %v", entries)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected state file mode 0600, got %v, %v", info, err)
+	}
+}
+
+func TestPreferService(t *testing.T) {
+	services := []string{"https://one", "https://two", "https://three"}
+
+	got := preferService(services, "https://three")
+	expected := []string{"https://three", "https://one", "https://two"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected order %v", got)
+	}
+
+	if got := preferService(services, "https://unknown"); !reflect.DeepEqual(got, services) {
+		t.Fatalf("expected unchanged order for unknown service, got %v", got)
+	}
+}
+
+func TestResetLocalFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := saveState(path, State{LastService: "https://service.one"}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	t.Setenv(envStateFile, path)
+
+	removed, err := resetLocalFiles(&envReader{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{path}) {
+		t.Fatalf("expected %s to be removed, got %v", path, removed)
+	}
+
+	removed, err = resetLocalFiles(&envReader{})
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("expected reset to be a no-op once files are gone, got %v %v", removed, err)
+	}
+}
+
+func TestResetLocalFilesRemovesLogs(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "updater.log")
+	for _, name := range []string{"updater.log", "updater.log.1", "updater.log.2", "updater.log.old", "other.log.1"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("line\n"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	t.Setenv(envLogFile, logPath)
+
+	removed, err := resetLocalFiles(&envReader{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{logPath, logPath + ".1", logPath + ".2"}
+	if !reflect.DeepEqual(removed, want) {
+		t.Fatalf("expected %v to be removed, got %v", want, removed)
+	}
+	for _, name := range []string{"updater.log.old", "other.log.1"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s to be kept: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// errWarnings is returned by a CF_STRICT run that logged warnings.
+var errWarnings = errors.New("warnings logged")
+
+// warningCounter passes log output through to out and counts the entries
+// that are warnings, in any case, so CF_STRICT can fail a run that otherwise
+// succeeded.
+type warningCounter struct {
+	mu    sync.Mutex
+	out   io.Writer
+	count int
+}
+
+func (w *warningCounter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if bytes.Contains(bytes.ToLower(p), []byte("warning: ")) {
+		w.count++
+	}
+	return w.out.Write(p)
+}
+
+// setOutput redirects the counted output, for when the log destination is
+// only known after the first warnings may have been logged.
+func (w *warningCounter) setOutput(out io.Writer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.out = out
+}
+
+// err returns an error wrapping errWarnings when any warning was logged.
+func (w *warningCounter) err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count == 0 {
+		return nil
+	}
+	noun := "warnings"
+	if w.count == 1 {
+		noun = "warning"
+	}
+	return fmt.Errorf("%w: %d %s with %s set", errWarnings, w.count, noun, envStrict)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestWarningCounter(t *testing.T) {
+	var out bytes.Buffer
+	counter := &warningCounter{out: &out}
+	logger := log.New(counter, "[abcd1234] ", log.LstdFlags|log.Lmsgprefix)
+
+	logger.Printf("home.example.com is up to date")
+	if err := counter.err(); err != nil {
+		t.Fatalf("expected no error without warnings, got %v", err)
+	}
+
+	logger.Printf("warning: %s is not set", envAuthEmail)
+	logger.Printf("warning: private IP 192.168.1.10 allowed")
+	err := counter.err()
+	if !errors.Is(err, errWarnings) {
+		t.Fatalf("expected errWarnings, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "2 warnings") {
+		t.Fatalf("unexpected error %q", err)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 3 {
+		t.Fatalf("expected 3 lines passed through, got %d: %q", got, out.String())
+	}
+}
+
+func TestWarningCounterSetOutput(t *testing.T) {
+	var first, second bytes.Buffer
+	counter := &warningCounter{out: &first}
+	logger := log.New(counter, "", 0)
+
+	logger.Printf("warning: early")
+	counter.setOutput(&second)
+	logger.Printf("later")
+
+	if first.String() != "warning: early\n" || second.String() != "later\n" {
+		t.Fatalf("unexpected outputs %q and %q", first.String(), second.String())
+	}
+	if err := counter.err(); err == nil || !strings.Contains(err.Error(), "1 warning with") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestStrictCountsInsecureTLSWarning(t *testing.T) {
+	counter := &warningCounter{out: io.Discard}
+	prevOut := log.Writer()
+	log.SetOutput(counter)
+	t.Cleanup(func() { log.SetOutput(prevOut) })
+
+	if _, err := newUpdater(Config{AuthMethod: "token", AuthKey: "token-value", IPInsecureTLS: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := counter.err(); !errors.Is(err, errWarnings) {
+		t.Fatalf("expected %s to count as a warning, got %v", envIPInsecureTLS, err)
+	}
+
+	counter = &warningCounter{out: io.Discard}
+	log.New(counter, "", 0).Printf("WARNING: shouting")
+	if err := counter.err(); err == nil {
+		t.Fatalf("expected an upper-case warning to be counted")
+	}
+}
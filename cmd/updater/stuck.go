@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// checkStuck records when ip was first seen for family and, once it has not
+// changed for cfg.StuckAfter, reports it for the stuck notification. Each
+// address is reported once. It returns whether state changed.
+func (u *updater) checkStuck(cfg Config, state *State, family, prev, ip string) bool {
+	now := u.clock.Now()
+	since, ok := state.IPSince[family]
+	if prev != ip || !ok {
+		if state.IPSince == nil {
+			state.IPSince = make(map[string]time.Time)
+		}
+		state.IPSince[family] = now
+		delete(state.StuckAlerted, family)
+		return true
+	}
+
+	age := now.Sub(since)
+	if cfg.StuckAfter <= 0 || age < cfg.StuckAfter || state.StuckAlerted[family] == ip {
+		return false
+	}
+
+	alert := fmt.Sprintf("%s address %s has not changed for %s (%s=%s); the link may be stuck", family, ip, formatAge(age), envStuckAfter, cfg.StuckAfter)
+	log.Printf("warning: %s", alert)
+	u.stuckAlerts = append(u.stuckAlerts, alert)
+	if state.StuckAlerted == nil {
+		state.StuckAlerted = make(map[string]string)
+	}
+	state.StuckAlerted[family] = ip
+	return true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/derek/cloudflare-ddns-cron/notify"
+)
+
+func TestUpdaterNotifiesStuckAddress(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{
+		RecordNames: []string{"example.com"},
+		StateFile:   statePath,
+		NotifyOn:    map[string]bool{notifyFailure: true},
+		StuckAfter:  48 * time.Hour,
+	}
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	clk := u.clock.(*fakeClock)
+	rec := &recordingNotifier{name: "recording"}
+	u.notifiers = &notify.Registry{}
+	u.notifiers.Register(rec.name, rec)
+
+	cycle := func() {
+		t.Helper()
+		if err := u.cycle(context.Background()); err != nil {
+			t.Fatalf("expected success, got %v", err)
+		}
+	}
+
+	cycle()
+	clk.Advance(24 * time.Hour)
+	cycle()
+	if len(rec.got) != 0 {
+		t.Fatalf("expected no notification before %s, got %+v", envStuckAfter, rec.got)
+	}
+
+	clk.Advance(25 * time.Hour)
+	cycle()
+	if len(rec.got) != 1 || rec.got[0].Kind != notifyStuck {
+		t.Fatalf("expected one stuck notification, got %+v", rec.got)
+	}
+
+	// The same address is only reported once.
+	clk.Advance(24 * time.Hour)
+	cycle()
+	if len(rec.got) != 1 {
+		t.Fatalf("expected no repeated notification, got %+v", rec.got)
+	}
+
+	state, err := loadState(statePath)
+	if err != nil || state.StuckAlerted[familyIPv4] != "203.0.113.10" {
+		t.Fatalf("expected the alert to be recorded, got %+v, %v", state, err)
+	}
+}
+
+func TestCheckStuckResetsOnChange(t *testing.T) {
+	u := &updater{clock: newFakeClock(time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC))}
+	state := State{
+		LastIPs:      map[string]string{familyIPv4: "203.0.113.10"},
+		IPSince:      map[string]time.Time{familyIPv4: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		StuckAlerted: map[string]string{familyIPv4: "203.0.113.10"},
+	}
+	cfg := Config{StuckAfter: time.Hour}
+
+	if !u.checkStuck(cfg, &state, familyIPv4, "203.0.113.10", "203.0.113.20") {
+		t.Fatalf("expected a new address to change state")
+	}
+	if _, ok := state.StuckAlerted[familyIPv4]; ok || !state.IPSince[familyIPv4].Equal(u.clock.Now()) {
+		t.Fatalf("expected the stuck tracking to restart, got %+v", state)
+	}
+	if len(u.stuckAlerts) != 0 {
+		t.Fatalf("expected no alert for a new address, got %v", u.stuckAlerts)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+)
+
+// parseRecordTag validates a CF_RECORD_TAG value: a tag name, or a
+// name:value pair as Cloudflare stores tags.
+func parseRecordTag(value string) (string, error) {
+	tag := strings.TrimSpace(value)
+	name, _, _ := strings.Cut(tag, ":")
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(tag, ", ") {
+		return "", fmt.Errorf("invalid %s value %q (expected name or name:value)", envRecordTag, value)
+	}
+	return tag, nil
+}
+
+// tagFilter restricts a record listing to records carrying tag: an exact
+// match for name:value, or any value for a bare name.
+func tagFilter(tag string) dns.RecordListParamsTag {
+	if strings.Contains(tag, ":") {
+		return dns.RecordListParamsTag{Exact: cloudflare.String(tag)}
+	}
+	return dns.RecordListParamsTag{Present: cloudflare.String(tag)}
+}
+
+// recordTags returns the tags on record.
+func recordTags(record dns.Record) []string {
+	switch r := record.AsUnion().(type) {
+	case dns.ARecord:
+		return r.Tags
+	case dns.AAAARecord:
+		return r.Tags
+	case dns.SRVRecord:
+		return r.Tags
+	case dns.CAARecord:
+		return r.Tags
+	}
+	return nil
+}
+
+// hasTag reports whether tags include tag. Like Cloudflare's filters, the
+// comparison ignores case, and a bare name matches the tag with any value.
+func hasTag(tags []string, tag string) bool {
+	name, _, pair := strings.Cut(tag, ":")
+	for _, t := range tags {
+		if pair && strings.EqualFold(t, tag) {
+			return true
+		}
+		if tagName, _, _ := strings.Cut(t, ":"); !pair && strings.EqualFold(tagName, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// withTag returns tags with tag added, keeping the record's other tags. A
+// name:value tag replaces any other value of the same name.
+func withTag(tags []string, tag string) []string {
+	if hasTag(tags, tag) {
+		return tags
+	}
+	name, _, _ := strings.Cut(tag, ":")
+	merged := make([]string, 0, len(tags)+1)
+	for _, t := range tags {
+		if tagName, _, _ := strings.Cut(t, ":"); !strings.EqualFold(tagName, name) {
+			merged = append(merged, t)
+		}
+	}
+	return append(merged, tag)
+}
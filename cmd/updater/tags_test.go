@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRecordTag(t *testing.T) {
+	for value, want := range map[string]string{"ddns": "ddns", " ddns:home ": "ddns:home"} {
+		if got, err := parseRecordTag(value); err != nil || got != want {
+			t.Errorf("parseRecordTag(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{":home", "ddns,owner", "ddns home"} {
+		if _, err := parseRecordTag(value); err == nil || !strings.Contains(err.Error(), envRecordTag) {
+			t.Errorf("parseRecordTag(%q): expected an error naming %s, got %v", value, envRecordTag, err)
+		}
+	}
+}
+
+func TestWithTag(t *testing.T) {
+	cases := []struct {
+		tags []string
+		tag  string
+		want []string
+	}{
+		{nil, "ddns", []string{"ddns"}},
+		{[]string{"owner:ops"}, "ddns:home", []string{"owner:ops", "ddns:home"}},
+		{[]string{"DDNS:Home", "owner:ops"}, "ddns:home", []string{"DDNS:Home", "owner:ops"}},
+		{[]string{"ddns:office", "owner:ops"}, "ddns:home", []string{"owner:ops", "ddns:home"}},
+		{[]string{"ddns:office"}, "ddns", []string{"ddns:office"}},
+	}
+	for _, c := range cases {
+		if got := withTag(c.tags, c.tag); !slices.Equal(got, c.want) {
+			t.Errorf("withTag(%v, %q) = %v, want %v", c.tags, c.tag, got, c.want)
+		}
+	}
+}
+
+func TestUpdaterRecordTag(t *testing.T) {
+	tagged := aRecordFixture("id-1", "a.example.com", "198.51.100.1")
+	tagged["tags"] = []any{"owner:ops", "ddns:home"}
+	api := newMockCloudflare(tagged, aRecordFixture("id-2", "b.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"a.example.com", "b.example.com"}, RecordTag: "ddns:home"}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if results[0].Action != actionChanged || results[1].Action != actionChanged || api.updates != 2 {
+		t.Fatalf("unexpected results %+v after %d update(s)", results, api.updates)
+	}
+	if got := api.records["a.example.com"]["tags"]; !slices.Equal(got.([]any), []any{"owner:ops", "ddns:home"}) {
+		t.Fatalf("expected the record's tags to be kept, got %v", got)
+	}
+	if got := api.records["b.example.com"]["tags"]; !slices.Equal(got.([]any), []any{"ddns:home"}) {
+		t.Fatalf("expected the untagged record to be tagged, got %v", got)
+	}
+}
+
+func TestUpdaterRecordTagAddsMissingTag(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "a.example.com", "203.0.113.10"))
+	cfg := Config{RecordNames: []string{"a.example.com"}, RecordMode: recordModeSync, RecordTag: "ddns"}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if results[0].Action != actionChanged || api.updates != 1 || api.creates != 0 {
+		t.Fatalf("expected the existing record to be tagged, not duplicated, got %+v after %d update(s) and %d creation(s)", results[0], api.updates, api.creates)
+	}
+	if got := results[0].Changes; !slices.ContainsFunc(got, func(c fieldChange) bool { return c.Field == "tags" && c.Changed() }) {
+		t.Fatalf("expected a tags change, got %+v", got)
+	}
+	if got := api.records["a.example.com"]["tags"]; !slices.Equal(got.([]any), []any{"ddns"}) {
+		t.Fatalf("expected the record to carry the tag, got %v", got)
+	}
+
+	// Once tagged, the record is up to date.
+	if results, err = u.run(context.Background()); err != nil || results[0].Action != actionUnchanged {
+		t.Fatalf("expected the tagged record to be left alone, got %+v, %v", results, err)
+	}
+}
+
+func TestUpdaterRecordTagOnCreate(t *testing.T) {
+	api := newMockCloudflare()
+	cfg := Config{RecordNames: []string{"a.example.com"}, RecordMode: recordModeSync, RecordTag: "ddns"}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if got := api.records["a.example.com"]["tags"]; !slices.Equal(got.([]any), []any{"ddns"}) {
+		t.Fatalf("expected the created record to carry the tag, got %v", got)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+)
+
+// Target is an additional set of records written with its own zone and
+// credentials, configured through CF_TARGETS_FILE. Discovery and every other
+// setting are shared with the main configuration.
+type Target struct {
+	Name       string         `json:"name"`
+	ZoneID     string         `json:"zone_id"`
+	AuthMethod string         `json:"auth_method"`
+	AuthKey    string         `json:"auth_key"`
+	AuthEmail  string         `json:"auth_email"`
+	Records    []TargetRecord `json:"record_names"`
+
+	// RecordNames and Disabled split Records into the names that are
+	// managed and those switched off with "enabled": false.
+	RecordNames []string `json:"-"`
+	Disabled    []string `json:"-"`
+}
+
+// TargetRecord is an entry of record_names: either a bare name or an object
+// such as {"name": "home.example.com", "enabled": false}. Records are enabled
+// unless the object says otherwise.
+type TargetRecord struct {
+	Name    string `json:"name"`
+	Enabled *bool  `json:"enabled"`
+}
+
+func (r *TargetRecord) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		return json.Unmarshal(data, &r.Name)
+	}
+	// The alias drops this method so the object decodes normally.
+	type record TargetRecord
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*record)(r))
+}
+
+func (r TargetRecord) enabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// loadTargets reads and validates the targets file at path. Each target is
+// checked on its own so errors name the offending entry.
+func loadTargets(path string) ([]Target, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", envTargetsFile, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var targets []Target
+	if err := dec.Decode(&targets); err != nil {
+		return nil, fmt.Errorf("parse %s %s: %w", envTargetsFile, path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range targets {
+		t := &targets[i]
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("duplicate target name %q in %s", t.Name, envTargetsFile)
+		}
+		seen[t.Name] = true
+
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("target %q: %w", t.Name, err)
+		}
+	}
+	return targets, nil
+}
+
+func (t *Target) validate() error {
+	t.AuthMethod = strings.ToLower(strings.TrimSpace(t.AuthMethod))
+	if t.AuthMethod == "" {
+		t.AuthMethod = authMethodAuto
+	}
+
+	if t.ZoneID == "" {
+		return fmt.Errorf("zone_id is required")
+	}
+	if t.AuthKey == "" {
+		return fmt.Errorf("auth_key is required")
+	}
+	if t.AuthMethod == authMethodAuto {
+		var reason string
+		t.AuthMethod, reason = inferAuthMethod(t.AuthKey, t.AuthEmail)
+		log.Printf("target %q: using %s authentication: %s", t.Name, t.AuthMethod, reason)
+	}
+	switch t.AuthMethod {
+	case "token":
+	case "global":
+		if t.AuthEmail == "" {
+			return fmt.Errorf("auth_email is required when auth_method is 'global'")
+		}
+	default:
+		return fmt.Errorf("unsupported auth_method %q (must be 'auto', 'token' or 'global')", t.AuthMethod)
+	}
+
+	var names, disabled []string
+	for _, r := range t.Records {
+		name := strings.TrimSpace(r.Name)
+		switch {
+		case name == "":
+		case r.enabled():
+			names = append(names, name)
+		default:
+			disabled = append(disabled, name)
+		}
+	}
+	if len(names) == 0 && len(disabled) == 0 {
+		return fmt.Errorf("record_names is required")
+	}
+	t.RecordNames = names
+	t.Disabled = disabled
+	return nil
+}
+
+// config returns base with the target's zone, credentials and records.
+func (t Target) config(base Config) Config {
+	cfg := base
+	cfg.ZoneID = t.ZoneID
+	cfg.AuthMethod = t.AuthMethod
+	cfg.AuthKey = t.AuthKey
+	cfg.AuthEmail = t.AuthEmail
+	cfg.RecordNames = t.RecordNames
+	cfg.DisabledNames = t.Disabled
+	cfg.RecordName = t.RecordNames[0]
+	// CF_ZONE_NAME describes the main zone; a target's is looked up.
+	cfg.ZoneName = ""
+	return cfg
+}
+
+// writeTarget is a set of records together with the client holding the
+// credentials allowed to change them.
+type writeTarget struct {
+	cfg    Config
+	client *cloudflare.Client
+}
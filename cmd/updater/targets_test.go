@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeTargetsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "targets.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write targets file: %v", err)
+	}
+	return path
+}
+
+func TestLoadTargets(t *testing.T) {
+	path := writeTargetsFile(t, `[
+		{"name": "partner", "zone_id": "zone-2", "auth_key": "scoped", "record_names": ["home.partner.example"]},
+		{"zone_id": "zone-3", "auth_method": "GLOBAL", "auth_key": "key", "auth_email": "ops@example.com", "record_names": [" a.example.net ", ""]}
+	]`)
+
+	targets, err := loadTargets(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected two targets, got %+v", targets)
+	}
+	if got := targets[0]; got.Name != "partner" || got.AuthMethod != "token" {
+		t.Fatalf("unexpected first target %+v", got)
+	}
+	if got := targets[1]; got.Name != "#2" || got.AuthMethod != "global" || len(got.RecordNames) != 1 || got.RecordNames[0] != "a.example.net" {
+		t.Fatalf("unexpected second target %+v", got)
+	}
+}
+
+func TestLoadTargetsValidatesEachTarget(t *testing.T) {
+	cases := map[string]string{
+		`[{"name": "p", "auth_key": "k", "record_names": ["a"]}]`:                                          `target "p": zone_id is required`,
+		`[{"name": "p", "zone_id": "z", "record_names": ["a"]}]`:                                           `target "p": auth_key is required`,
+		`[{"name": "p", "zone_id": "z", "auth_key": "k", "auth_method": "global", "record_names": ["a"]}]`: `target "p": auth_email is required`,
+		`[{"name": "p", "zone_id": "z", "auth_key": "k"}]`:                                                 `target "p": record_names is required`,
+		`[{"name": "p", "zone": "z"}]`:                                                                     `unknown field "zone"`,
+	}
+	for content, want := range cases {
+		_, err := loadTargets(writeTargetsFile(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected error containing %q, got %v", content, want, err)
+		}
+	}
+}
+
+func TestUpdaterWritesEachTarget(t *testing.T) {
+	primary := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	partner := newMockCloudflare(aRecordFixture("id-2", "home.partner.example", "198.51.100.1"))
+
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}}, primary, "203.0.113.10")
+
+	target := Target{Name: "partner", ZoneID: "zone-2", AuthMethod: "token", AuthKey: "scoped", RecordNames: []string{"home.partner.example"}}
+	targetCfg := target.config(u.cfg)
+	client, err := newCloudflareClient(partner.client(), targetCfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	u.targets = []writeTarget{{cfg: targetCfg, client: client}}
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if len(results) != 2 || results[1].Record != "home.partner.example" || results[1].Action != actionChanged {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if primary.updates != 1 || partner.updates != 1 {
+		t.Fatalf("expected one update per target, got %d and %d", primary.updates, partner.updates)
+	}
+}
+
+func TestLoadTargetsDisabledRecords(t *testing.T) {
+	path := writeTargetsFile(t, `[
+		{"name": "partner", "zone_id": "zone-2", "auth_key": "scoped", "record_names": [
+			"home.partner.example",
+			{"name": "vpn.partner.example", "enabled": false},
+			{"name": "nas.partner.example", "enabled": true}
+		]},
+		{"name": "paused", "zone_id": "zone-3", "auth_key": "scoped", "record_names": [{"name": "old.example.net", "enabled": false}]}
+	]`)
+
+	targets, err := loadTargets(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := targets[0]; !slices.Equal(got.RecordNames, []string{"home.partner.example", "nas.partner.example"}) || !slices.Equal(got.Disabled, []string{"vpn.partner.example"}) {
+		t.Fatalf("unexpected first target %+v", got)
+	}
+	if got := targets[1]; len(got.RecordNames) != 0 || !slices.Equal(got.Disabled, []string{"old.example.net"}) {
+		t.Fatalf("unexpected second target %+v", got)
+	}
+
+	_, err = loadTargets(writeTargetsFile(t, `[{"zone_id": "z", "auth_key": "k", "record_names": [{"name": "a", "enable": false}]}]`))
+	if err == nil || !strings.Contains(err.Error(), `unknown field "enable"`) {
+		t.Fatalf("expected a misspelled field to be rejected, got %v", err)
+	}
+}
+
+func TestUpdaterSkipsDisabledTargetRecords(t *testing.T) {
+	stale := aRecordFixture("id-3", "vpn.partner.example", "198.51.100.1")
+	stale["comment"] = managedComment
+	primary := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	partner := newMockCloudflare(aRecordFixture("id-2", "home.partner.example", "198.51.100.1"), stale)
+	cfg := Config{RecordNames: []string{"home.example.com"}, RecordMode: recordModeSync, Prune: true}
+	u := newTestUpdater(t, cfg, primary, "203.0.113.10")
+
+	target := Target{Name: "partner", ZoneID: "zone-2", AuthMethod: "token", AuthKey: "scoped", RecordNames: []string{"home.partner.example"}, Disabled: []string{"vpn.partner.example"}}
+	targetCfg := target.config(u.cfg)
+	client, err := newCloudflareClient(partner.client(), targetCfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	u.targets = []writeTarget{{cfg: targetCfg, client: client}}
+	u.disabled = target.Disabled
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if len(results) != 3 || results[0].Record != "vpn.partner.example" || results[0].Action != actionSkipped {
+		t.Fatalf("expected the disabled record to be reported as skipped, got %+v", results)
+	}
+	if partner.deletes != 0 || partner.records["vpn.partner.example"]["content"] != "198.51.100.1" {
+		t.Fatalf("expected the disabled record to be left alone")
+	}
+}
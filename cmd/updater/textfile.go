@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricLastSuccess is carried over from the previous textfile when a run
+// fails, so it keeps the time of the last successful run.
+const metricLastSuccess = "cloudflare_ddns_last_success_timestamp_seconds"
+
+// writeTextfile replaces path with the metrics of the run that started at
+// start and ended at end, in the Prometheus text format read by
+// node_exporter's textfile collector (CF_TEXTFILE_PATH).
+func writeTextfile(path string, results []Result, runErr error, start, end time.Time) error {
+	lastSuccess := end.Unix()
+	if runErr != nil {
+		var err error
+		if lastSuccess, err = previousLastSuccess(path); err != nil {
+			return err
+		}
+	}
+
+	success := 0
+	if runErr == nil {
+		success = 1
+	}
+	actions := make(map[string]int)
+	ips := make(map[string]string)
+	changed := 0
+	for _, r := range results {
+		actions[r.Action]++
+		switch r.Action {
+		case actionChanged, actionCreated:
+			changed++
+		}
+		if isAddressType(r.Type) && r.NewIP != "" {
+			ips[r.Type] = r.NewIP
+		}
+	}
+
+	var buf bytes.Buffer
+	writeMetric(&buf, "cloudflare_ddns_last_run_timestamp_seconds", "gauge", "Time the last run finished.", strconv.FormatInt(end.Unix(), 10))
+	writeMetric(&buf, "cloudflare_ddns_last_run_success", "gauge", "Whether the last run succeeded.", strconv.Itoa(success))
+	writeMetric(&buf, "cloudflare_ddns_last_run_duration_seconds", "gauge", "Duration of the last run.", strconv.FormatFloat(end.Sub(start).Seconds(), 'f', 3, 64))
+	if lastSuccess > 0 {
+		writeMetric(&buf, metricLastSuccess, "gauge", "Time the last successful run finished.", strconv.FormatInt(lastSuccess, 10))
+	}
+	writeMetric(&buf, "cloudflare_ddns_last_run_changes", "gauge", "Records updated or created by the last run.", strconv.Itoa(changed))
+
+	fmt.Fprintf(&buf, "# HELP cloudflare_ddns_last_run_records Records handled by the last run, by result.\n")
+	fmt.Fprintf(&buf, "# TYPE cloudflare_ddns_last_run_records gauge\n")
+	for _, action := range slices.Sorted(maps.Keys(actions)) {
+		fmt.Fprintf(&buf, "cloudflare_ddns_last_run_records{result=\"%s\"} %d\n", escapeLabel(action), actions[action])
+	}
+
+	if len(ips) > 0 {
+		fmt.Fprintf(&buf, "# HELP cloudflare_ddns_ip_info The published address per record type.\n")
+		fmt.Fprintf(&buf, "# TYPE cloudflare_ddns_ip_info gauge\n")
+		for _, recordType := range slices.Sorted(maps.Keys(ips)) {
+			fmt.Fprintf(&buf, "cloudflare_ddns_ip_info{type=\"%s\",ip=\"%s\"} 1\n", escapeLabel(recordType), escapeLabel(ips[recordType]))
+		}
+	}
+
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return err
+	}
+	// node_exporter usually runs as another user; the metrics hold nothing
+	// secret.
+	return os.Chmod(path, 0o644)
+}
+
+// writeMetric writes a single-sample metric with its HELP and TYPE lines.
+func writeMetric(buf *bytes.Buffer, name, kind, help, value string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, value)
+}
+
+// escapeLabel escapes a label value for the Prometheus text format.
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// previousLastSuccess reads the last-success timestamp from the textfile at
+// path, or returns 0 when there is none yet.
+func previousLastSuccess(path string) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), metricLastSuccess+" ")
+		if !ok {
+			continue
+		}
+		if ts, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			return ts, nil
+		}
+	}
+	return 0, scanner.Err()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddns.prom")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(1500 * time.Millisecond)
+	results := []Result{
+		{Action: actionChanged, Record: "a.example.com", Type: "A", OldIP: "198.51.100.1", NewIP: "203.0.113.10"},
+		{Action: actionUnchanged, Record: "b.example.com", Type: "A", OldIP: "203.0.113.10", NewIP: "203.0.113.10"},
+		{Action: actionUnchanged, Record: "c.example.com", Type: "AAAA", OldIP: "2001:db8::1", NewIP: "2001:db8::1"},
+	}
+
+	if err := writeTextfile(path, results, nil, start, end); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected a world-readable textfile, got %v, %v", info.Mode(), err)
+	}
+	data, _ := os.ReadFile(path)
+	text := string(data)
+	for _, want := range []string{
+		"# TYPE cloudflare_ddns_last_run_success gauge\ncloudflare_ddns_last_run_success 1\n",
+		"cloudflare_ddns_last_run_duration_seconds 1.500\n",
+		"cloudflare_ddns_last_success_timestamp_seconds 1704067201\n",
+		"cloudflare_ddns_last_run_changes 1\n",
+		`cloudflare_ddns_last_run_records{result="changed"} 1` + "\n",
+		`cloudflare_ddns_last_run_records{result="unchanged"} 2` + "\n",
+		`cloudflare_ddns_ip_info{type="A",ip="203.0.113.10"} 1` + "\n",
+		`cloudflare_ddns_ip_info{type="AAAA",ip="2001:db8::1"} 1` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in textfile:\n%s", want, text)
+		}
+	}
+
+	// A failed run keeps the time of the last success.
+	if err := writeTextfile(path, nil, errors.New("boom"), end.Add(time.Hour), end.Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	text = string(data)
+	if !strings.Contains(text, "cloudflare_ddns_last_run_success 0\n") || !strings.Contains(text, "cloudflare_ddns_last_success_timestamp_seconds 1704067201\n") {
+		t.Fatalf("expected the previous success time kept, got:\n%s", text)
+	}
+}
+
+func TestWriteTextfileFirstRunFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddns.prom")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := writeTextfile(path, nil, errors.New("boom"), now, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), metricLastSuccess) {
+		t.Fatalf("expected no last-success time before any success, got:\n%s", data)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Fatalf("unexpected escaped value %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+)
+
+const userAgentProduct = "cloudflare-ddns-cron"
+
+// RoundTripperFunc adapts an ordinary function to http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a RoundTripper with additional behavior such as logging,
+// retries, or metrics.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// Chain layers middlewares around base. The first middleware is outermost: it
+// sees each request first and each response last. A nil base uses
+// http.DefaultTransport.
+func Chain(base http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	rt := base
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// withMiddlewares returns a shallow copy of client whose transport is wrapped
+// by middlewares, leaving the original client untouched.
+func withMiddlewares(client *http.Client, middlewares ...Middleware) *http.Client {
+	if len(middlewares) == 0 {
+		return client
+	}
+
+	wrapped := *client
+	wrapped.Transport = Chain(client.Transport, middlewares...)
+	return &wrapped
+}
+
+// userAgentMiddleware appends the updater's product token to the User-Agent
+// set by the SDK so requests are identifiable in Cloudflare audit logs.
+func userAgentMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ua := req.Header.Get("User-Agent")
+		if strings.Contains(ua, userAgentProduct) {
+			return next.RoundTrip(req)
+		}
+
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", strings.TrimSpace(ua+" "+userAgentProduct))
+		return next.RoundTrip(req)
+	})
+}
+
+// insecureClient returns a copy of client that skips TLS certificate
+// verification. It is only used for IP discovery against self-hosted services.
+func insecureClient(client *http.Client) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	insecure := *client
+	insecure.Transport = transport
+	return &insecure
+}
+
+// hostOverrideMiddleware sends every request with the given Host header while
+// still connecting to the address in the request URL.
+func hostOverrideMiddleware(host string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Host = host
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// serverNameClient returns a copy of client that sends serverName via SNI and
+// verifies the peer certificate against it instead of the dialed host.
+func serverNameClient(client *http.Client, serverName string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if base, ok := client.Transport.(*http.Transport); ok {
+		transport = base.Clone()
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.ServerName = serverName
+
+	named := *client
+	named.Transport = transport
+	return &named
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChainOrdering(t *testing.T) {
+	var calls []string
+
+	record := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+":before")
+				resp, err := next.RoundTrip(req)
+				calls = append(calls, name+":after")
+				return resp, err
+			})
+		}
+	}
+
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls = append(calls, "base")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if _, err := Chain(base, record("outer"), record("inner")).RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"outer:before", "inner:before", "base", "inner:after", "outer:after"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("unexpected call order %v", calls)
+	}
+}
+
+func TestNewCloudflareClientMiddlewares(t *testing.T) {
+	var userAgent string
+	var sawMiddleware bool
+
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		userAgent = req.Header.Get("User-Agent")
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Header: make(http.Header)}, nil
+	})
+	httpClient := &http.Client{Transport: base}
+
+	extra := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sawMiddleware = true
+			return next.RoundTrip(req)
+		})
+	}
+
+	cfg := Config{AuthMethod: "token", AuthKey: "token-value", ZoneID: "zone-id", RecordName: "example.com", RecordType: "A"}
+	client, err := newCloudflareClient(httpClient, cfg, nil, extra)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	_, _ = cloudflareProvider{client: client}.Fetch(t.Context(), cfg)
+
+	if !sawMiddleware {
+		t.Fatalf("expected extra middleware to run")
+	}
+	if !strings.HasSuffix(userAgent, userAgentProduct) {
+		t.Fatalf("unexpected user agent %q", userAgent)
+	}
+	if _, ok := httpClient.Transport.(RoundTripperFunc); !ok {
+		t.Fatalf("expected original client transport to be untouched")
+	}
+}
+
+func TestInsecureClientForDiscovery(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.10"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{}
+	if _, _, err := discoverIP(context.Background(), client, []string{server.URL}, familyIPv4, 0, nil); err == nil {
+		t.Fatalf("expected self-signed certificate to be rejected")
+	}
+
+	ip, _, err := discoverIP(context.Background(), insecureClient(client), []string{server.URL}, familyIPv4, 0, nil)
+	if err != nil {
+		t.Fatalf("expected insecure client to succeed, got %v", err)
+	}
+	if ip != "203.0.113.10" {
+		t.Fatalf("unexpected IP %s", ip)
+	}
+	if client.Transport != nil {
+		t.Fatalf("expected original client to be untouched")
+	}
+}
+
+func TestAPIHostOverride(t *testing.T) {
+	var host, serverName string
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		serverName = r.TLS.ServerName
+		api.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := Config{
+		AuthMethod:      "token",
+		AuthKey:         "token-value",
+		ZoneID:          "zone-id",
+		RecordName:      "example.com",
+		RecordType:      "A",
+		APIBaseURL:      server.URL + "/client/v4/",
+		APIHostOverride: "example.com:8443",
+	}
+	client, err := newCloudflareClient(server.Client(), cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	if _, err := (cloudflareProvider{client: client}).Fetch(context.Background(), cfg); err != nil {
+		t.Fatalf("expected request through the override to succeed, got %v", err)
+	}
+	if host != "example.com:8443" || serverName != "example.com" {
+		t.Fatalf("expected Host example.com:8443 and SNI example.com, got %q and %q", host, serverName)
+	}
+}
@@ -0,0 +1,905 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go/v2"
+	"github.com/cloudflare/cloudflare-go/v2/dns"
+	"github.com/derek/cloudflare-ddns-cron/notify"
+)
+
+// updater holds the clients shared across runs so watch mode reuses
+// connections between cycles.
+type updater struct {
+	cfg             Config
+	discoveryClient *http.Client
+	cfClient        *cloudflare.Client
+	// targets holds a client per additional target in cfg.Targets.
+	targets []writeTarget
+	// disabled lists the target records switched off in CF_TARGETS_FILE,
+	// reported as skipped on every run.
+	disabled []string
+	// notifiers receive notifications about runs.
+	notifiers *notify.Registry
+	// push, in watch mode with CF_WEBHOOK_LISTEN_ADDR, holds addresses
+	// pushed by the router for the next cycle.
+	push *pushServer
+	// networkChanges describes the moves to a new network detected during
+	// the current run.
+	networkChanges []string
+	// stuckAlerts describes the addresses found unchanged for longer than
+	// CF_STUCK_AFTER during the current run.
+	stuckAlerts []string
+	// zoneNames caches zone names looked up for relative name matching,
+	// keyed by zone ID.
+	zoneNames map[string]string
+	clock     Clock
+	// out receives machine-readable results when cfg.Output is json.
+	out io.Writer
+	// lookup resolves names for DNS verification.
+	lookup lookupFunc
+	// sourceLookup resolves CF_IP_SOURCE_RECORD through the system resolver,
+	// so LAN-only and split-horizon names work.
+	sourceLookup lookupFunc
+	// provider returns the DNSProvider records are synchronized through.
+	provider providerFunc
+	// health, when set, is updated after every cycle.
+	health *healthState
+	// budget counts the Cloudflare API calls of the current run.
+	budget *callBudget
+	// outage counts Cloudflare server errors in a row for
+	// CF_OUTAGE_COOLDOWN.
+	outage *outageDetector
+	// journal, with CF_LOG_JOURNAL, tags log entries with the detected IP.
+	journal *journalWriter
+	// runID identifies the current cycle in logs, notifications and reports.
+	runID string
+	// booted is set once the first cycle has finished, or once it is found
+	// to run long after boot, which ends CF_BOOT_WAIT. bootDeadline is when
+	// the wait runs out.
+	booted       bool
+	bootDeadline time.Time
+}
+
+func newUpdater(cfg Config) (*updater, error) {
+	httpClient := &http.Client{Timeout: defaultHTTPTimeout}
+
+	discoveryClient := httpClient
+	if cfg.IPInsecureTLS {
+		log.Printf("warning: %s is enabled; TLS certificates of IP services will NOT be verified", envIPInsecureTLS)
+		discoveryClient = insecureClient(httpClient)
+	}
+
+	budget := newCallBudget(cfg.MaxAPICalls)
+	outage := newOutageDetector(cfg.OutageThreshold)
+	cfClient, err := newCloudflareClient(httpClient, cfg, budget, outage.middleware)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []writeTarget
+	var disabled []string
+	for _, t := range cfg.Targets {
+		disabled = append(disabled, t.Disabled...)
+		if len(t.RecordNames) == 0 {
+			log.Printf("target %q has no enabled records; skipping it", t.Name)
+			continue
+		}
+		targetCfg := t.config(cfg)
+		client, err := newCloudflareClient(httpClient, targetCfg, budget, outage.middleware)
+		if err != nil {
+			return nil, fmt.Errorf("target %q: %w", t.Name, err)
+		}
+		targets = append(targets, writeTarget{cfg: targetCfg, client: client})
+	}
+
+	return &updater{
+		cfg:             cfg,
+		discoveryClient: discoveryClient,
+		cfClient:        cfClient,
+		targets:         targets,
+		disabled:        disabled,
+		notifiers:       newNotifiers(cfg, httpClient),
+		clock:           systemClock,
+		out:             os.Stdout,
+		lookup:          newLookup(cfg.VerifyResolver),
+		sourceLookup:    newLookup(""),
+		provider:        newCloudflareProvider,
+		budget:          budget,
+		outage:          outage,
+	}, nil
+}
+
+// errRunTimeout marks a cycle that was cancelled by CF_RUN_TIMEOUT.
+var errRunTimeout = errors.New("run timed out")
+
+// run performs one complete update cycle: determine the desired content and
+// synchronize every configured record. A Result is returned for every record,
+// including when the cycle fails before any record is processed. When
+// cfg.RunTimeout is set the whole cycle shares a single deadline. During a
+// CF_OUTAGE_COOLDOWN every record is reported as skipped.
+func (u *updater) run(ctx context.Context) ([]Result, error) {
+	u.budget.reset()
+	if u.coolingDown() {
+		return resultsFor(u.cfg, actionSkipped, nil), nil
+	}
+	defer u.trackOutage()
+	if u.cfg.RunTimeout <= 0 {
+		return u.checkBudget(u.sync(ctx))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.RunTimeout)
+	defer cancel()
+
+	results, err := u.sync(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s: %v", errRunTimeout, u.cfg.RunTimeout, err)
+	}
+	return u.checkBudget(results, err)
+}
+
+// checkBudget reports a run that hit CF_MAX_API_CALLS as aborted, whatever
+// else went wrong, since the calls refused past the limit left it
+// incomplete.
+func (u *updater) checkBudget(results []Result, err error) ([]Result, error) {
+	if !u.budget.exceeded() {
+		return results, err
+	}
+	abort := fmt.Errorf("run aborted: %w (%s=%d)", errAPICallLimit, envMaxAPICalls, u.cfg.MaxAPICalls)
+	if err != nil {
+		return results, errors.Join(abort, err)
+	}
+	return results, abort
+}
+
+// sync determines the desired content and synchronizes every record. Records
+// are processed per write target and in groups sharing a record type; the
+// address for each type is discovered once and shared by all targets.
+// With CF_VERIFY_DNS the published records are then resolved to confirm them.
+func (u *updater) sync(ctx context.Context) ([]Result, error) {
+	if err := u.resolveZone(ctx); err != nil {
+		err = fmt.Errorf("failed to resolve %s: %w", envZoneName, err)
+		return resultsFor(u.cfg, actionError, err), err
+	}
+
+	var state State
+	if u.cfg.StateFile != "" {
+		var err error
+		state, err = loadState(u.cfg.StateFile)
+		if err != nil {
+			err = fmt.Errorf("failed to load state file: %w", err)
+			return resultsFor(u.cfg, actionError, err), err
+		}
+	}
+
+	u.networkChanges = nil
+	u.stuckAlerts = nil
+	var results []Result
+	var errs []error
+	found := make(map[string]discovery)
+	for family, ip := range u.push.take() {
+		recordType := "A"
+		if family == familyIPv6 {
+			recordType = "AAAA"
+		}
+		log.Printf("using pushed %s address %s", family, ip)
+		found[recordType] = discovery{recordType: recordType, ip: ip}
+	}
+	// AUTO records take a pushed address too, of the preferred family when
+	// both were pushed.
+	for _, recordType := range autoTypes(u.cfg.AutoPrefer) {
+		if d, ok := found[recordType]; ok {
+			found[recordTypeAuto] = d
+			break
+		}
+	}
+	frozen := u.frozen()
+	for _, name := range u.disabled {
+		log.Printf("skipping disabled record %s", name)
+		results = append(results, Result{Action: actionSkipped, Record: name, Type: u.cfg.RecordType})
+	}
+	writeTargets := append([]writeTarget{{cfg: u.cfg, client: u.cfClient}}, u.targets...)
+	if !slices.ContainsFunc(writeTargets, func(t writeTarget) bool { return needsAddress(t.cfg) }) {
+		log.Printf("no A, AAAA or AUTO records configured; skipping IP discovery")
+	}
+	for _, target := range writeTargets {
+		targetCfg := target.cfg
+		targetCfg.ReadOnly = targetCfg.ReadOnly || frozen
+		for _, cfg := range groupByType(targetCfg) {
+			groupResults, err := u.syncGroup(ctx, cfg, target.client, &state, found)
+			results = append(results, groupResults...)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if u.cfg.VerifyDNS {
+		u.verify(ctx, results)
+	}
+
+	if len(errs) > 0 {
+		return results, errors.Join(errs...)
+	}
+	if n := countFailed(results); n > 0 {
+		failed := &failedRecordsError{failed: n, total: len(results)}
+		for _, r := range results {
+			if r.Err != nil {
+				failed.errs = append(failed.errs, r.Err)
+			}
+		}
+		return results, failed
+	}
+	if n := countUnverified(results); n > 0 {
+		return results, fmt.Errorf("%d of %d record(s) failed DNS verification", n, len(results))
+	}
+	if n := countNeeded(results); n > 0 && !u.cfg.ReadOnly {
+		return results, fmt.Errorf("%w: %d of %d record(s) need changes but were left alone (%s exists)", errFrozen, n, len(results), u.cfg.FreezeFile)
+	}
+	if n := countNeeded(results); n > 0 {
+		return results, fmt.Errorf("%w: %d of %d record(s) need changes (%s is set)", errChangesNeeded, n, len(results), envReadOnly)
+	}
+	return results, nil
+}
+
+// frozen reports whether CF_FREEZE_FILE exists, in which case the run only
+// reports the changes it would make, as with CF_READONLY. The file is checked
+// at the start of every run so removing it resumes updates.
+func (u *updater) frozen() bool {
+	if u.cfg.FreezeFile == "" {
+		return false
+	}
+	_, err := os.Stat(u.cfg.FreezeFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// An unreadable path may still be a freeze; err on the safe side.
+		log.Printf("warning: cannot check %s %s: %v; treating updates as frozen", envFreezeFile, u.cfg.FreezeFile, err)
+		return true
+	}
+	if err == nil {
+		log.Printf("updates are frozen: %s exists; only reporting changes", u.cfg.FreezeFile)
+		return true
+	}
+	return false
+}
+
+// discovery is the outcome of discovering the address for one configured
+// record type, reused by every target within a run. For SRV records, ip
+// holds the data printed by CF_CONTENT_COMMAND.
+type discovery struct {
+	recordType string
+	ip         string
+	err        error
+	// tracked is set once the address passed the checks below and was
+	// recorded by trackNetwork.
+	tracked bool
+}
+
+// syncGroup synchronizes the records in cfg.RecordNames, which all share
+// cfg.RecordType, through client. found caches discovered addresses by
+// record type. An error is returned only when the group fails before any
+// record is processed; per-record failures are reported in the results.
+func (u *updater) syncGroup(ctx context.Context, cfg Config, client *cloudflare.Client, state *State, found map[string]discovery) ([]Result, error) {
+	var content string
+	if cfg.RecordType == "CAA" {
+		content = cfg.CAA.String()
+	} else if !isAddressType(cfg.RecordType) {
+		if cfg.ContentCommand != "" {
+			srv, err := u.commandSRV(ctx, cfg, found)
+			if err != nil {
+				return resultsFor(cfg, actionError, err), err
+			}
+			cfg.SRV = srv
+		}
+		content = cfg.SRV.String()
+	} else {
+		key := cfg.RecordType
+		d, ok := found[key]
+		if !ok {
+			d.recordType, d.ip, d.err = u.discoverAtBoot(ctx, cfg, state)
+			if d.err != nil {
+				d.err = fmt.Errorf("failed to determine public IP: %w", d.err)
+			} else {
+				if cfg.MaskIP {
+					u.journal.setIP(maskIPs(d.ip))
+				} else {
+					u.journal.setIP(d.ip)
+				}
+				log.Printf("detected public IP: %s", d.ip)
+			}
+			found[key] = d
+		}
+
+		cfg.RecordType = d.recordType
+		if d.err != nil {
+			var mismatch *familyMismatchError
+			if errors.As(d.err, &mismatch) && (cfg.Mismatch == mismatchSkip || cfg.Mismatch == mismatchWarn) {
+				logMismatch(cfg, mismatch)
+				return resultsFor(cfg, actionSkipped, nil), nil
+			}
+			return resultsFor(cfg, actionError, d.err), d.err
+		}
+		ip := d.ip
+
+		if isExcludedIP(ip, cfg.ExcludeIPs) {
+			log.Printf("warning: detected IP %s matches %s; skipping update", ip, envExcludeIPs)
+			return resultsFor(cfg, actionSkipped, nil), nil
+		}
+
+		if cfg.ExpectedCountry != "" {
+			country, err := lookupCountry(ctx, u.discoveryClient, cfg.GeoURL, ip)
+			if err != nil {
+				err = fmt.Errorf("failed to verify location of %s: %w", ip, err)
+				return resultsFor(cfg, actionError, err), err
+			}
+			if country != cfg.ExpectedCountry {
+				log.Printf("warning: detected IP %s geolocates to %s, expected %s; skipping update", ip, country, cfg.ExpectedCountry)
+				return resultsFor(cfg, actionSkipped, nil), nil
+			}
+		}
+
+		if cfg.PrecheckTarget != "" {
+			if err := precheck(ctx, cfg.PrecheckTarget, ip, cfg.PrecheckTimeout); err != nil {
+				log.Printf("warning: detected IP %s failed the %s connectivity check: %v; skipping update", ip, envPrecheckTarget, err)
+				return resultsFor(cfg, actionSkipped, nil), nil
+			}
+		}
+
+		// Only an accepted address is compared with the previous network and
+		// recorded, so a rejected one never raises a notification.
+		if !d.tracked {
+			u.trackNetwork(cfg, state, ip)
+			d.tracked = true
+			found[key] = d
+		}
+		content = ip
+	}
+
+	if cfg.NameMatch == nameMatchRelative {
+		zone, err := u.zoneName(ctx, client, cfg)
+		if err != nil {
+			err = fmt.Errorf("failed to look up zone name for %s: %w", envNameMatch, err)
+			return resultsFor(cfg, actionError, err), err
+		}
+		cfg.ZoneName = zone
+		names := make([]string, 0, len(cfg.RecordNames))
+		for _, name := range cfg.RecordNames {
+			names = append(names, qualifyName(name, zone))
+		}
+		cfg.RecordNames = names
+	}
+
+	if cfg.CustomHostnames {
+		origins, err := customHostnameOrigins(ctx, client, cfg)
+		if err != nil {
+			return resultsFor(cfg, actionError, err), err
+		}
+		cfg.RecordNames = origins
+	}
+
+	results := u.syncRecords(ctx, client, cfg, *state, content)
+	u.recordResults(cfg, state, results)
+
+	if cfg.Prune {
+		pruned, err := pruneRecords(ctx, client, cfg)
+		if err != nil {
+			log.Printf("%v", err)
+			pruned = []Result{{Action: actionError, Type: cfg.RecordType, Err: err}}
+		}
+		u.recordResults(cfg, state, pruned)
+		results = append(results, pruned...)
+	}
+
+	return results, nil
+}
+
+// syncRecords synchronizes every record in cfg.RecordNames to content, with
+// up to cfg.Concurrency records in flight at once. Results keep the order of
+// cfg.RecordNames however the updates finish. Records that state shows
+// already held content within CF_RECHECK_INTERVAL are not looked up, unless
+// CF_FORCE or CF_MAX_RECORD_AGE needs the live record, and with CF_MINIMAL
+// the others are found in a single listing.
+func (u *updater) syncRecords(ctx context.Context, client *cloudflare.Client, cfg Config, state State, content string) []Result {
+	results := make([]Result, len(cfg.RecordNames))
+	workers := min(max(cfg.Concurrency, 1), len(cfg.RecordNames))
+
+	provider := u.provider(client, cfg)
+
+	// A forced write and the record age both need the live record.
+	useCache := !cfg.DryRun && !cfg.AlwaysFetch && !cfg.Force && cfg.MaxRecordAge <= 0
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				recordCfg := cfg
+				recordCfg.RecordName = cfg.RecordNames[i]
+
+				if useCache && state.recentlyChecked(recordCfg.RecordName, cfg.RecordType, content, u.clock.Now(), cfg.RecheckInterval) {
+					label := cfg.displayName(recordCfg.RecordName)
+					log.Printf("%s already held %s at the last check; skipping lookup", label, content)
+					results[i] = Result{Action: actionUnchanged, Record: recordCfg.RecordName, Label: label, Type: cfg.RecordType, OldIP: content, NewIP: content, Cached: true}
+					continue
+				}
+
+				start := u.clock.Now()
+				if cfg.MaxWritesPerHour > 0 {
+					recordCfg.RecentWrites = state.writesInWindow(recordCfg.RecordName, cfg.RecordType, start)
+				}
+				result, err := syncRecord(ctx, provider, recordCfg, content, start)
+				result.Duration = u.clock.Now().Sub(start)
+				if err != nil {
+					log.Printf("%s: %v", result.Label, err)
+				}
+				results[i] = result
+			}
+		}()
+	}
+	for i := range cfg.RecordNames {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// commandSRV runs CF_CONTENT_COMMAND for the SRV records in cfg, once per run
+// however many targets share it, and parses its output.
+func (u *updater) commandSRV(ctx context.Context, cfg Config, found map[string]discovery) (SRVData, error) {
+	d, ok := found[cfg.RecordType]
+	if !ok {
+		d.recordType = cfg.RecordType
+		d.ip, d.err = runContentCommand(ctx, cfg.ContentCommand, cfg.RecordType)
+		found[cfg.RecordType] = d
+	}
+	if d.err != nil {
+		return SRVData{}, d.err
+	}
+	return parseSRVContent(d.ip)
+}
+
+// discover determines the public IP from the configured source. With
+// CF_CONTENT_COMMAND it is what the command prints, and with
+// CF_IP_SOURCE=dns-record the address another hostname resolves to. AAAA
+// records are otherwise read from the configured interface, or else from the
+// IPv6 services. UPnP and NAT-PMP, which only report IPv4, fall back to the
+// HTTP services when the gateway cannot be queried. In consensus mode every
+// HTTP service is asked; otherwise the first answer wins within
+// CF_DISCOVERY_TIMEOUT and, with service stickiness enabled, the answering
+// service is recorded in state. Per-service outcomes are recorded whenever a
+// state file is configured.
+func (u *updater) discover(ctx context.Context, cfg Config, state *State) (string, error) {
+	plan := planDiscovery(cfg, *state)
+	if plan.Command != "" {
+		content, err := runContentCommand(ctx, plan.Command, cfg.RecordType)
+		if err != nil {
+			return "", err
+		}
+		if plan.Family == familyIPv6 {
+			return parseIPv6(content)
+		}
+		return parseIPv4(content)
+	}
+	if plan.SourceRecord != "" {
+		return resolveSourceRecord(ctx, u.sourceLookup, plan.SourceRecord, plan.Family)
+	}
+	if plan.Interface != "" {
+		return discoverInterfaceIPv6(plan.Interface, cfg.IPv6Prefer)
+	}
+
+	if plan.UPnP {
+		ip, err := discoverUPnP(ctx, u.discoveryClient, u.clock)
+		if err == nil {
+			return ip, nil
+		}
+		log.Printf("UPnP and NAT-PMP discovery failed: %v; falling back to HTTP services", err)
+	}
+
+	// With a state file, the outcome of every query is kept so unreliable
+	// services show up in the service-stats subcommand.
+	var observe queryObserver
+	dirty := false
+	if cfg.StateFile != "" {
+		observe = func(svc string, err error) {
+			state.recordServiceResult(svc, err, u.clock.Now())
+			dirty = true
+		}
+	}
+
+	var ip string
+	var err error
+	if plan.Consensus > 0 {
+		ip, err = discoverConsensus(ctx, u.discoveryClient, plan.Services, plan.Family, cfg.IPServiceRetries, plan.Consensus, cfg.ConsensusTiebreak, observe)
+	} else {
+		services := plan.Services
+		if plan.Shuffle {
+			services = shuffleServices(services)
+		}
+		var service string
+		discoveryCtx, cancel := withDiscoveryTimeout(ctx, cfg.DiscoveryTimeout)
+		ip, service, err = discoverIP(discoveryCtx, u.discoveryClient, services, plan.Family, cfg.IPServiceRetries, observe)
+		cancel()
+		if err == nil && plan.Sticky && service != state.LastService {
+			state.LastService = service
+			dirty = true
+		}
+	}
+
+	if dirty {
+		if err := saveState(cfg.StateFile, *state); err != nil {
+			log.Printf("warning: failed to save state file: %v", err)
+		}
+	}
+	return ip, err
+}
+
+// shuffleServices returns services in a random order for CF_IP_SHUFFLE,
+// leaving the configured slice untouched.
+func shuffleServices(services []string) []string {
+	shuffled := append([]string(nil), services...)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+// discoverWithRetries discovers the address for cfg.RecordType, resolving
+// AUTO to the family found. When every source fails, the whole phase is run
+// again up to cfg.DiscoveryRetries times with doubling waits, which rides
+// out a brief network outage that per-service retries cannot.
+func (u *updater) discoverWithRetries(ctx context.Context, cfg Config, state *State) (string, string, error) {
+	backoff := RetryPolicy{BaseDelay: cfg.DiscoveryRetryDelay, MaxDelay: max(cfg.DiscoveryRetryDelay, defaultRetryMaxDelay), Jitter: jitterNone}
+	for attempt := 0; ; attempt++ {
+		recordType, ip, err := cfg.RecordType, "", error(nil)
+		if cfg.RecordType == recordTypeAuto {
+			recordType, ip, err = u.discoverAuto(ctx, cfg, state)
+		} else {
+			ip, err = u.discover(ctx, cfg, state)
+		}
+		if err == nil && attempt > 0 {
+			log.Printf("IP discovery attempt %d of %d succeeded", attempt+1, cfg.DiscoveryRetries+1)
+		}
+		if err == nil || attempt >= cfg.DiscoveryRetries || ctx.Err() != nil {
+			return recordType, ip, err
+		}
+
+		wait := backoff.delay(attempt, 0, nil)
+		log.Printf("IP discovery attempt %d of %d failed: %v; retrying in %s", attempt+1, cfg.DiscoveryRetries+1, err, wait)
+		if err := sleepContext(ctx, u.clock, wait); err != nil {
+			return recordType, "", err
+		}
+	}
+}
+
+// discoverAtBoot runs discoverWithRetries. In the first run of the process
+// with CF_BOOT_WAIT, when the system booted less than that long ago, it keeps
+// trying every bootRetryInterval until discovery succeeds or CF_BOOT_WAIT
+// has passed since boot, so a run started before the network is up does not
+// fail. Other runs fail as usual.
+func (u *updater) discoverAtBoot(ctx context.Context, cfg Config, state *State) (string, string, error) {
+	if u.booted || cfg.BootWait <= 0 {
+		return u.discoverWithRetries(ctx, cfg, state)
+	}
+	if u.bootDeadline.IsZero() {
+		left := bootWaitLeft(cfg.BootWait)
+		if left <= 0 {
+			u.booted = true
+			return u.discoverWithRetries(ctx, cfg, state)
+		}
+		u.bootDeadline = u.clock.Now().Add(left)
+	}
+	for {
+		recordType, ip, err := u.discoverWithRetries(ctx, cfg, state)
+		if err == nil || ctx.Err() != nil {
+			return recordType, ip, err
+		}
+		left := u.bootDeadline.Sub(u.clock.Now())
+		if left <= 0 {
+			return recordType, ip, fmt.Errorf("%w (gave up after %s=%s)", err, envBootWait, cfg.BootWait)
+		}
+		wait := min(bootRetryInterval, left)
+		log.Printf("IP discovery failed at boot: %v; retrying in %s (%s of %s left)", err, wait, left.Round(time.Second), envBootWait)
+		if err := sleepContext(ctx, u.clock, wait); err != nil {
+			return recordType, "", err
+		}
+	}
+}
+
+// autoTypes lists the record types AUTO may resolve to, preferred first.
+func autoTypes(prefer string) []string {
+	if prefer == autoPreferIPv6 {
+		return []string{"AAAA", "A"}
+	}
+	return []string{"A", "AAAA"}
+}
+
+// discoverAuto resolves RecordType AUTO by discovering the preferred address
+// family first and falling back to the other one.
+func (u *updater) discoverAuto(ctx context.Context, cfg Config, state *State) (string, string, error) {
+	var errs []error
+	for _, recordType := range autoTypes(cfg.AutoPrefer) {
+		typed := cfg
+		typed.RecordType = recordType
+		ip, err := u.discover(ctx, typed, state)
+		if err == nil {
+			log.Printf("%s resolved to %s", envRecordType, recordType)
+			return recordType, ip, nil
+		}
+		if ctx.Err() != nil {
+			return cfg.RecordType, "", ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", recordType, err))
+	}
+
+	return cfg.RecordType, "", errors.Join(errs...)
+}
+
+// cycle runs one update cycle, reports its results (also to CF_REPORT_FILE
+// and CF_TEXTFILE_PATH) and sends any notification. It is the unit of work
+// in both modes. A failure is returned as a *runError carrying the cycle's
+// ID.
+func (u *updater) cycle(ctx context.Context) error {
+	u.runID = newRunID()
+	defer startRun(u.runID)()
+	u.journal.setRunID(u.runID)
+	start := u.clock.Now()
+	results, err := u.run(ctx)
+	u.booted = true
+	u.report(results)
+	if u.cfg.ReportFile != "" {
+		if werr := writeReportFile(u.cfg.ReportFile, u.runID, results, err, start, u.clock.Now()); werr != nil {
+			log.Printf("warning: failed to write %s: %v", envReportFile, werr)
+		}
+	}
+	if u.cfg.TextfilePath != "" {
+		if werr := writeTextfile(u.cfg.TextfilePath, results, err, start, u.clock.Now()); werr != nil {
+			log.Printf("warning: failed to write %s: %v", envTextfilePath, werr)
+		}
+	}
+	u.notify(ctx, err)
+	if u.health != nil {
+		u.health.record(err)
+	}
+	if err != nil {
+		return &runError{runID: u.runID, err: err}
+	}
+	return nil
+}
+
+// report writes results to u.out in the configured output format.
+func (u *updater) report(results []Result) {
+	switch u.cfg.Output {
+	case outputReport:
+		if err := writeReport(u.out, results); err != nil {
+			log.Printf("warning: failed to write report: %v", err)
+		}
+		return
+	case outputJSON:
+	default:
+		if len(results) > 1 {
+			logSummary(results)
+		}
+		return
+	}
+
+	now := u.clock.Now()
+	for _, r := range results {
+		if err := writeJSONResult(u.out, r, u.runID, now); err != nil {
+			log.Printf("warning: failed to write JSON result: %v", err)
+			return
+		}
+	}
+}
+
+// resultsFor returns the same action for every record in cfg, used when a run
+// ends before any record is processed.
+func resultsFor(cfg Config, action string, err error) []Result {
+	results := make([]Result, 0, len(cfg.RecordNames))
+	for _, name := range cfg.RecordNames {
+		results = append(results, Result{Action: action, Record: name, Type: cfg.RecordType, Err: err})
+	}
+	return results
+}
+
+// syncRecord brings the record named cfg.RecordName in line with content
+// through provider, honoring dry-run mode. With CF_MULTI_MATCH=all every
+// record of the name is brought in line; the Result is that of the first
+// one not already up to date. now is compared with the record's modified_on
+// for CF_MAX_RECORD_AGE. The returned Result is populated even on error.
+func syncRecord(ctx context.Context, provider DNSProvider, cfg Config, content string, now time.Time) (Result, error) {
+	result := Result{Action: actionError, Record: cfg.RecordName, Label: cfg.displayName(cfg.RecordName), Type: cfg.RecordType, NewIP: content}
+	if cfg.AuditComment != "" {
+		cfg.AuditComment = auditComment(cfg.AuditComment, now)
+	}
+
+	records, err := fetchRecordsToSync(ctx, provider, cfg)
+	if errors.Is(err, errRecordNotFound) && cfg.RecordMode == recordModeSync {
+		return createRecord(ctx, provider, cfg, result)
+	}
+	if errors.Is(err, errRecordNotFound) && cfg.MissingOK {
+		log.Printf("warning: %v; skipping because %s is set", err, envMissingOK)
+		result.Action = actionSkipped
+		return result, nil
+	}
+	if err != nil {
+		result.Err = fmt.Errorf("failed to fetch DNS record: %w", err)
+		return result, result.Err
+	}
+
+	var synced Result
+	for i, record := range records {
+		recordResult, err := syncFetchedRecord(ctx, provider, cfg, record, content, now, result)
+		if err != nil {
+			return recordResult, err
+		}
+		if i == 0 || synced.Action == actionUnchanged {
+			synced = recordResult
+		}
+	}
+	return synced, nil
+}
+
+// fetchRecordsToSync returns the records syncRecord brings in line: the one
+// provider.Fetch picks, or with CF_MULTI_MATCH=all every record of the name
+// holding CF_MATCH_CONTENT when set.
+func fetchRecordsToSync(ctx context.Context, provider DNSProvider, cfg Config) ([]dns.Record, error) {
+	if cfg.MultiMatch != multiMatchAll {
+		record, err := provider.Fetch(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []dns.Record{record}, nil
+	}
+
+	records, err := provider.FetchRecords(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MatchContent != "" {
+		records = slices.DeleteFunc(records, func(record dns.Record) bool {
+			content, err := extractRecordContent(record)
+			return err != nil || content != cfg.MatchContent
+		})
+		if len(records) == 0 {
+			return nil, fmt.Errorf("%w for %s with content %s", errRecordNotFound, cfg.RecordName, cfg.MatchContent)
+		}
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w for %s", errRecordNotFound, cfg.RecordName)
+	}
+	return records, nil
+}
+
+// syncFetchedRecord brings record in line with content, filling in result.
+func syncFetchedRecord(ctx context.Context, provider DNSProvider, cfg Config, record dns.Record, content string, now time.Time, result Result) (Result, error) {
+	current, err := extractRecordContent(record)
+	if err != nil {
+		result.Err = fmt.Errorf("unexpected DNS record content: %w", err)
+		return result, result.Err
+	}
+	result.OldIP = current
+
+	if cfg.PreserveMeta {
+		cfg.TTL = int(record.TTL)
+		cfg.Proxied = record.Proxied
+	}
+	cfg.Tags = recordTags(record)
+
+	changes := diffRecord(record, cfg, current, content)
+	result.Changes = changes
+	if cfg.AlwaysFetch {
+		log.Printf("fetched %s (%s):", result.Label, envAlwaysFetch)
+		for _, change := range changes {
+			log.Printf("  %s", change)
+		}
+	}
+	if !hasChanges(changes) {
+		age := now.Sub(record.ModifiedOn)
+		switch {
+		case cfg.Force:
+			log.Printf("Cloudflare record %s already up to date; writing it anyway because %s is set", result.Label, envForce)
+		case cfg.MaxRecordAge > 0 && !record.ModifiedOn.IsZero() && age >= cfg.MaxRecordAge:
+			log.Printf("Cloudflare record %s already up to date; rewriting it because it was last modified %s ago (%s=%s)", result.Label, formatAge(age), envMaxRecordAge, cfg.MaxRecordAge)
+		default:
+			log.Printf("Cloudflare record %s already up to date", result.Label)
+			result.Action = actionUnchanged
+			return result, nil
+		}
+	}
+
+	if cfg.DryRun {
+		log.Printf("dry run: would update %s", result.Label)
+		for _, change := range changes {
+			log.Printf("  %s", change)
+		}
+		result.Action = actionDryRun
+		return result, nil
+	}
+	if cfg.ReadOnly {
+		log.Printf("read-only: %s needs updating from %s to %s", result.Label, current, content)
+		result.Action = actionNeeded
+		return result, nil
+	}
+	if writeBudgetSpent(cfg) {
+		log.Printf("warning: %s needs updating from %s to %s, but it was already written %d time(s) in the last hour (%s=%d); skipping", result.Label, current, content, cfg.RecentWrites, envMaxWritesPerHour, cfg.MaxWritesPerHour)
+		result.Action = actionSkipped
+		return result, nil
+	}
+
+	if err := runConfiguredHook(ctx, cfg, "pre-hook", cfg.PreHook, result); err != nil {
+		result.Err = err
+		return result, err
+	}
+
+	if err := provider.Update(ctx, cfg, record.ID, content); err != nil {
+		result.Err = fmt.Errorf("failed to update DNS record: %w", err)
+		return result, result.Err
+	}
+
+	log.Printf("successfully updated %s from %s to %s", result.Label, current, content)
+	result.Action = actionChanged
+
+	if err := runConfiguredHook(ctx, cfg, "post-hook", cfg.PostHook, result); err != nil {
+		result.Err = err
+		return result, err
+	}
+	return result, nil
+}
+
+// writeBudgetSpent reports whether the record in cfg already had the
+// writes CF_MAX_WRITES_PER_HOUR allows within the last hour.
+func writeBudgetSpent(cfg Config) bool {
+	return cfg.MaxWritesPerHour > 0 && cfg.RecentWrites >= cfg.MaxWritesPerHour
+}
+
+// createRecord creates a missing record in sync mode, honoring dry-run and
+// read-only mode and running the configured hooks around the change.
+func createRecord(ctx context.Context, provider DNSProvider, cfg Config, result Result) (Result, error) {
+	if cfg.DryRun {
+		log.Printf("dry run: would create %s with %s", result.Label, result.NewIP)
+		result.Action = actionDryRun
+		return result, nil
+	}
+	if cfg.ReadOnly {
+		log.Printf("read-only: %s is missing and needs creating with %s", result.Label, result.NewIP)
+		result.Action = actionNeeded
+		return result, nil
+	}
+	if writeBudgetSpent(cfg) {
+		log.Printf("warning: %s is missing, but it was already written %d time(s) in the last hour (%s=%d); skipping", result.Label, cfg.RecentWrites, envMaxWritesPerHour, cfg.MaxWritesPerHour)
+		result.Action = actionSkipped
+		return result, nil
+	}
+
+	if err := runConfiguredHook(ctx, cfg, "pre-hook", cfg.PreHook, result); err != nil {
+		result.Err = err
+		return result, err
+	}
+
+	if err := provider.Create(ctx, cfg, result.NewIP); err != nil {
+		result.Err = fmt.Errorf("failed to create DNS record: %w", err)
+		return result, result.Err
+	}
+
+	log.Printf("successfully created %s with %s", result.Label, result.NewIP)
+	result.Action = actionCreated
+
+	if err := runConfiguredHook(ctx, cfg, "post-hook", cfg.PostHook, result); err != nil {
+		result.Err = err
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client returns an HTTP client that routes every request to m in-process.
+func (m *mockCloudflare) client() *http.Client {
+	return &http.Client{
+		Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+			return rec.Result(), nil
+		}),
+	}
+}
+
+func aRecordFixture(id, name, content string) map[string]any {
+	return map[string]any{
+		"id": id, "type": "A", "name": name, "content": content,
+		"proxied": false, "ttl": 300, "tags": []any{},
+	}
+}
+
+// newTestUpdater wires an updater to the fake API and a static IP service.
+func newTestUpdater(t *testing.T, cfg Config, api *mockCloudflare, ip string) *updater {
+	t.Helper()
+
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ip))
+	}))
+	t.Cleanup(ipServer.Close)
+
+	if cfg.AuthMethod == "" {
+		cfg.AuthMethod = "token"
+		cfg.AuthKey = "token-value"
+	}
+	if cfg.ZoneID == "" {
+		cfg.ZoneID = "zone-id"
+	}
+	if cfg.RecordType == "" {
+		cfg.RecordType = "A"
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = 300
+	}
+	if cfg.Output == "" {
+		cfg.Output = outputText
+	}
+	cfg.IPServices = []string{ipServer.URL}
+	cfg.IPv6Services = []string{ipServer.URL}
+	cfg.RecordName = cfg.RecordNames[0]
+
+	cfClient, err := newCloudflareClient(api.client(), cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	return &updater{
+		cfg:             cfg,
+		discoveryClient: &http.Client{},
+		cfClient:        cfClient,
+		provider:        newCloudflareProvider,
+		clock:           newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		out:             io.Discard,
+	}
+}
+
+func TestUpdaterRunJSONOutput(t *testing.T) {
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "203.0.113.10"),
+	)
+	cfg := Config{RecordNames: []string{"a.example.com", "b.example.com"}, Output: outputJSON}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	var out bytes.Buffer
+	u.out = &out
+
+	if err := u.cycle(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one JSON line per record, got %q", out.String())
+	}
+
+	var first, second jsonResult
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[1], err)
+	}
+
+	if first.Result != actionChanged || first.Record != "a.example.com" || first.Old != "198.51.100.1" || first.New != "203.0.113.10" {
+		t.Fatalf("unexpected first result %+v", first)
+	}
+	if first.Type != "A" || first.Timestamp != "2024-01-01T00:00:00Z" {
+		t.Fatalf("unexpected first result %+v", first)
+	}
+	if second.Result != actionUnchanged {
+		t.Fatalf("unexpected second result %+v", second)
+	}
+	if api.updates != 1 {
+		t.Fatalf("expected exactly one update, got %d", api.updates)
+	}
+}
+
+func TestWriteReportDuration(t *testing.T) {
+	var out bytes.Buffer
+	results := []Result{
+		{Action: actionChanged, Record: "a.example.com", Type: "A", OldIP: "198.51.100.1", NewIP: "203.0.113.10", Duration: 1234567 * time.Microsecond},
+		{Action: actionUnchanged, Record: "b.example.com", Type: "A", OldIP: "203.0.113.10", NewIP: "203.0.113.10"},
+	}
+	if err := writeReport(&out, results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if got := strings.Join(strings.Fields(lines[1]), " "); got != "a.example.com A 203.0.113.10 198.51.100.1 changed 1.235s - -" {
+		t.Fatalf("unexpected row %q", got)
+	}
+	if got := strings.Join(strings.Fields(lines[2]), " "); got != "b.example.com A 203.0.113.10 203.0.113.10 unchanged - - -" {
+		t.Fatalf("unexpected row %q", got)
+	}
+}
+
+func TestUpdaterRunResults(t *testing.T) {
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "203.0.113.10"),
+	)
+	cfg := Config{RecordNames: []string{"a.example.com", "b.example.com", "c.example.com"}}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err == nil {
+		t.Fatalf("expected missing record to fail the run")
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected one result per record, got %+v", results)
+	}
+
+	if r := results[0]; r.Action != actionChanged || r.OldIP != "198.51.100.1" || r.NewIP != "203.0.113.10" || r.Err != nil {
+		t.Fatalf("unexpected first result %+v", r)
+	}
+	if r := results[1]; r.Action != actionUnchanged || r.Err != nil {
+		t.Fatalf("unexpected second result %+v", r)
+	}
+	if r := results[2]; r.Action != actionError || r.Err == nil {
+		t.Fatalf("unexpected third result %+v", r)
+	}
+	if countFailed(results) != 1 {
+		t.Fatalf("expected exactly one failed result")
+	}
+}
+
+func TestUpdaterConcurrentResultsKeepOrder(t *testing.T) {
+	var names []string
+	var records []map[string]any
+	for i := range 10 {
+		name := fmt.Sprintf("host%d.example.com", i)
+		names = append(names, name)
+		records = append(records, aRecordFixture(fmt.Sprintf("id-%d", i), name, "198.51.100.1"))
+	}
+	api := newMockCloudflare(records...)
+	cfg := Config{RecordNames: names, Concurrency: 3}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if api.updates != len(names) {
+		t.Fatalf("expected %d updates, got %d", len(names), api.updates)
+	}
+	for i, r := range results {
+		if r.Record != names[i] || r.Action != actionChanged {
+			t.Fatalf("result %d: expected %s changed, got %+v", i, names[i], r)
+		}
+	}
+}
+
+func TestUpdaterForceWritesUpToDateRecord(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "203.0.113.10"))
+	cfg := Config{RecordNames: []string{"example.com"}, Force: true}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if results[0].Action != actionChanged || api.updates != 1 {
+		t.Fatalf("expected a forced update, got %+v after %d update(s)", results[0], api.updates)
+	}
+}
+
+func TestUpdaterMaxRecordAge(t *testing.T) {
+	record := aRecordFixture("id-1", "example.com", "203.0.113.10")
+	record["modified_on"] = "2023-12-25T00:00:00Z"
+	api := newMockCloudflare(record)
+	cfg := Config{RecordNames: []string{"example.com"}, MaxRecordAge: 30 * 24 * time.Hour}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil || results[0].Action != actionUnchanged || api.updates != 0 {
+		t.Fatalf("expected a recently modified record to be left alone, got %+v, %v", results, err)
+	}
+
+	u.cfg.MaxRecordAge = 7 * 24 * time.Hour
+	results, err = u.run(context.Background())
+	if err != nil || results[0].Action != actionChanged || api.updates != 1 {
+		t.Fatalf("expected a stale record to be rewritten, got %+v, %v after %d update(s)", results, err, api.updates)
+	}
+}
+
+func TestUpdaterRunMissingRecord(t *testing.T) {
+	api := newMockCloudflare()
+	cfg := Config{RecordNames: []string{"missing.example.com"}}
+
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	if _, err := u.run(context.Background()); err == nil {
+		t.Fatalf("expected missing record to fail by default")
+	}
+
+	cfg.MissingOK = true
+	u = newTestUpdater(t, cfg, api, "203.0.113.10")
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected missing record to be tolerated, got %v", err)
+	}
+}
+
+func TestUpdaterRunTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(slow.Close)
+
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"example.com"}, RunTimeout: 50 * time.Millisecond}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	u.cfg.IPServices = []string{slow.URL, slow.URL}
+
+	results, err := u.run(context.Background())
+	if !errors.Is(err, errRunTimeout) {
+		t.Fatalf("expected run timeout, got %v", err)
+	}
+	if len(results) != 1 || results[0].Action != actionError {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestUpdaterSyncModePrune(t *testing.T) {
+	stale := aRecordFixture("id-2", "old.example.com", "198.51.100.1")
+	stale["comment"] = managedComment
+	manual := aRecordFixture("id-3", "manual.example.com", "198.51.100.1")
+
+	api := newMockCloudflare(aRecordFixture("id-1", "a.example.com", "203.0.113.10"), stale, manual)
+	cfg := Config{RecordNames: []string{"a.example.com", "b.example.com"}, RecordMode: recordModeSync, Prune: true}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+
+	actions := make(map[string]string)
+	for _, r := range results {
+		actions[r.Record] = r.Action
+	}
+	want := map[string]string{
+		"a.example.com":   actionChanged, // adopted: gains the managed marker
+		"b.example.com":   actionCreated,
+		"old.example.com": actionDeleted,
+	}
+	if len(actions) != len(want) {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	for name, action := range want {
+		if actions[name] != action {
+			t.Fatalf("expected %s to be %s, got %+v", name, action, results)
+		}
+	}
+
+	if api.creates != 1 || api.deletes != 1 {
+		t.Fatalf("expected one create and one delete, got %d and %d", api.creates, api.deletes)
+	}
+	if _, ok := api.records["manual.example.com"]; !ok {
+		t.Fatalf("unmanaged record must never be pruned")
+	}
+	if api.records["b.example.com"]["comment"] != managedComment {
+		t.Fatalf("expected created record to carry the managed marker")
+	}
+
+	results, err = u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	for _, r := range results {
+		if r.Action != actionUnchanged {
+			t.Fatalf("expected second sync to be a no-op, got %+v", results)
+		}
+	}
+}
+
+func TestUpdaterPreserveMeta(t *testing.T) {
+	record := aRecordFixture("id-1", "example.com", "198.51.100.1")
+	record["ttl"] = 1
+	record["proxied"] = true
+	api := newMockCloudflare(record)
+
+	cfg := Config{RecordNames: []string{"example.com"}, TTL: 600, PreserveMeta: true}
+	u := newTestUpdater(t, cfg, api, "198.51.100.1")
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if api.updates != 0 {
+		t.Fatalf("expected metadata differences to be ignored, got %d updates", api.updates)
+	}
+
+	u = newTestUpdater(t, cfg, api, "203.0.113.10")
+	if _, err := u.run(context.Background()); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	got := api.records["example.com"]
+	if got["content"] != "203.0.113.10" || got["ttl"] != float64(1) || got["proxied"] != true {
+		t.Fatalf("expected only content to change, got %v", got)
+	}
+}
+
+func TestUpdaterAutoRecordType(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "example.com", "198.51.100.1"))
+
+	cfg := Config{
+		RecordNames:   []string{"example.com"},
+		RecordType:    recordTypeAuto,
+		AutoPrefer:    autoPreferIPv6,
+		IPv6Interface: "does-not-exist0",
+	}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected fallback to IPv4, got %v", err)
+	}
+	if r := results[0]; r.Type != "A" || r.Action != actionChanged || r.NewIP != "203.0.113.10" {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}
+
+func TestUpdaterPermissionDenied(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	api.readOnly = true
+	u := newTestUpdater(t, Config{RecordNames: []string{"home.example.com"}}, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	var permErr *PermissionError
+	if err == nil || !errors.As(results[0].Err, &permErr) || !strings.Contains(results[0].Err.Error(), envReadOnly) {
+		t.Fatalf("expected a permission error pointing at %s, got %v", envReadOnly, results[0].Err)
+	}
+}
+
+func TestUpdaterReadOnly(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	api.readOnly = true
+	cfg := Config{RecordNames: []string{"home.example.com", "new.example.com"}, RecordMode: recordModeSync, ReadOnly: true}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	results, err := u.run(context.Background())
+	if !errors.Is(err, errChangesNeeded) {
+		t.Fatalf("expected errChangesNeeded, got %v", err)
+	}
+	for _, r := range results {
+		if r.Action != actionNeeded || r.Err != nil {
+			t.Fatalf("expected every record to need a change, got %+v", results)
+		}
+	}
+	if api.updates != 0 || api.creates != 0 {
+		t.Fatalf("expected no writes, got %d update(s) and %d create(s)", api.updates, api.creates)
+	}
+}
+
+func TestUpdaterFreezeFile(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "home.example.com", "198.51.100.1"))
+	freeze := filepath.Join(t.TempDir(), "freeze")
+	cfg := Config{RecordNames: []string{"home.example.com"}, FreezeFile: freeze}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	if err := os.WriteFile(freeze, nil, 0o644); err != nil {
+		t.Fatalf("write freeze file: %v", err)
+	}
+	results, err := u.run(context.Background())
+	if !errors.Is(err, errFrozen) {
+		t.Fatalf("expected errFrozen, got %v", err)
+	}
+	if results[0].Action != actionNeeded || api.updates != 0 {
+		t.Fatalf("expected the change to be held back, got %+v after %d update(s)", results, api.updates)
+	}
+
+	if err := os.Remove(freeze); err != nil {
+		t.Fatalf("remove freeze file: %v", err)
+	}
+	results, err = u.run(context.Background())
+	if err != nil || results[0].Action != actionChanged {
+		t.Fatalf("expected updates to resume once the file is gone, got %+v, %v", results, err)
+	}
+}
+
+func TestUpdaterDisplayName(t *testing.T) {
+	api := newMockCloudflare(aRecordFixture("id-1", "a.example.com", "198.51.100.1"))
+	cfg := Config{RecordNames: []string{"a.example.com"}, DisplayNames: map[string]string{"a.example.com": "Router"}}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+
+	var logs strings.Builder
+	prev := log.Writer()
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(prev) })
+
+	results, err := u.run(context.Background())
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if results[0].Record != "a.example.com" || results[0].Label != "Router" {
+		t.Fatalf("expected the real name in results and the label alongside, got %+v", results[0])
+	}
+	if !strings.Contains(logs.String(), "successfully updated Router from 198.51.100.1 to 203.0.113.10") {
+		t.Fatalf("expected the label in logs, got %q", logs.String())
+	}
+}
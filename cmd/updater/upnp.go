@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ipSourceHTTP = "http"
+	ipSourceUPnP = "upnp"
+
+	ssdpAddr        = "239.255.255.250:1900"
+	upnpWaitTimeout = 3 * time.Second
+)
+
+// upnpServiceTypes are the Internet Gateway Device services able to report the
+// WAN address, in order of preference.
+var upnpServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// discoverUPnP asks the local Internet Gateway Device for its external IPv4
+// address using SSDP discovery followed by a GetExternalIPAddress SOAP call.
+// Gateways that do not speak UPnP IGD are asked over NAT-PMP instead.
+func discoverUPnP(ctx context.Context, client *http.Client, clock Clock) (string, error) {
+	raw, err := upnpDiscoverIP(ctx, client, clock)
+	if err != nil {
+		var natErr error
+		raw, natErr = discoverNATPMP(ctx, clock)
+		if natErr != nil {
+			return "", fmt.Errorf("%w; NAT-PMP: %w", err, natErr)
+		}
+	}
+
+	return parseIPv4(raw)
+}
+
+func upnpDiscoverIP(ctx context.Context, client *http.Client, clock Clock) (string, error) {
+	location, err := ssdpSearch(ctx, clock.Now().Add(upnpWaitTimeout))
+	if err != nil {
+		return "", err
+	}
+	return upnpExternalIP(ctx, client, location)
+}
+
+// ssdpSearch multicasts an M-SEARCH for Internet Gateway Devices and returns
+// the LOCATION of the first response received before deadline.
+func ssdpSearch(ctx context.Context, deadline time.Time) (string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return "", err
+	}
+
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(search), dst); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", fmt.Errorf("no UPnP gateway answered: %w", err)
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// upnpDevice is the subset of a UPnP device description needed to locate the
+// WAN connection service. Devices nest arbitrarily deep.
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// findService returns the control URL of the first matching service type.
+func (d upnpDevice) findService(serviceType string) string {
+	for _, svc := range d.Services {
+		if svc.ServiceType == serviceType {
+			return svc.ControlURL
+		}
+	}
+	for _, child := range d.Devices {
+		if control := child.findService(serviceType); control != "" {
+			return control
+		}
+	}
+	return ""
+}
+
+// upnpExternalIP fetches the device description at location and calls
+// GetExternalIPAddress on its WAN connection service.
+func upnpExternalIP(ctx context.Context, client *http.Client, location string) (string, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid UPnP location %q: %w", location, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch UPnP description: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var desc struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return "", fmt.Errorf("failed to parse UPnP description: %w", err)
+	}
+	if desc.URLBase != "" {
+		if parsed, err := url.Parse(desc.URLBase); err == nil {
+			base = parsed
+		}
+	}
+
+	for _, serviceType := range upnpServiceTypes {
+		control := desc.Device.findService(serviceType)
+		if control == "" {
+			continue
+		}
+		controlURL, err := base.Parse(control)
+		if err != nil {
+			return "", fmt.Errorf("invalid UPnP control URL %q: %w", control, err)
+		}
+		return upnpGetExternalIP(ctx, client, controlURL.String(), serviceType)
+	}
+
+	return "", errors.New("UPnP gateway does not expose a WAN connection service")
+}
+
+func upnpGetExternalIP(ctx context.Context, client *http.Client, controlURL, serviceType string) (string, error) {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + serviceType + `"/></s:Body></s:Envelope>`
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controlURL, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+serviceType+`#GetExternalIPAddress"`)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("UPnP GetExternalIPAddress failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return "", fmt.Errorf("UPnP GetExternalIPAddress returned %s", resp.Status)
+	}
+
+	var envelope struct {
+		IP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return "", fmt.Errorf("failed to parse UPnP response: %w", err)
+	}
+	if envelope.IP == "" {
+		return "", errors.New("UPnP gateway returned an empty external IP")
+	}
+
+	return envelope.IP, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const upnpDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+const upnpResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+  <s:Body>
+    <u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+      <NewExternalIPAddress>203.0.113.77</NewExternalIPAddress>
+    </u:GetExternalIPAddressResponse>
+  </s:Body>
+</s:Envelope>`
+
+func TestUPnPExternalIP(t *testing.T) {
+	var soapAction, soapBody string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(upnpDescription))
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		soapAction = r.Header.Get("SOAPAction")
+		body, _ := io.ReadAll(r.Body)
+		soapBody = string(body)
+		w.Write([]byte(upnpResponse))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ip, err := upnpExternalIP(context.Background(), &http.Client{}, server.URL+"/rootDesc.xml")
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if ip != "203.0.113.77" {
+		t.Fatalf("unexpected IP %s", ip)
+	}
+	if soapAction != `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"` {
+		t.Fatalf("unexpected SOAPAction %s", soapAction)
+	}
+	if !strings.Contains(soapBody, "GetExternalIPAddress") {
+		t.Fatalf("unexpected SOAP body %s", soapBody)
+	}
+}
+
+func TestUPnPExternalIPMissingService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<root><device><deviceType>x</deviceType></device></root>`))
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := upnpExternalIP(context.Background(), &http.Client{}, server.URL); err == nil {
+		t.Fatalf("expected error when no WAN service is present")
+	}
+}
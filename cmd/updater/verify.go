@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVerifyTimeout = 2 * time.Minute
+	verifyPollInterval   = 5 * time.Second
+
+	// defaultVerifyResolver is asked unless CF_VERIFY_RESOLVER names another
+	// server, so verification sees what the internet sees rather than a
+	// local cache. resolverSystem selects the system resolver instead.
+	defaultVerifyResolver = "1.1.1.1:53"
+	resolverSystem        = "system"
+)
+
+// lookupFunc resolves host to its addresses, matching net.Resolver.LookupHost.
+type lookupFunc func(ctx context.Context, host string) ([]string, error)
+
+// newLookup returns a lookupFunc that asks server (host:port) directly, or
+// the system resolver when server is empty.
+func newLookup(server string) lookupFunc {
+	if server == "" {
+		return net.DefaultResolver.LookupHost
+	}
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	return resolver.LookupHost
+}
+
+// verify resolves every record the run published and checks that it answers
+// with the new content, polling until cfg.VerifyTimeout. The resolved
+// addresses and any mismatch are stored on the results.
+func (u *updater) verify(ctx context.Context, results []Result) {
+	for i := range results {
+		r := &results[i]
+		if !shouldVerify(*r) {
+			continue
+		}
+		r.Resolved, r.VerifyErr = verifyRecord(ctx, u.clock, u.lookup, r.Record, r.NewIP, u.cfg.VerifyTimeout)
+		if r.VerifyErr != nil {
+			log.Printf("%s: verification failed: %v", r.label(), r.VerifyErr)
+		} else {
+			log.Printf("%s: verified %s via DNS", r.label(), r.Resolved)
+		}
+	}
+}
+
+// shouldVerify reports whether r left an address record that should resolve
+// to r.NewIP.
+func shouldVerify(r Result) bool {
+	if r.Type != "A" && r.Type != "AAAA" {
+		return false
+	}
+	switch r.Action {
+	case actionChanged, actionCreated, actionUnchanged:
+		return r.NewIP != ""
+	}
+	return false
+}
+
+// verifyRecord polls lookup until name resolves to want or timeout passes.
+// It returns the addresses seen last, comma-separated.
+func verifyRecord(ctx context.Context, clock Clock, lookup lookupFunc, name, want string, timeout time.Duration) (string, error) {
+	wantAddr, err := netip.ParseAddr(want)
+	if err != nil {
+		return "", fmt.Errorf("invalid expected address %q", want)
+	}
+	deadline := clock.Now().Add(timeout)
+
+	for {
+		addrs, err := lookup(ctx, name)
+		resolved := strings.Join(addrs, ",")
+		if err == nil && containsAddr(addrs, wantAddr) {
+			return resolved, nil
+		}
+
+		if !clock.Now().Add(verifyPollInterval).Before(deadline) {
+			if err != nil {
+				return resolved, fmt.Errorf("lookup %s: %w", name, err)
+			}
+			return resolved, fmt.Errorf("%s resolves to %s, expected %s after %s", name, resolved, want, timeout)
+		}
+		if err := sleepContext(ctx, clock, verifyPollInterval); err != nil {
+			return resolved, err
+		}
+	}
+}
+
+func containsAddr(addrs []string, want netip.Addr) bool {
+	for _, a := range addrs {
+		if addr, err := netip.ParseAddr(a); err == nil && addr.Unmap() == want.Unmap() {
+			return true
+		}
+	}
+	return false
+}
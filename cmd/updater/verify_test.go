@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyRecord(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		return []string{"198.51.100.1", "2001:db8::1"}, nil
+	}
+
+	resolved, err := verifyRecord(context.Background(), clock, lookup, "example.com", "2001:db8:0::1", time.Minute)
+	if err != nil {
+		t.Fatalf("expected match, got %v", err)
+	}
+	if resolved != "198.51.100.1,2001:db8::1" {
+		t.Fatalf("unexpected resolved value %q", resolved)
+	}
+
+	resolved, err = verifyRecord(context.Background(), clock, lookup, "example.com", "203.0.113.10", time.Second)
+	if err == nil {
+		t.Fatalf("expected mismatch to fail verification")
+	}
+	if resolved != "198.51.100.1,2001:db8::1" {
+		t.Fatalf("expected resolved value on failure, got %q", resolved)
+	}
+}
+
+func TestUpdaterVerifyReport(t *testing.T) {
+	api := newMockCloudflare(
+		aRecordFixture("id-1", "a.example.com", "198.51.100.1"),
+		aRecordFixture("id-2", "b.example.com", "198.51.100.1"),
+	)
+	cfg := Config{
+		RecordNames:   []string{"a.example.com", "b.example.com"},
+		VerifyDNS:     true,
+		VerifyTimeout: time.Second,
+		Output:        outputReport,
+	}
+	u := newTestUpdater(t, cfg, api, "203.0.113.10")
+	// a.example.com resolves to whatever the API holds; b.example.com is
+	// served by a stale resolver.
+	u.lookup = func(ctx context.Context, host string) ([]string, error) {
+		if host == "b.example.com" {
+			return []string{"198.51.100.1"}, nil
+		}
+		return []string{api.records[host]["content"].(string)}, nil
+	}
+
+	var out bytes.Buffer
+	u.out = &out
+
+	err := u.cycle(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 record(s) failed DNS verification") {
+		t.Fatalf("expected verification failure, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header, two rows and one error line, got %q", out.String())
+	}
+	if got := strings.Join(strings.Fields(lines[1]), " "); got != "a.example.com A 203.0.113.10 198.51.100.1 changed - 203.0.113.10 yes" {
+		t.Fatalf("unexpected row %q", got)
+	}
+	if got := strings.Join(strings.Fields(lines[2]), " "); got != "b.example.com A 203.0.113.10 198.51.100.1 changed - 198.51.100.1 no" {
+		t.Fatalf("unexpected row %q", got)
+	}
+	if !strings.HasPrefix(lines[3], "b.example.com: b.example.com resolves to 198.51.100.1") {
+		t.Fatalf("unexpected error line %q", lines[3])
+	}
+}
+
+func TestLoadConfigVerifyResolver(t *testing.T) {
+	t.Setenv(envAuthKey, "token-value")
+	t.Setenv(envZoneID, "zone-id")
+	t.Setenv(envRecordName, "example.com")
+
+	cases := map[string]string{
+		"":                 defaultVerifyResolver,
+		"system":           "",
+		"8.8.8.8":          "8.8.8.8:53",
+		"192.168.1.1:5353": "192.168.1.1:5353",
+		"2606:4700::1111":  "[2606:4700::1111]:53",
+	}
+	for value, want := range cases {
+		t.Setenv(envVerifyResolver, value)
+		cfg, err := loadConfig()
+		if err != nil || cfg.VerifyResolver != want {
+			t.Errorf("%s=%q: got %q, %v; want %q", envVerifyResolver, value, cfg.VerifyResolver, err, want)
+		}
+	}
+
+	t.Setenv(envVerifyResolver, "1.1.1.1:dns")
+	if _, err := loadConfig(); err == nil {
+		t.Fatalf("expected an invalid port to be rejected")
+	}
+}
@@ -0,0 +1,196 @@
+// Package notify delivers cloudflare-ddns-cron events to notification
+// channels. The updater ships a webhook and a Discord channel; programs
+// embedding it can register their own Notifier implementations.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event kinds sent by the updater.
+const (
+	KindFailure  = "failure"
+	KindRecovery = "recovery"
+	KindStreak   = "streak"
+	KindNetwork  = "network"
+	KindStuck    = "stuck"
+)
+
+// Event describes something that happened during an update run.
+type Event struct {
+	Kind    string
+	Message string
+	// Failures is the number of consecutive failed runs; for a recovery,
+	// the length of the streak that just ended.
+	Failures int
+	Time     time.Time
+	// RunID identifies the update run that raised the event, matching the
+	// prefix of its log lines. It may be empty.
+	RunID string
+}
+
+// Notifier delivers events to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(ctx context.Context, event Event) error
+
+func (f NotifierFunc) Notify(ctx context.Context, event Event) error { return f(ctx, event) }
+
+// Registry holds named notifiers and sends every event to all of them.
+// The zero value is empty and ready to use.
+type Registry struct {
+	names     []string
+	notifiers []Notifier
+}
+
+// Register adds n under name, which identifies it in delivery results.
+func (r *Registry) Register(name string, n Notifier) {
+	r.names = append(r.names, name)
+	r.notifiers = append(r.notifiers, n)
+}
+
+// Channels selects the built-in channels. A channel whose URL is empty is
+// left out.
+type Channels struct {
+	WebhookURL string
+	DiscordURL string
+}
+
+var (
+	extraMu    sync.Mutex
+	extraNames []string
+	extras     []Notifier
+)
+
+// Register adds n under name to every Registry created by New afterwards,
+// including the one the updater builds. Call it from an init function to
+// plug a custom channel into a build of the updater.
+func Register(name string, n Notifier) {
+	extraMu.Lock()
+	defer extraMu.Unlock()
+	extraNames = append(extraNames, name)
+	extras = append(extras, n)
+}
+
+// New returns a Registry holding the built-in channels configured in ch,
+// which send through client, followed by every notifier added with the
+// package-level Register. More can be added with Registry.Register.
+func New(client *http.Client, ch Channels) *Registry {
+	r := &Registry{}
+	if ch.WebhookURL != "" {
+		r.Register("webhook", Webhook{Client: client, URL: ch.WebhookURL})
+	}
+	if ch.DiscordURL != "" {
+		r.Register("discord", Discord{Client: client, URL: ch.DiscordURL})
+	}
+
+	extraMu.Lock()
+	defer extraMu.Unlock()
+	for i, n := range extras {
+		r.Register(extraNames[i], n)
+	}
+	return r
+}
+
+// Len returns the number of registered notifiers.
+func (r *Registry) Len() int { return len(r.notifiers) }
+
+// Delivery is the outcome of sending an event to one notifier.
+type Delivery struct {
+	Name string
+	Err  error
+}
+
+// Send delivers event to every notifier in parallel and returns one
+// Delivery per notifier in registration order. A failing notifier does not
+// affect the others.
+func (r *Registry) Send(ctx context.Context, event Event) []Delivery {
+	deliveries := make([]Delivery, len(r.notifiers))
+	var wg sync.WaitGroup
+	for i, n := range r.notifiers {
+		deliveries[i].Name = r.names[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deliveries[i].Err = n.Notify(ctx, event)
+		}()
+	}
+	wg.Wait()
+	return deliveries
+}
+
+// Webhook posts events as JSON to URL.
+type Webhook struct {
+	Client *http.Client
+	URL    string
+}
+
+// webhookBody is the JSON document posted by Webhook.
+type webhookBody struct {
+	Event     string `json:"event"`
+	Message   string `json:"message"`
+	Failures  int    `json:"consecutive_failures"`
+	Timestamp string `json:"timestamp"`
+	RunID     string `json:"run_id,omitempty"`
+}
+
+func (w Webhook) Notify(ctx context.Context, event Event) error {
+	return postJSON(ctx, w.Client, w.URL, webhookBody{
+		Event:     event.Kind,
+		Message:   event.Message,
+		Failures:  event.Failures,
+		Timestamp: event.Time.UTC().Format(time.RFC3339),
+		RunID:     event.RunID,
+	})
+}
+
+// Discord posts events as chat messages to a Discord channel webhook.
+type Discord struct {
+	Client *http.Client
+	URL    string
+}
+
+func (d Discord) Notify(ctx context.Context, event Event) error {
+	content := fmt.Sprintf("**cloudflare-ddns %s**: %s", event.Kind, event.Message)
+	if event.RunID != "" {
+		content += fmt.Sprintf(" (run %s)", event.RunID)
+	}
+	return postJSON(ctx, d.Client, d.URL, map[string]string{"content": content})
+}
+
+// postJSON posts body as JSON to url and fails on a non-2xx answer.
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
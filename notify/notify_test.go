@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegistrySendsToEveryNotifier(t *testing.T) {
+	var reg Registry
+	var got []string
+	reg.Register("broken", NotifierFunc(func(ctx context.Context, event Event) error {
+		return errors.New("unreachable")
+	}))
+	reg.Register("working", NotifierFunc(func(ctx context.Context, event Event) error {
+		got = append(got, event.Message)
+		return nil
+	}))
+
+	deliveries := reg.Send(context.Background(), Event{Kind: KindFailure, Message: "run failed"})
+	if len(deliveries) != 2 || deliveries[0].Name != "broken" || deliveries[0].Err == nil {
+		t.Fatalf("unexpected deliveries %+v", deliveries)
+	}
+	if deliveries[1].Name != "working" || deliveries[1].Err != nil {
+		t.Fatalf("unexpected deliveries %+v", deliveries)
+	}
+	if len(got) != 1 || got[0] != "run failed" {
+		t.Fatalf("expected working notifier to receive the event, got %v", got)
+	}
+}
+
+func TestNewRegistersChannels(t *testing.T) {
+	t.Cleanup(func() { extraNames, extras = nil, nil })
+
+	var custom []string
+	Register("custom", NotifierFunc(func(ctx context.Context, event Event) error {
+		custom = append(custom, event.Message)
+		return nil
+	}))
+
+	reg := New(http.DefaultClient, Channels{DiscordURL: "https://discord.example/hook"})
+	if reg.Len() != 2 || reg.names[0] != "discord" || reg.names[1] != "custom" {
+		t.Fatalf("expected discord and custom channels, got %v", reg.names)
+	}
+
+	reg = New(nil, Channels{})
+	reg.Send(context.Background(), Event{Kind: KindFailure, Message: "run failed"})
+	if reg.Len() != 1 || len(custom) != 1 {
+		t.Fatalf("expected only the custom channel to be used, got %v and %v", reg.names, custom)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	t.Cleanup(server.Close)
+
+	event := Event{Kind: KindRecovery, Message: "recovered", Failures: 4, Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), RunID: "0a1b2c3d"}
+	if err := (Webhook{Client: server.Client(), URL: server.URL}).Notify(context.Background(), event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["event"] != "recovery" || body["consecutive_failures"] != float64(4) || body["timestamp"] != "2024-01-01T00:00:00Z" || body["run_id"] != "0a1b2c3d" {
+		t.Fatalf("unexpected webhook body %v", body)
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	d := Discord{Client: server.Client(), URL: server.URL}
+	if err := d.Notify(context.Background(), Event{Kind: KindFailure, Message: "run failed: boom"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["content"] != "**cloudflare-ddns failure**: run failed: boom" {
+		t.Fatalf("unexpected Discord message %v", body)
+	}
+
+	if err := d.Notify(context.Background(), Event{Kind: KindFailure, Message: "run failed: boom", RunID: "0a1b2c3d"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body["content"] != "**cloudflare-ddns failure**: run failed: boom (run 0a1b2c3d)" {
+		t.Fatalf("unexpected Discord message %v", body)
+	}
+}
+
+func TestWebhookRejectsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	if err := (Webhook{Client: server.Client(), URL: server.URL}).Notify(context.Background(), Event{}); err == nil {
+		t.Fatalf("expected error status to fail delivery")
+	}
+}